@@ -0,0 +1,172 @@
+package gogroupimports
+
+import (
+	"go/ast"
+	"go/build"
+	"go/token"
+	"path/filepath"
+	"sync"
+)
+
+// RuleUnnecessaryAlias is reported when BanUnnecessaryAliases is set for aliases repeating the
+// name of the package, or renaming it without a conflict to avoid
+const RuleUnnecessaryAlias = "unnecessary-alias"
+
+// packageNames caches the names of imported packages by the directory importing them, since
+// resolving them may run the go command. A nil cache resolves every time.
+type packageNames struct {
+	mu    sync.Mutex
+	names map[string]string // By platform, directory and import path, "" when unknown
+}
+
+// lookup returns the name declared by the package importPath refers to from srcDir, taking the
+// files ctxt builds. ok is false when the package can't be loaded, the name is unknown then:
+// import paths don't tell, like k8s.io/api/autoscaling/v2 declaring package v2.
+func (names *packageNames) lookup(ctxt *build.Context, importPath, srcDir string) (name string, ok bool) {
+	key := ctxt.GOOS + "/" + ctxt.GOARCH + "\x00" + srcDir + "\x00" + importPath
+	if names != nil {
+		names.mu.Lock()
+		name, ok = names.names[key]
+		names.mu.Unlock()
+		if ok {
+			return name, name != ""
+		}
+	}
+	if pkg, err := ctxt.Import(importPath, srcDir, 0); err == nil {
+		name = pkg.Name
+	}
+	if names != nil {
+		names.mu.Lock()
+		if names.names == nil {
+			names.names = make(map[string]string)
+		}
+		names.names[key] = name
+		names.mu.Unlock()
+	}
+	return name, name != ""
+}
+
+// unnecessaryAlias is an alias that can be dropped, with the name the package gets then
+type unnecessaryAlias struct {
+	spec    *ast.ImportSpec
+	natural string
+}
+
+// unnecessaryAliases returns the aliases of node that can be dropped: those equal to the name
+// the package declares, and those renaming it although no other import and no identifier of the
+// file uses that name. Packages are loaded from srcDir to learn their names, aliases of packages
+// that can't be loaded are kept, as are blank, dot and required major version aliases.
+func unnecessaryAliases(node *ast.File, settings Settings, ctxt *build.Context, srcDir string) []unnecessaryAlias {
+	if !settings.BanUnnecessaryAliases {
+		return nil
+	}
+	var candidates []*ast.ImportSpec
+	for _, importSpec := range node.Imports {
+		if importSpec.Name == nil || importSpec.Name.Name == "_" || importSpec.Name.Name == "." {
+			continue
+		}
+		importPath := importPathOf(importSpec)
+		if importPath == "C" {
+			continue
+		}
+		if settings.RequireMajorVersionAliases && majorVersionOf(importPath) != "" && !isBuiltinImport(importPath, settings) {
+			continue
+		}
+		if configured, ok := settings.MajorVersionAliases[importPath]; ok && importSpec.Name.Name == configured {
+			continue
+		}
+		candidates = append(candidates, importSpec)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	// The names the file uses, other than aliases
+	used := make(map[string]bool)
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.ImportSpec:
+			return false
+		case *ast.Ident:
+			used[n.Name] = true
+		}
+		return true
+	})
+	// Dot imports and packages of unknown names bring names into the file that can't be told
+	// from the source, renames are kept then
+	imported := make(map[string]int)
+	unknownNames := false
+	for _, importSpec := range node.Imports {
+		if importSpec.Name != nil {
+			unknownNames = unknownNames || importSpec.Name.Name == "."
+			imported[importSpec.Name.Name]++
+			continue
+		}
+		name, ok := settings.packageNames.lookup(ctxt, importPathOf(importSpec), srcDir)
+		unknownNames = unknownNames || !ok
+		imported[name]++
+	}
+
+	var aliases []unnecessaryAlias
+	for _, importSpec := range candidates {
+		natural, ok := settings.packageNames.lookup(ctxt, importPathOf(importSpec), srcDir)
+		if !ok {
+			continue
+		}
+		if alias := importSpec.Name.Name; alias == natural || !unknownNames && !used[natural] && imported[natural] == 0 {
+			aliases = append(aliases, unnecessaryAlias{spec: importSpec, natural: natural})
+		}
+	}
+	return aliases
+}
+
+// checkUnnecessaryAliases reports the aliases unnecessaryAliases finds
+func checkUnnecessaryAliases(fset *token.FileSet, node *ast.File, settings Settings, filename string) []Diagnostic {
+	if !settings.BanUnnecessaryAliases {
+		return nil
+	}
+	var diagnostics []Diagnostic
+	for _, alias := range unnecessaryAliases(node, settings, buildContextFor(filename, node), filepath.Dir(filename)) {
+		if alias.spec.Name.Name == alias.natural {
+			diagnostics = append(diagnostics, newDiagnostic(fset, alias.spec.Pos(), RuleUnnecessaryAlias,
+				"alias %s repeats the name of the package", alias.natural))
+			continue
+		}
+		diagnostics = append(diagnostics, newDiagnostic(fset, alias.spec.Pos(), RuleUnnecessaryAlias,
+			"alias %s is unnecessary, nothing else in the file is called %s", alias.spec.Name.Name, alias.natural))
+	}
+	return diagnostics
+}
+
+// fixUnnecessaryAliases drops the aliases unnecessaryAliases finds, renaming the references to
+// the package to the name it declares
+func fixUnnecessaryAliases(fset *token.FileSet, node *ast.File, src []byte, settings Settings, ctxt *build.Context, srcDir string) ([]textEdit, []Diagnostic) {
+	aliases := unnecessaryAliases(node, settings, ctxt, srcDir)
+	if len(aliases) == 0 {
+		return nil, nil
+	}
+	renamed := make(map[string]string)
+	var edits []textEdit
+	for _, alias := range aliases {
+		edits = append(edits, textEdit{start: offsetOf(fset, alias.spec.Name.Pos()), end: offsetOf(fset, alias.spec.Path.Pos())})
+		if alias.spec.Name.Name != alias.natural {
+			renamed[alias.spec.Name.Name] = alias.natural
+		}
+	}
+	if len(renamed) > 0 {
+		// References to imports are left unresolved by the parser, unlike local names shadowing them
+		ast.Inspect(node, func(n ast.Node) bool {
+			selector, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			if ident, ok := selector.X.(*ast.Ident); ok && ident.Obj == nil {
+				if natural, ok := renamed[ident.Name]; ok {
+					edits = append(edits, textEdit{start: offsetOf(fset, ident.Pos()), end: offsetOf(fset, ident.End()), text: natural})
+				}
+			}
+			return true
+		})
+	}
+	return edits, nil
+}
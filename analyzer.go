@@ -0,0 +1,84 @@
+package gogroupimports
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// ImportGroupsFact describes how the imports of a package split into import types. The
+// analyzer exports it for every package, so analyzers requiring it, like architecture checks,
+// can build on the classification with pass.ImportPackageFact instead of classifying again.
+type ImportGroupsFact struct {
+	Imports map[string]string // Import type by import path, over all files of the package
+	Counts  map[string]int    // Number of imports of each type, counting every file
+}
+
+// AFact marks ImportGroupsFact as an analysis fact
+func (*ImportGroupsFact) AFact() {}
+
+func (fact *ImportGroupsFact) String() string {
+	var counts []string
+	for importType, count := range fact.Counts {
+		counts = append(counts, fmt.Sprintf("%s=%d", importType, count))
+	}
+	sort.Strings(counts)
+	return "imports(" + strings.Join(counts, ", ") + ")"
+}
+
+// NewAnalyzer returns an analyzer reporting the problems c finds in every file of a package
+// and exporting an ImportGroupsFact for the package
+func NewAnalyzer(c *Checker) *analysis.Analyzer {
+	return &analysis.Analyzer{
+		Name:      "gogroupimports",
+		Doc:       "checks that imports are split into builtin, third party, internal private and own module groups",
+		Run:       func(pass *analysis.Pass) (interface{}, error) { return c.runAnalysis(pass) },
+		FactTypes: []analysis.Fact{new(ImportGroupsFact)},
+	}
+}
+
+// runAnalysis checks the files of pass and exports the fact of its package
+func (c *Checker) runAnalysis(pass *analysis.Pass) (interface{}, error) {
+	if len(pass.Files) == 0 {
+		return nil, nil
+	}
+	fact := &ImportGroupsFact{Imports: make(map[string]string), Counts: make(map[string]int)}
+	for _, file := range pass.Files {
+		if len(file.Imports) == 0 {
+			continue
+		}
+		tokenFile := pass.Fset.File(file.Pos())
+		filename := tokenFile.Name()
+		if skipped, ok := exceedsLimits(filename, int64(tokenFile.Size()), len(file.Imports), c.settings); ok {
+			pass.Report(analysis.Diagnostic{Pos: file.Package, Category: skipped.Rule, Message: skipped.Message})
+			continue
+		}
+		settings, err := c.settingsFor(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		diagnostics, err := checkFile(pass.Fset, file, settings, filename)
+		if err != nil {
+			return nil, err
+		}
+		for _, diagnostic := range diagnostics {
+			pass.Report(analysis.Diagnostic{
+				Pos:      diagnostic.pos,
+				Category: diagnostic.Rule,
+				Message:  diagnostic.Message,
+			})
+		}
+
+		settings, _ = withFileDirectives(pass.Fset, file, settings)
+		for _, importSpec := range file.Imports {
+			importType := getSpecType(importSpec, settings)
+			fact.Imports[importPathOf(importSpec)] = importType
+			fact.Counts[importType]++
+		}
+	}
+	pass.ExportPackageFact(fact)
+	return nil, nil
+}
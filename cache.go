@@ -0,0 +1,165 @@
+package gogroupimports
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// cacheHits and cacheMisses count the lookups of every cache in the process
+var cacheHits, cacheMisses atomic.Uint64
+
+// CacheStats returns how many cache lookups of the process found an entry and how many didn't,
+// for monitoring long running processes. Lookups with caching disabled count as misses.
+func CacheStats() (hits, misses uint64) {
+	return cacheHits.Load(), cacheMisses.Load()
+}
+
+// ClearCaches forgets the lookups every Checker of the process shares, the go-import roots of
+// vanity imports, so that long running processes reloading their config see their current
+// state. Lookups of a Checker, like the build lists of UseGoList, live as long as it does.
+func ClearCaches() {
+	vanityRoots.Lock()
+	defer vanityRoots.Unlock()
+	vanityRoots.roots = make(map[string]vanityRoot)
+}
+
+// diskCache persists JSON encoded lookups between runs. A nil cache stores nothing.
+type diskCache struct {
+	dir string
+}
+
+// openCache returns the cache configured by settings, or nil when caching is disabled or no
+// cache directory is available
+func openCache(settings Settings) *diskCache {
+	if settings.DisableCache {
+		return nil
+	}
+	dir := settings.CacheDir
+	if dir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil
+		}
+		dir = filepath.Join(userCacheDir, "gogroupimports")
+	}
+	return &diskCache{dir: dir}
+}
+
+// cacheKey hashes parts into a key that is safe to use as a file name
+func cacheKey(parts ...[]byte) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		hash.Write(part)
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// ConfigHash identifies the policy c checks files against: its settings, including those merged
+// in from extended configs before NewChecker, the contents of IndexFile, the Go version and
+// standard library packages it classifies as builtin and the rules registered with
+// RegisterRule. Caches of check results, like those of editors or CI jobs keyed by the content
+// of files, add it to their keys so that a changed policy invalidates the results found clean
+// under the old one. It doesn't depend on the files checked before; the build list of
+// UseGoList differs between the modules of files, see ConfigHashFor, and the vanity roots of
+// ResolveVanityImports are served by remote hosts and aren't covered.
+func (c *Checker) ConfigHash() (string, error) {
+	settings, err := json.Marshal(c.settings)
+	if err != nil {
+		return "", err
+	}
+	var index []byte
+	if c.settings.IndexFile != "" {
+		if index, err = os.ReadFile(c.settings.IndexFile); err != nil {
+			return "", err
+		}
+	}
+
+	_, version := gorootOf(c.settings)
+	stdlib := make([]string, 0, len(c.settings.stdlib))
+	for path := range c.settings.stdlib {
+		stdlib = append(stdlib, path)
+	}
+	sort.Strings(stdlib)
+
+	rules.RLock()
+	names := make([]string, 0, len(rules.list))
+	for _, rule := range rules.list {
+		names = append(names, rule.Name())
+	}
+	rules.RUnlock()
+	return cacheKey([]byte("config"), settings, index, []byte(version), []byte(strings.Join(stdlib, "\n")),
+		[]byte(strings.Join(names, "\n"))), nil
+}
+
+// ConfigHashFor is ConfigHash for the results of filename. With UseGoList it also covers the
+// build list of the module containing filename, identified like the cached build lists are.
+func (c *Checker) ConfigHashFor(filename string) (string, error) {
+	hash, err := c.ConfigHash()
+	if err != nil || !c.settings.UseGoList {
+		return hash, err
+	}
+	root, err := findModuleRoot(filepath.Dir(filename))
+	if err != nil {
+		return "", err
+	}
+	modules, err := moduleCacheKey(root)
+	if err != nil {
+		return "", err
+	}
+	return cacheKey([]byte(hash), []byte(modules)), nil
+}
+
+// get decodes the entry stored under key into value and reports whether it was found
+func (cache *diskCache) get(key string, value interface{}) bool {
+	if cache == nil {
+		cacheMisses.Add(1)
+		return false
+	}
+	content, err := os.ReadFile(filepath.Join(cache.dir, key+".json"))
+	if err != nil || json.Unmarshal(content, value) != nil {
+		cacheMisses.Add(1)
+		debugLog.Printf("cache miss %s", key)
+		return false
+	}
+	cacheHits.Add(1)
+	debugLog.Printf("cache hit %s", key)
+	return true
+}
+
+// put stores value under key. Failing to write the cache only costs speed, so errors are ignored.
+func (cache *diskCache) put(key string, value interface{}) {
+	if cache == nil {
+		return
+	}
+	content, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(cache.dir, 0o755); err != nil {
+		return
+	}
+
+	// Write to a temporary file first so concurrent runs never read a partial entry
+	tmp, err := os.CreateTemp(cache.dir, key+".*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(cache.dir, key+".json")); err != nil {
+		os.Remove(tmp.Name())
+	}
+}
@@ -0,0 +1,430 @@
+package gogroupimports
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Checker checks and fixes Go files against one set of Settings. The setup shared by all
+// files, like the stdlib index and module information, is done once and kept by the Checker,
+// so a single Checker should be used for every file of a run.
+//
+// A Checker is safe for concurrent use by multiple goroutines. It works on a copy of the
+// settings taken by NewChecker, later changes to the Settings passed in have no effect.
+type Checker struct {
+	settings Settings
+	modules  moduleIndexCache
+}
+
+// NewChecker validates settings and prepares the lookups they need
+func NewChecker(settings Settings) (*Checker, error) {
+	settings = settings.clone()
+	less, err := lookupSortOrder(settings.SortOrder)
+	if err != nil {
+		return nil, err
+	}
+	settings.less = less
+	if settings.ParseTimeout != "" {
+		if settings.parseTimeout, err = time.ParseDuration(settings.ParseTimeout); err != nil {
+			return nil, fmt.Errorf("invalid parseTimeout: %w", err)
+		}
+	}
+	if err := validatePatterns(settings); err != nil {
+		return nil, err
+	}
+	if err := validateSeverities(settings); err != nil {
+		return nil, err
+	}
+	if err := validateFailOn(settings.FailOn); err != nil {
+		return nil, fmt.Errorf("invalid failOn: %w", err)
+	}
+	if err := validateAliasScheme(settings.AliasScheme); err != nil {
+		return nil, fmt.Errorf("invalid aliasScheme: %w", err)
+	}
+	if err := validateToolsFiles(settings.ToolsFiles); err != nil {
+		return nil, fmt.Errorf("invalid toolsFiles: %w", err)
+	}
+
+	settings.cache = openCache(settings)
+	settings.packageNames = &packageNames{}
+	checker := &Checker{settings: settings}
+	if settings.IndexFile != "" {
+		if err := checker.useIndex(settings.IndexFile); err != nil {
+			return nil, err
+		}
+		return checker, nil
+	}
+	checker.settings.stdlib = stdlibPackages(settings)
+	return checker, nil
+}
+
+// clone returns a deep copy of settings, so that the copy isn't affected by changes to the
+// slices and maps of the original
+func (settings Settings) clone() Settings {
+	settings.InternalPrivateDomains = slices.Clone(settings.InternalPrivateDomains)
+	settings.DeprecatedImports = maps.Clone(settings.DeprecatedImports)
+	for path, replacements := range settings.DeprecatedImports {
+		settings.DeprecatedImports[path] = slices.Clone(replacements)
+	}
+	settings.VanityImports = maps.Clone(settings.VanityImports)
+	settings.MajorVersionAliases = maps.Clone(settings.MajorVersionAliases)
+	settings.LayerRules = slices.Clone(settings.LayerRules)
+	for i, rule := range settings.LayerRules {
+		settings.LayerRules[i].Deny = slices.Clone(rule.Deny)
+		settings.LayerRules[i].Allow = slices.Clone(rule.Allow)
+	}
+	settings.TestOnlyImports = slices.Clone(settings.TestOnlyImports)
+	settings.TestPackagePatterns = slices.Clone(settings.TestPackagePatterns)
+	settings.SideEffectImports = slices.Clone(settings.SideEffectImports)
+	settings.GeneratedImports = slices.Clone(settings.GeneratedImports)
+	settings.HostOrder = slices.Clone(settings.HostOrder)
+	settings.SortPriority = slices.Clone(settings.SortPriority)
+	settings.Severities = maps.Clone(settings.Severities)
+	settings.FailOn = slices.Clone(settings.FailOn)
+	return settings
+}
+
+// settingsFor returns the settings to check filename with, including the module information
+// of the module containing it
+func (c *Checker) settingsFor(filename string) (Settings, error) {
+	return c.settingsForContext(context.Background(), filename)
+}
+
+// settingsForContext is settingsFor tracing the module lookups as a child of the span of ctx
+func (c *Checker) settingsForContext(ctx context.Context, filename string) (Settings, error) {
+	settings := c.settings
+	if settings.UseGoList {
+		ctx, end := startSpan(ctx, "modules", filename)
+		defer end()
+		modules, err := c.modules.load(ctx, filename, settings.cache)
+		if err != nil {
+			return settings, err
+		}
+		settings.modules = modules
+	}
+	return settings, nil
+}
+
+// Check checks filename and returns its problems. An error means that the file couldn't be
+// checked at all, for example because it doesn't parse; it is never used to report problems,
+// so a file passes when both results are nil. Files without imports pass right away, like
+// files guarded by //go:build ignore unless IncludeIgnoredFiles is set, files exceeding
+// MaxFileSize, MaxImports or ParseTimeout are only reported as skipped.
+func (c *Checker) Check(filename string) ([]Diagnostic, error) {
+	return c.CheckContext(context.Background(), filename)
+}
+
+// CheckContext is Check with the spans of the check added to the span of ctx, see SetTracer
+func (c *Checker) CheckContext(ctx context.Context, filename string) ([]Diagnostic, error) {
+	ctx, end := startSpan(ctx, "gogroupimports.check", filename)
+	defer end()
+
+	_, endRead := startSpan(ctx, "read", filename)
+	buf, err := readFile(filename)
+	endRead()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	// Diagnostics and the syntax tree don't refer to the source, only parses abandoned after
+	// ParseTimeout may still read it
+	if c.settings.parseTimeout <= 0 {
+		defer putBuffer(buf)
+	}
+	return c.checkSource(ctx, filename, buf.Bytes())
+}
+
+// CheckSource is CheckContext for the contents src of filename, which doesn't have to exist,
+// e.g. for unsaved editor buffers or sources sent to a server. filename still decides the
+// module, build variant and test status of the file, and whether it is a template, see
+// IsTemplateFile.
+func (c *Checker) CheckSource(ctx context.Context, filename string, src []byte) ([]Diagnostic, error) {
+	ctx, end := startSpan(ctx, "gogroupimports.check", filename)
+	defer end()
+	return c.checkSource(ctx, filename, src)
+}
+
+// checkSource is CheckSource within the span of the check
+func (c *Checker) checkSource(ctx context.Context, filename string, src []byte) ([]Diagnostic, error) {
+	marker := ""
+	if IsTemplateFile(filename) {
+		src, _, marker = templateSource(src)
+	}
+
+	fset := getFileSet()
+	if c.settings.parseTimeout <= 0 {
+		defer putFileSet(fset)
+	}
+	_, endParse := startSpan(ctx, "parse", filename)
+	node, skipped, ok, err := parseForCheck(fset, filename, src, parser.ParseComments, c.settings)
+	endParse()
+	if !ok {
+		return skipped, err
+	}
+
+	settings, err := c.settingsForContext(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+	settings.templateMarker = marker
+
+	_, endClassify := startSpan(ctx, "classify", filename)
+	defer endClassify()
+	diagnostics, err := checkFile(fset, node, settings, filename)
+	if err != nil {
+		return nil, err
+	}
+	narrowFixable(fset, node, src, settings, diagnostics)
+	return diagnostics, nil
+}
+
+// checkFile runs every check on the parsed file
+func checkFile(fset *token.FileSet, node *ast.File, settings Settings, filename string) ([]Diagnostic, error) {
+	settings, skip := fileProfile(node, settings)
+	if skip {
+		return nil, nil
+	}
+	settings, diagnostics := withFileDirectives(fset, node, settings)
+	diagnostics = append(diagnostics, checkImportPaths(fset, node)...)
+	diagnostics = append(diagnostics, checkRelativeImports(fset, node, settings, filename)...)
+
+	importGroups, err := getImportGroups(fset, node, settings)
+	if err != nil {
+		return nil, fmt.Errorf("error getting import groups: %w", err)
+	}
+
+	// Check if imports are properly grouped and have line breaks between groups
+	if i := firstMisplacedGroup(importGroups, settings); i >= 0 {
+		diagnostics = append(diagnostics, newDiagnostic(fset, importGroups[i].pos, RuleGrouping,
+			"imports are not properly grouped: %s imports must come before %s imports", importGroups[i].importType, importGroups[i-1].importType))
+	}
+
+	// Check for line breaks between import groups, and for blank lines within them
+	for i, group := range importGroups {
+		if isSplitGroup(importGroups, i, settings) {
+			diagnostics = append(diagnostics, newDiagnostic(fset, group.pos, RuleSeparator,
+				"unexpected blank line before line %d splitting the %s imports", group.startLine, group.importType))
+			continue
+		}
+		if i > 0 && group.block == importGroups[i-1].block && group.startLine != importGroups[i-1].endLine+2 {
+			diagnostics = append(diagnostics, newDiagnostic(fset, group.pos, RuleSeparator,
+				"missing single line break before line %d", group.startLine))
+		}
+	}
+
+	// Check the order of the imports within their groups
+	diagnostics = append(diagnostics, checkImportOrder(fset, node, settings)...)
+
+	// Check the groups of separate import declarations against each other
+	diagnostics = append(diagnostics, checkDeclarationOrder(fset, importGroups, settings)...)
+
+	// Check that major versions are named
+	diagnostics = append(diagnostics, checkMajorVersionAliases(fset, node, settings)...)
+
+	// Check for aliases that can go
+	diagnostics = append(diagnostics, checkUnnecessaryAliases(fset, node, settings, filename)...)
+
+	// Check for imports following other declarations
+	diagnostics = append(diagnostics, checkMisplacedImports(fset, node)...)
+
+	// Check the blank lines between the package clause and the imports
+	diagnostics = append(diagnostics, checkPackageSpacing(fset, node, settings)...)
+
+	// Check for blank lines at the edges of the import blocks
+	diagnostics = append(diagnostics, checkBlockPadding(fset, node)...)
+
+	// Check for imports outside the import block
+	diagnostics = append(diagnostics, checkFactoredImports(fset, node, settings)...)
+
+	// Check for deprecated imports
+	diagnostics = append(diagnostics, checkDeprecatedImports(fset, node, settings)...)
+
+	// Check for internal packages of other modules
+	diagnostics = append(diagnostics, checkInternalImports(fset, node, settings)...)
+
+	// Check the architecture layering rules
+	diagnostics = append(diagnostics, checkLayers(fset, node, settings, filename)...)
+
+	// Check for test frameworks leaking into production code
+	diagnostics = append(diagnostics, checkTestOnlyImports(fset, node, settings, filename)...)
+	diagnostics = append(diagnostics, checkTestPackageImports(fset, node, settings, filename)...)
+
+	// Run the rules registered by embedders
+	diagnostics = append(diagnostics, checkRules(fset, node, settings)...)
+
+	// Tell that Fix leaves the file alone
+	if directive, ok := lineDirectiveDiagnostic(fset, node); ok {
+		diagnostics = append(diagnostics, directive)
+	}
+
+	for i := range diagnostics {
+		diagnostics[i].Fixable = diagnostics[i].Fixable || fixableRules[diagnostics[i].Rule]
+	}
+	diagnostics = withVariant(applySeverities(diagnostics, settings), filename, node)
+	SortDiagnostics(diagnostics)
+	return diagnostics, nil
+}
+
+// Fix rewrites the imports of filename and returns the updated source, which equals the
+// original when there was nothing to fix. The diagnostics describe the problems that can't be
+// fixed automatically, they don't cover problems Check reports that Fix doesn't deal with.
+// An error means that the file couldn't be fixed at all, the source is nil then.
+func (c *Checker) Fix(filename string) ([]byte, []Diagnostic, error) {
+	return c.FixContext(context.Background(), filename)
+}
+
+// FixContext is Fix with the spans of the fix added to the span of ctx, see SetTracer
+func (c *Checker) FixContext(ctx context.Context, filename string) ([]byte, []Diagnostic, error) {
+	ctx, end := startSpan(ctx, "gogroupimports.fix", filename)
+	defer end()
+
+	_, endRead := startSpan(ctx, "read", filename)
+	src, err := os.ReadFile(filename)
+	endRead()
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.fixSource(ctx, filename, src)
+}
+
+// FixSource is FixContext for the contents src of filename, like CheckSource
+func (c *Checker) FixSource(ctx context.Context, filename string, src []byte) ([]byte, []Diagnostic, error) {
+	ctx, end := startSpan(ctx, "gogroupimports.fix", filename)
+	defer end()
+	return c.fixSource(ctx, filename, src)
+}
+
+// fixSource is FixSource within the span of the fix
+func (c *Checker) fixSource(ctx context.Context, filename string, src []byte) ([]byte, []Diagnostic, error) {
+	if IsTemplateFile(filename) {
+		return c.fixTemplate(ctx, filename, src)
+	}
+	return c.fixGoSource(ctx, filename, src)
+}
+
+// fixTemplate is FixSource for code generation templates, fixing the Go source around their
+// actions. Templates whose actions wouldn't survive the fix are left alone.
+func (c *Checker) fixTemplate(ctx context.Context, filename string, src []byte) ([]byte, []Diagnostic, error) {
+	goSrc, actions, _ := templateSource(src)
+	fixed, diagnostics, err := c.fixGoSource(ctx, filename, goSrc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if bytes.Equal(fixed, goSrc) {
+		return src, diagnostics, nil
+	}
+	if importsHoldLineActions(goSrc, actions) {
+		return src, append(diagnostics, templateFixRefused(filename, c.settings, "template actions between the imports decide which of them are generated")), nil
+	}
+	restored, ok := restoreTemplate(fixed, actions)
+	if !ok {
+		return src, append(diagnostics, templateFixRefused(filename, c.settings, "fixing its imports would drop or repeat template actions")), nil
+	}
+	return restored, diagnostics, nil
+}
+
+// fixGoSource is FixSource for Go source files
+func (c *Checker) fixGoSource(ctx context.Context, filename string, src []byte) ([]byte, []Diagnostic, error) {
+	// The passes parse the source again, only the imports are needed to tell whether to fix it
+	fset := getFileSet()
+	_, endParse := startSpan(ctx, "parse", filename)
+	_, skipped, ok, err := parseForCheck(fset, filename, src, parser.ImportsOnly, c.settings)
+	endParse()
+	if c.settings.parseTimeout <= 0 {
+		putFileSet(fset)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return src, skipped, nil
+	}
+
+	settings, err := c.settingsForContext(ctx, filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, endRewrite := startSpan(ctx, "rewrite", filename)
+	defer endRewrite()
+	srcDir := filepath.Dir(filename)
+	fixed, diagnostics, err := fixSource(filename, src, settings,
+		fixMisplacedImports,
+		fixPackageSpacing,
+		func(fset *token.FileSet, node *ast.File, src []byte, settings Settings) ([]textEdit, []Diagnostic) {
+			return fixUnnecessaryAliases(fset, node, src, settings, buildContextFor(filename, node), srcDir)
+		},
+		func(fset *token.FileSet, node *ast.File, src []byte, settings Settings) ([]textEdit, []Diagnostic) {
+			return fixDeprecatedImports(fset, node, src, settings, buildContextFor(filename, node), srcDir)
+		},
+		fixFactoredImports,
+		fixImportGroups,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fixed, diagnostics, nil
+}
+
+// CheckFiles checks the files concurrently and returns the problems of all of them, sorted
+// with SortDiagnostics so that the result doesn't depend on scheduling. Files that can't be
+// checked don't stop the run, each is reported as a FileError in the returned Errors, in the
+// order of files.
+func (c *Checker) CheckFiles(files []string) ([]Diagnostic, error) {
+	type result struct {
+		diagnostics []Diagnostic
+		err         error
+	}
+	results := make([]result, len(files))
+	workers := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i, file := range files {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-workers }()
+			results[i].diagnostics, results[i].err = c.Check(file)
+		}()
+	}
+	wg.Wait()
+
+	var diagnostics []Diagnostic
+	var errs []error
+	for i, result := range results {
+		if result.err != nil {
+			errs = append(errs, &FileError{Path: files[i], Err: result.err})
+			continue
+		}
+		diagnostics = append(diagnostics, result.diagnostics...)
+	}
+	SortDiagnostics(diagnostics)
+	return diagnostics, joinErrors(errs)
+}
+
+// Walk checks every Go file below root, skipping the directories the go tool ignores, and
+// calls fn with the path and Check results of each file. An error returned by fn stops the walk
+// and is returned by Walk.
+func (c *Checker) Walk(root string, fn func(path string, diagnostics []Diagnostic, err error) error) error {
+	files, err := FindGoFiles(root, nil)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		diagnostics, err := c.Check(file)
+		if err := fn(file, diagnostics, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
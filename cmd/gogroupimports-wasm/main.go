@@ -0,0 +1,107 @@
+//go:build js && wasm
+
+// Command gogroupimports-wasm exposes the checker to JavaScript, so that playgrounds and code
+// review tools can apply the grouping rules in the browser. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o gogroupimports.wasm ./cmd/gogroupimports-wasm
+//
+// and load it with the wasm_exec.js of the same Go release. It defines a global gogroupimports
+// object with two functions taking the file name, the Go source and the settings, an object
+// with the keys of the config file:
+//
+//	gogroupimports.check(filename, source, settings)   // {diagnostics} or {error}
+//	gogroupimports.format(filename, source, settings)  // {source, diagnostics} or {error}
+//
+// There is no file system in the browser, so selfModule must be set and settings reading
+// go.mod files, running go list or fetching vanity imports don't apply. Standard library
+// packages are recognized by their first path element lacking a dot.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"syscall/js"
+
+	"github.com/hsivakum/gogroupimports"
+)
+
+// checkers caches the checkers by their settings, encoded as JSON
+var checkers = make(map[string]*gogroupimports.Checker)
+
+func main() {
+	js.Global().Set("gogroupimports", js.ValueOf(map[string]interface{}{
+		"check":  js.FuncOf(func(this js.Value, args []js.Value) interface{} { return call(args, false) }),
+		"format": js.FuncOf(func(this js.Value, args []js.Value) interface{} { return call(args, true) }),
+	}))
+	select {}
+}
+
+// call checks, or fixes when fix is set, the source passed in args and returns the result as a
+// JavaScript object
+func call(args []js.Value, fix bool) interface{} {
+	result := make(map[string]interface{})
+	if err := run(args, fix, result); err != nil {
+		result = map[string]interface{}{"error": err.Error()}
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return js.ValueOf(map[string]interface{}{"error": err.Error()})
+	}
+	return js.Global().Get("JSON").Call("parse", string(encoded))
+}
+
+// run fills result with the diagnostics and, when fix is set, the fixed source
+func run(args []js.Value, fix bool, result map[string]interface{}) error {
+	if len(args) < 2 || args[0].Type() != js.TypeString || args[1].Type() != js.TypeString {
+		return fmt.Errorf("expected the file name, the source and optionally the settings")
+	}
+	filename, src := args[0].String(), []byte(args[1].String())
+	settings := "{}"
+	if len(args) > 2 && args[2].Truthy() {
+		settings = js.Global().Get("JSON").Call("stringify", args[2]).String()
+	}
+	checker, err := checkerFor(settings)
+	if err != nil {
+		return err
+	}
+
+	var diagnostics []gogroupimports.Diagnostic
+	if fix {
+		var fixed []byte
+		if fixed, diagnostics, err = checker.FixSource(context.Background(), filename, src); err != nil {
+			return err
+		}
+		result["source"] = string(fixed)
+	} else if diagnostics, err = checker.CheckSource(context.Background(), filename, src); err != nil {
+		return err
+	}
+	if diagnostics == nil {
+		diagnostics = []gogroupimports.Diagnostic{}
+	}
+	result["diagnostics"] = diagnostics
+	return nil
+}
+
+// checkerFor returns the checker for the settings encoded as JSON
+func checkerFor(settings string) (*gogroupimports.Checker, error) {
+	if checker, ok := checkers[settings]; ok {
+		return checker, nil
+	}
+	var metaData map[string]interface{}
+	if err := json.Unmarshal([]byte(settings), &metaData); err != nil {
+		return nil, fmt.Errorf("invalid settings: %w", err)
+	}
+	parsed, err := gogroupimports.ParseSettings(metaData)
+	if err != nil {
+		return nil, fmt.Errorf("invalid settings: %w", err)
+	}
+	// There is nowhere to cache lookups
+	parsed.DisableCache = true
+	checker, err := gogroupimports.NewChecker(parsed)
+	if err != nil {
+		return nil, err
+	}
+	checkers[settings] = checker
+	return checker, nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hsivakum/gogroupimports"
+)
+
+// tokenAuth lets requests with one of the bearer tokens of a tokens file through. Each line of
+// the file holds the name of a client, its token and optionally how many requests per minute
+// it may send, overriding the default; # starts a comment:
+//
+//	ci-bot      3f9c0e...  600
+//	docs-site   a71b2d...
+type tokenAuth struct {
+	clients map[[sha256.Size]byte]*tokenClient // By hashed token, so lookups don't leak its bytes
+}
+
+// tokenClient is a client allowed by a tokens file
+type tokenClient struct {
+	name    string
+	limiter *rateLimiter // nil when unlimited
+}
+
+// loadTokens reads the tokens file at path, limiting clients without their own limit to
+// perMinute requests, or not at all when it is 0
+func loadTokens(path string, perMinute int) (*tokenAuth, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	auth := &tokenAuth{clients: make(map[[sha256.Size]byte]*tokenClient)}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 3 || len(fields) < 2 {
+			return nil, fmt.Errorf("%s:%d: want a name, a token and optionally requests per minute", path, line)
+		}
+		limit := perMinute
+		if len(fields) == 3 {
+			if limit, err = strconv.Atoi(fields[2]); err != nil || limit < 0 {
+				return nil, fmt.Errorf("%s:%d: invalid requests per minute %q", path, line, fields[2])
+			}
+		}
+		hash := sha256.Sum256([]byte(fields[1]))
+		if _, ok := auth.clients[hash]; ok {
+			return nil, fmt.Errorf("%s:%d: token of %s used before", path, line, fields[0])
+		}
+		client := &tokenClient{name: fields[0]}
+		if limit > 0 {
+			client.limiter = newRateLimiter(limit)
+		}
+		auth.clients[hash] = client
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(auth.clients) == 0 {
+		return nil, fmt.Errorf("%s: no tokens", path)
+	}
+	return auth, nil
+}
+
+// wrap answers requests without a known bearer token with 401, and those of clients over
+// their limit with 429, before passing them to next
+func (auth *tokenAuth) wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		client := auth.clients[sha256.Sum256([]byte(strings.TrimSpace(token)))]
+		if !ok || client == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gogroupimports"`)
+			writeJSON(w, http.StatusUnauthorized, serveResponse{Diagnostics: []gogroupimports.Diagnostic{}, Error: "missing or unknown bearer token"})
+			verbosef(1, "%s from %s: unknown token", r.URL.Path, clientAddress(r))
+			return
+		}
+		if client.limiter != nil && !client.limiter.allow("") {
+			w.Header().Set("Retry-After", "60")
+			writeJSON(w, http.StatusTooManyRequests, serveResponse{Diagnostics: []gogroupimports.Diagnostic{}, Error: "too many requests, try again later"})
+			verbosef(1, "%s from %s: rate limited", r.URL.Path, client.name)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// tlsConfig returns the TLS config requiring client certificates signed by one of the CAs in
+// the PEM file clientCA, for mutual TLS, or nil when clientCA is empty
+func tlsConfig(clientCA string) (*tls.Config, error) {
+	if clientCA == "" {
+		return nil, nil
+	}
+	pem, err := os.ReadFile(clientCA)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New(clientCA + ": no PEM certificates")
+	}
+	return &tls.Config{ClientCAs: pool, ClientAuth: tls.RequireAndVerifyClientCert, MinVersion: tls.VersionTLS12}, nil
+}
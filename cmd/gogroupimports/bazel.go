@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// bazelWorkspaceFiles mark the root of a Bazel workspace
+var bazelWorkspaceFiles = []string{"MODULE.bazel", "WORKSPACE", "WORKSPACE.bazel"}
+
+// bazelBuildFiles may carry Gazelle directives
+var bazelBuildFiles = []string{"BUILD.bazel", "BUILD"}
+
+// gazellePrefix is the Gazelle directive naming the import path of a directory
+const gazellePrefix = "# gazelle:prefix "
+
+// bazelProject returns the import path prefix of dir in a Bazel workspace without go.mod files,
+// as the "gazelle" and "bazelPrefixes" settings of metaData ask, or "" when they are unset or
+// name none. With "gazelle": true it is the prefix of the closest gazelle:prefix directive in the
+// BUILD files of dir and its parents up to the workspace root. "bazelPrefixes" names a file,
+// relative to the workspace root, mapping workspace directories to their prefixes, one per line:
+//
+//	.              example.com/monorepo
+//	third_party/x  github.com/x/x
+//
+// The deepest directory containing dir wins. It takes precedence over Gazelle directives.
+func bazelProject(dir string, metaData map[string]interface{}) (string, error) {
+	gazelle, ok := metaData["gazelle"].(bool)
+	if _, set := metaData["gazelle"]; set && !ok {
+		return "", errors.New("invalid config: gazelle must be true or false")
+	}
+	prefixes, ok := metaData["bazelPrefixes"].(string)
+	if _, set := metaData["bazelPrefixes"]; set && !ok {
+		return "", errors.New("invalid config: bazelPrefixes must be the path of a file")
+	}
+	if !gazelle && prefixes == "" {
+		return "", nil
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	root := bazelWorkspaceRoot(dir)
+	if root == "" {
+		return "", fmt.Errorf("gazelle or bazelPrefixes is set but %s isn't in a Bazel workspace, no %s found", dir, strings.Join(bazelWorkspaceFiles, " or "))
+	}
+	if prefixes != "" {
+		if !filepath.IsAbs(prefixes) {
+			prefixes = filepath.Join(root, prefixes)
+		}
+		prefix, err := mappedPrefix(prefixes, root, dir)
+		if err != nil || prefix != "" {
+			return prefix, err
+		}
+	}
+	if gazelle {
+		return gazelleDirective(root, dir)
+	}
+	return "", nil
+}
+
+// bazelWorkspaceRoot returns the closest directory from dir up containing a workspace file
+func bazelWorkspaceRoot(dir string) string {
+	for {
+		for _, name := range bazelWorkspaceFiles {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				return dir
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// mappedPrefix returns the prefix the mapping file at path assigns to the deepest directory
+// of the workspace at root containing dir
+func mappedPrefix(path, root, dir string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return "", err
+	}
+	rel = filepath.ToSlash(rel)
+	best, bestDepth := "", -1
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return "", fmt.Errorf("%s:%d: want a workspace directory and its import path prefix", path, line)
+		}
+		mapped := filepath.ToSlash(filepath.Clean(fields[0]))
+		if mapped != "." && rel != mapped && !strings.HasPrefix(rel, mapped+"/") {
+			continue
+		}
+		depth := 0
+		if mapped != "." {
+			depth = strings.Count(mapped, "/") + 1
+		}
+		if depth > bestDepth {
+			best, bestDepth = fields[1], depth
+		}
+	}
+	return best, scanner.Err()
+}
+
+// gazelleDirective returns the prefix of the closest gazelle:prefix directive in the BUILD
+// files from dir up to root
+func gazelleDirective(root, dir string) (string, error) {
+	for {
+		for _, name := range bazelBuildFiles {
+			prefix, err := readGazellePrefix(filepath.Join(dir, name))
+			if err != nil || prefix != "" {
+				return prefix, err
+			}
+		}
+		if dir == root {
+			return "", nil
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// readGazellePrefix returns the gazelle:prefix directive of the BUILD file at path, "" when
+// there is none or no such file
+func readGazellePrefix(path string) (string, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if prefix, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), gazellePrefix); ok {
+			return strings.TrimSpace(prefix), nil
+		}
+	}
+	return "", scanner.Err()
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/hsivakum/gogroupimports"
+)
+
+// Bitbucket Code Insights limits
+const (
+	bitbucketAnnotationsPerRequest = 100
+	bitbucketMaxAnnotations        = 1000
+	bitbucketMaxSummary            = 450
+)
+
+// bitbucketReport identifies the Code Insights report of the bot on a commit
+const bitbucketReport = "gogroupimports"
+
+// bitbucketSeverities maps diagnostic severities to those of annotations
+var bitbucketSeverities = map[string]string{
+	gogroupimports.SeverityError:   "HIGH",
+	gogroupimports.SeverityWarning: "MEDIUM",
+	gogroupimports.SeverityInfo:    "LOW",
+}
+
+// bitbucketPullRequest is a pull request the bot reports on
+type bitbucketPullRequest struct {
+	client *botClient
+	repo   string // workspace/name
+	id     int
+	head   string // Commit the files are read at and the report is attached to
+}
+
+// newBitbucketClient returns a client for the Bitbucket Cloud API at api, with the access token
+// in BITBUCKET_TOKEN
+func newBitbucketClient(api string) *botClient {
+	header := http.Header{}
+	if token := os.Getenv("BITBUCKET_TOKEN"); token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	return &botClient{api: strings.TrimSuffix(cmp.Or(api, "https://api.bitbucket.org/2.0"), "/"), header: header}
+}
+
+// bitbucketPullRequestFor returns the pull request id of repo at its current head
+func bitbucketPullRequestFor(ctx context.Context, client *botClient, repo string, id int) (*bitbucketPullRequest, error) {
+	if repo == "" || id == 0 {
+		return nil, errors.New("name the pull request with -repo and -pr, or run in a pull request pipeline")
+	}
+	var pr struct {
+		Source struct {
+			Commit struct {
+				Hash string `json:"hash"`
+			} `json:"commit"`
+		} `json:"source"`
+	}
+	if err := client.do(ctx, http.MethodGet, fmt.Sprintf("/repositories/%s/pullrequests/%d", repo, id), nil, &pr); err != nil {
+		return nil, err
+	}
+	return &bitbucketPullRequest{client: client, repo: repo, id: id, head: pr.Source.Commit.Hash}, nil
+}
+
+func (pr *bitbucketPullRequest) String() string {
+	return fmt.Sprintf("%s#%d", pr.repo, pr.id)
+}
+
+// changedFiles returns the Go files the pull request adds or modifies, at its head
+func (pr *bitbucketPullRequest) changedFiles(ctx context.Context) ([]botFile, error) {
+	var diff []byte
+	if err := pr.client.do(ctx, http.MethodGet, fmt.Sprintf("/repositories/%s/pullrequests/%d/diff", pr.repo, pr.id), nil, &diff); err != nil {
+		return nil, err
+	}
+	var files []botFile
+	for _, patch := range splitDiff(string(diff)) {
+		if !strings.HasSuffix(patch.path, ".go") {
+			continue
+		}
+		var src []byte
+		if err := pr.client.do(ctx, http.MethodGet, fmt.Sprintf("/repositories/%s/src/%s/%s", pr.repo, pr.head, escapePath(patch.path)), nil, &src); err != nil {
+			return nil, err
+		}
+		hunks, old := patchHunks(patch.hunks)
+		files = append(files, botFile{path: patch.path, src: src, hunks: hunks, old: old})
+	}
+	return files, nil
+}
+
+// post replaces the Code Insights report on the head commit, annotating every diagnostic.
+// Bitbucket shows annotations on any line of the changed files, so there are no suggestions
+// and nothing is left for the summary.
+func (pr *bitbucketPullRequest) post(ctx context.Context, review *botReview) error {
+	result := "PASSED"
+	if review.errors {
+		result = "FAILED"
+	}
+	report := map[string]interface{}{
+		"title":       "gogroupimports",
+		"details":     fmt.Sprintf("gogroupimports found %d import problems in the changed files.", len(review.diagnostics)),
+		"report_type": "BUG",
+		"reporter":    "gogroupimports",
+		"result":      result,
+	}
+	path := fmt.Sprintf("/repositories/%s/commit/%s/reports/%s", pr.repo, pr.head, bitbucketReport)
+	if err := pr.client.do(ctx, http.MethodPut, path, report, nil); err != nil {
+		return err
+	}
+
+	var annotations []map[string]interface{}
+	for i, diagnostic := range review.diagnostics {
+		if i == bitbucketMaxAnnotations {
+			break
+		}
+		summary := diagnostic.Message
+		if len(summary) > bitbucketMaxSummary {
+			summary = summary[:bitbucketMaxSummary-3] + "..."
+		}
+		annotations = append(annotations, map[string]interface{}{
+			"external_id":     fmt.Sprintf("%s-%d", bitbucketReport, i+1),
+			"annotation_type": "CODE_SMELL",
+			"summary":         summary,
+			"details":         fmt.Sprintf("%s (%s)", diagnostic.Message, diagnostic.Rule),
+			"path":            diagnostic.Path,
+			"line":            diagnostic.Line,
+			"severity":        bitbucketSeverities[diagnostic.Severity],
+		})
+	}
+	for len(annotations) > 0 {
+		batch := annotations[:min(len(annotations), bitbucketAnnotationsPerRequest)]
+		annotations = annotations[len(batch):]
+		if err := pr.client.do(ctx, http.MethodPost, path+"/annotations", batch, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filePatch holds the hunks of a file in a unified diff
+type filePatch struct {
+	path  string // New path of the file
+	hunks string
+}
+
+// splitDiff splits a git diff into the hunks of the files it adds or modifies
+func splitDiff(diff string) []filePatch {
+	var patches []filePatch
+	var current *filePatch
+	var hunks strings.Builder
+	flush := func() {
+		if current != nil && current.path != "" {
+			current.hunks = hunks.String()
+			patches = append(patches, *current)
+		}
+		hunks.Reset()
+	}
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			current = &filePatch{}
+		case current == nil:
+		case hunks.Len() == 0 && strings.HasPrefix(line, "+++ "):
+			// +++ /dev/null for deleted files leaves the path empty
+			current.path, _ = strings.CutPrefix(strings.TrimRight(line[4:], "\r\n"), "b/")
+			if current.path == "/dev/null" {
+				current.path = ""
+			}
+		case strings.HasPrefix(line, "@@") || hunks.Len() > 0:
+			hunks.WriteString(line)
+		}
+	}
+	flush()
+	return patches
+}
@@ -0,0 +1,480 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hsivakum/gogroupimports"
+)
+
+// maxSummaryDiagnostics bounds the diagnostics listed in the summary of a review
+const maxSummaryDiagnostics = 50
+
+// botTimeout bounds every call to the API of a code host
+const botTimeout = 30 * time.Second
+
+// botPullRequest is a pull request on a code host that the bot reviews
+type botPullRequest interface {
+	fmt.Stringer
+	// changedFiles returns the Go files the pull request adds or modifies, at its head
+	changedFiles(ctx context.Context) ([]botFile, error)
+	// post submits the review
+	post(ctx context.Context, review *botReview) error
+}
+
+// bot reviews the Go files changed by a pull request and posts the problems as review
+// comments, suggesting the fix where it only touches lines of the diff. The pull request is
+// named with flags, the CI environment or a webhook payload, or the bot receives webhooks itself
+// with -listen.
+func bot(args []string) int {
+	flags := flag.NewFlagSet("gogroupimports bot", flag.ExitOnError)
+	configPath := configFlag(flags)
+	github := flags.Bool("github", false, "review GitHub pull requests, with the token in GITHUB_TOKEN")
+	gitlab := flags.Bool("gitlab", false, "review GitLab merge requests, with the token in GITLAB_TOKEN")
+	bitbucket := flags.Bool("bitbucket", false, "report on Bitbucket pull requests with Code Insights, with the token in BITBUCKET_TOKEN")
+	repo := flags.String("repo", "", "repository of the pull request, as owner/name (default from the CI environment)")
+	number := flags.Int("pr", 0, "number of the pull request to review (default from the CI environment)")
+	eventPath := flags.String("event", "", "GitHub pull_request webhook payload naming the pull request (default $GITHUB_EVENT_PATH)")
+	listen := flags.String("listen", "", "address to receive GitHub or GitLab webhooks on, with the secret in GITHUB_WEBHOOK_SECRET or GITLAB_WEBHOOK_SECRET")
+	api := flags.String("api", "", "API URL of a self-hosted code host (default from the CI environment or the public one)")
+	dryRun := flags.Bool("dry-run", false, "print the review instead of posting it")
+	verbose := flags.Bool("v", false, "log every review")
+	_ = flags.Parse(args)
+
+	if *verbose {
+		verbosity = 1
+	}
+	hosts := 0
+	for _, selected := range []bool{*github, *gitlab, *bitbucket} {
+		if selected {
+			hosts++
+		}
+	}
+	if hosts != 1 {
+		log.Print("choose one code host: -github, -gitlab or -bitbucket")
+		return 2
+	}
+	checkers, err := newCheckers(*configPath)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	ctx := context.Background()
+
+	var pr botPullRequest
+	switch {
+	case *github:
+		client := newGitHubClient(cmp.Or(*api, os.Getenv("GITHUB_API_URL")))
+		if *listen != "" {
+			return listenForWebhooks(*listen, "GITHUB_WEBHOOK_SECRET", func(secret string, review func(botPullRequest)) http.Handler {
+				return githubWebhook(client, secret, review)
+			}, checkers, *dryRun)
+		}
+		pr, err = githubPullRequestFor(ctx, client, cmp.Or(*repo, os.Getenv("GITHUB_REPOSITORY")), *number, cmp.Or(*eventPath, os.Getenv("GITHUB_EVENT_PATH")))
+	case *gitlab:
+		client := newGitLabClient(cmp.Or(*api, os.Getenv("CI_API_V4_URL")))
+		if *listen != "" {
+			return listenForWebhooks(*listen, "GITLAB_WEBHOOK_SECRET", func(secret string, review func(botPullRequest)) http.Handler {
+				return gitlabWebhook(client, secret, review)
+			}, checkers, *dryRun)
+		}
+		pr, err = gitlabMergeRequestFor(ctx, client, cmp.Or(*repo, os.Getenv("CI_PROJECT_PATH")), envNumber(*number, "CI_MERGE_REQUEST_IID"))
+	case *bitbucket:
+		if *listen != "" {
+			log.Print("-listen is only supported for GitHub and GitLab, run the bot from Bitbucket Pipelines instead")
+			return 2
+		}
+		client := newBitbucketClient(*api)
+		pr, err = bitbucketPullRequestFor(ctx, client, cmp.Or(*repo, os.Getenv("BITBUCKET_REPO_FULL_NAME")), envNumber(*number, "BITBUCKET_PR_ID"))
+	}
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	return reviewPullRequest(ctx, checkers, pr, *dryRun)
+}
+
+// envNumber returns number, or the one in the environment variable name when number is 0
+func envNumber(number int, name string) int {
+	if number == 0 {
+		number, _ = strconv.Atoi(os.Getenv(name))
+	}
+	return number
+}
+
+// reviewPullRequest reviews pr and posts the review, or prints it with dryRun. Like the check
+// it exits with 1 when errors were found.
+func reviewPullRequest(ctx context.Context, checkers *checkerTree, pr botPullRequest, dryRun bool) int {
+	files, err := pr.changedFiles(ctx)
+	if err != nil {
+		log.Printf("%s: %v", pr, err)
+		return 2
+	}
+	review, err := reviewFiles(ctx, checkers, files)
+	if err != nil {
+		log.Printf("%s: %v", pr, err)
+		return 2
+	}
+	switch {
+	case len(review.diagnostics) == 0:
+		verbosef(1, "%s: no problems in %d changed files", pr, len(files))
+		return 0
+	case dryRun:
+		printReview(os.Stdout, review)
+	default:
+		if err := pr.post(ctx, review); err != nil {
+			log.Printf("%s: %v", pr, err)
+			return 2
+		}
+		verbosef(1, "%s: posted %d comments", pr, len(review.comments))
+	}
+	if review.errors {
+		return 1
+	}
+	return 0
+}
+
+// printReview writes the comments and summary of review to w
+func printReview(w io.Writer, review *botReview) {
+	for _, comment := range review.comments {
+		lines := strconv.Itoa(comment.start)
+		if comment.end != comment.start {
+			lines += "-" + strconv.Itoa(comment.end)
+		}
+		fmt.Fprintf(w, "%s:%s:\n%s\n\n", comment.path, lines, comment.body)
+	}
+	fmt.Fprintln(w, review.summary())
+}
+
+// listenForWebhooks receives webhooks on addr until interrupted, with the handler created by
+// webhook for the secret in the environment variable secretEnv. The pull requests they name
+// are reviewed one at a time, so that a burst of pushes doesn't flood the API of the code host
+// and the reviews printed with -dry-run don't interleave.
+func listenForWebhooks(addr, secretEnv string, webhook func(secret string, review func(botPullRequest)) http.Handler, checkers *checkerTree, dryRun bool) int {
+	secret := os.Getenv(secretEnv)
+	if secret == "" {
+		log.Printf("-listen requires the webhook secret in %s", secretEnv)
+		return 2
+	}
+	var mu sync.Mutex
+	handler := webhook(secret, func(pr botPullRequest) {
+		mu.Lock()
+		defer mu.Unlock()
+		reviewPullRequest(context.Background(), checkers, pr, dryRun)
+	})
+
+	httpServer := &http.Server{Addr: addr, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdown)
+	}()
+
+	verbosef(0, "receiving webhooks on %s", addr)
+	if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Print(err)
+		return 2
+	}
+	return 0
+}
+
+// botClient calls the REST API of a code host
+type botClient struct {
+	api       string      // Base URL
+	header    http.Header // Sent with every request, like the token
+	rawAccept string      // Accept header asking for file contents rather than JSON, if any
+}
+
+// do calls the API at path and decodes the JSON answer into out unless it is nil. When out is
+// a *[]byte the answer is stored as is, for file contents and diffs.
+func (c *botClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, botTimeout)
+	defer cancel()
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.api+path, reader)
+	if err != nil {
+		return err
+	}
+	for name, values := range c.header {
+		req.Header[name] = values
+	}
+	raw, isRaw := out.(*[]byte)
+	if isRaw && c.rawAccept != "" {
+		req.Header.Set("Accept", c.rawAccept)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		if message := apiErrorMessage(content); message != "" {
+			return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, message)
+		}
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	switch {
+	case isRaw:
+		*raw = content
+		return nil
+	case out == nil:
+		return nil
+	}
+	return json.Unmarshal(content, out)
+}
+
+// apiErrorMessage returns the message of an API error answer, code hosts nest it differently
+func apiErrorMessage(content []byte) string {
+	var answer struct {
+		Message interface{} `json:"message"`
+		Error   interface{} `json:"error"`
+	}
+	if json.Unmarshal(content, &answer) != nil {
+		return ""
+	}
+	for _, value := range []interface{}{answer.Message, answer.Error} {
+		switch value := value.(type) {
+		case string:
+			return value
+		case map[string]interface{}:
+			if message, ok := value["message"].(string); ok {
+				return message
+			}
+		case nil:
+		default:
+			encoded, _ := json.Marshal(value)
+			return string(encoded)
+		}
+	}
+	return ""
+}
+
+// botFile is a Go file changed by a pull request, at its head revision
+type botFile struct {
+	path  string      // Relative to the repository root, slash separated
+	src   []byte      // Content at the head revision
+	hunks map[int]int // Hunk of every line the diff shows on the new side, numbered from 1
+	old   map[int]int // Line before the change of the unchanged lines the diff shows, by new line
+}
+
+// botComment is an inline review comment on the lines start to end of a file
+type botComment struct {
+	path       string
+	start, end int // Equal for comments on a single line
+	oldStart   int // Line of start before the change when the diff shows it unchanged, else 0
+	body       string
+}
+
+// botReview is what the bot posts on a pull request: inline comments on the lines of the diff
+// and the diagnostics on other lines, which code review only takes in the summary
+type botReview struct {
+	comments    []botComment
+	outside     []gogroupimports.Diagnostic
+	diagnostics []gogroupimports.Diagnostic // All of them, for code hosts annotating any line
+	errors      bool
+}
+
+// reviewFiles checks the changed files. When a fix only touches lines of a single hunk of the
+// diff, the diagnostics on them are posted as one comment suggesting the fixed lines, so the
+// author can apply it from the review.
+func reviewFiles(ctx context.Context, checkers *checkerTree, files []botFile) (*botReview, error) {
+	review := &botReview{}
+	for _, file := range files {
+		checker, err := checkers.forFile(file.path)
+		if err != nil {
+			return nil, err
+		}
+		diagnostics, err := checker.CheckSource(ctx, file.path, file.src)
+		if err != nil {
+			// Files that don't compile are for the build to report
+			verbosef(1, "skipping %s: %v", file.path, err)
+			continue
+		}
+		if len(diagnostics) == 0 {
+			continue
+		}
+		review.diagnostics = append(review.diagnostics, diagnostics...)
+		review.errors = review.errors || checker.Fails(diagnostics)
+
+		var suggestion *botComment
+		var replacement []string
+		if fixed, _, err := checker.FixSource(ctx, file.path, file.src); err == nil && !bytes.Equal(fixed, file.src) {
+			start, end, fixedLines := changedLines(splitLines(file.src), splitLines(fixed))
+			if hunk := file.hunks[start]; hunk != 0 && file.hunks[end] == hunk {
+				suggestion = &botComment{path: file.path, start: start, end: end, oldStart: file.old[start]}
+				replacement = fixedLines
+			}
+		}
+
+		var suggested []string
+		byLine := make(map[int][]string)
+		var lines []int
+		for _, diagnostic := range diagnostics {
+			message := commentLine(diagnostic)
+			switch {
+			case suggestion != nil && diagnostic.Line >= suggestion.start && diagnostic.Line <= suggestion.end:
+				suggested = append(suggested, message)
+			case file.hunks[diagnostic.Line] != 0:
+				if byLine[diagnostic.Line] == nil {
+					lines = append(lines, diagnostic.Line)
+				}
+				byLine[diagnostic.Line] = append(byLine[diagnostic.Line], message)
+			default:
+				review.outside = append(review.outside, diagnostic)
+			}
+		}
+		if suggestion != nil {
+			suggestion.body = suggestionBody(suggested, replacement)
+			review.comments = append(review.comments, *suggestion)
+		}
+		for _, line := range lines {
+			review.comments = append(review.comments, botComment{path: file.path, start: line, end: line, oldStart: file.old[line], body: strings.Join(byLine[line], "\n")})
+		}
+	}
+	sortComments(review.comments)
+	return review, nil
+}
+
+// suggestionBody returns a comment listing messages and suggesting replacement for its lines
+func suggestionBody(messages, replacement []string) string {
+	if len(messages) == 0 {
+		messages = []string{"Imports aren't grouped and sorted as configured."}
+	}
+	body := strings.Join(messages, "\n") + "\n\n```suggestion\n"
+	for _, line := range replacement {
+		body += line + "\n"
+	}
+	return body + "```"
+}
+
+// summary returns the body of the review
+func (review *botReview) summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "gogroupimports found %d import problems in the changed files.", len(review.diagnostics))
+	if len(review.outside) > 0 {
+		b.WriteString("\n\nOn lines outside the diff:\n")
+		for i, diagnostic := range review.outside {
+			if i == maxSummaryDiagnostics {
+				fmt.Fprintf(&b, "- and %d more\n", len(review.outside)-i)
+				break
+			}
+			fmt.Fprintf(&b, "- `%s`\n", diagnostic)
+		}
+	}
+	return b.String()
+}
+
+// commentLine formats a diagnostic for a review comment
+func commentLine(diagnostic gogroupimports.Diagnostic) string {
+	if diagnostic.Severity != gogroupimports.SeverityError {
+		return fmt.Sprintf("**%s** (%s): %s", diagnostic.Rule, diagnostic.Severity, diagnostic.Message)
+	}
+	return fmt.Sprintf("**%s**: %s", diagnostic.Rule, diagnostic.Message)
+}
+
+// changedLines returns the 1-based range of old lines replaced in new and what replaces them.
+// A pure insertion includes the line before it, so that the range is never empty.
+func changedLines(old, new []string) (start, end int, replacement []string) {
+	prefix := 0
+	for prefix < len(old) && prefix < len(new) && old[prefix] == new[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(new)-prefix && old[len(old)-1-suffix] == new[len(new)-1-suffix] {
+		suffix++
+	}
+	start, end = prefix+1, len(old)-suffix
+	replacement = new[prefix : len(new)-suffix]
+	if end < start && prefix > 0 {
+		start--
+		replacement = append([]string{old[prefix-1]}, replacement...)
+	}
+	return start, end, replacement
+}
+
+// splitLines splits src into lines without their line breaks
+func splitLines(src []byte) []string {
+	return strings.Split(strings.TrimSuffix(string(src), "\n"), "\n")
+}
+
+// patchHunks returns the hunk of every line a unified diff shows on the new side, and the
+// line before the change of those it shows unchanged
+func patchHunks(patch string) (hunks, old map[int]int) {
+	hunks, old = make(map[int]int), make(map[int]int)
+	hunk, oldLine, line := 0, 0, 0
+	for _, text := range strings.Split(strings.TrimSuffix(patch, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(text, "@@"):
+			// @@ -old,count +new,count @@
+			fields := strings.Fields(text)
+			if len(fields) < 3 {
+				continue
+			}
+			oldStart, _, _ := strings.Cut(strings.TrimPrefix(fields[1], "-"), ",")
+			newStart, _, _ := strings.Cut(strings.TrimPrefix(fields[2], "+"), ",")
+			o, err1 := strconv.Atoi(oldStart)
+			n, err2 := strconv.Atoi(newStart)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			hunk++
+			oldLine, line = o, n
+		case hunk == 0, strings.HasPrefix(text, `\`):
+		case strings.HasPrefix(text, "-"):
+			oldLine++
+		case strings.HasPrefix(text, "+"):
+			hunks[line] = hunk
+			line++
+		default:
+			hunks[line] = hunk
+			old[line] = oldLine
+			oldLine++
+			line++
+		}
+	}
+	return hunks, old
+}
+
+// sortComments orders comments by file and line, as code review shows them
+func sortComments(comments []botComment) {
+	sort.SliceStable(comments, func(i, j int) bool {
+		if comments[i].path != comments[j].path {
+			return comments[i].path < comments[j].path
+		}
+		return comments[i].start < comments[j].start
+	})
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// completionFlag describes a flag for shell completion
+type completionFlag struct {
+	name   string
+	value  bool     // Takes a value, bool flags don't
+	file   bool     // The value is a path
+	values []string // Possible values, nil for free text
+}
+
+// completionCommand describes a command for shell completion, the default check command has no name
+type completionCommand struct {
+	name  string
+	flags []completionFlag
+	args  []string // Possible first arguments, paths are completed otherwise
+}
+
+// configFlags are the flags registered by configFlag
+var configFlags = []completionFlag{
+	{name: "config", value: true, file: true},
+	{name: "require-signed-config"},
+}
+
+// completionCommands lists the commands and their flags, keep it in sync with the flag sets
+var completionCommands = []completionCommand{
+	{flags: append([]completionFlag{
+		{name: "index", value: true, file: true},
+		{name: "goroot", value: true, file: true},
+		{name: "format", value: true, values: []string{formatText, formatJSON, formatTemplate}},
+		{name: "template", value: true},
+		{name: "q"},
+		{name: "v"},
+		{name: "vv"},
+		{name: "progress"},
+		{name: "w"},
+		{name: "backup", value: true},
+		{name: "this-module-only"},
+		{name: "gitignore"},
+		{name: "templates"},
+		{name: "files-from", value: true, file: true},
+		{name: "0"},
+		{name: "staged"},
+		{name: "report-unclassified"},
+		{name: "fixable-only"},
+		{name: "unfixable-only"},
+		{name: "shard", value: true},
+		{name: "j", value: true},
+		{name: "fail-on", value: true, values: []string{"error", "error,warning", "error,warning,info"}},
+		{name: "timeout", value: true},
+		{name: "log-format", value: true, values: []string{logFormatText, logFormatJSON}},
+		{name: "cpuprofile", value: true, file: true},
+		{name: "memprofile", value: true, file: true},
+		{name: "trace", value: true, file: true},
+		{name: "watch"},
+		{name: "watch-debounce", value: true},
+		{name: "watch-ignore", value: true},
+	}, configFlags...)},
+	{name: "rename-module", flags: append([]completionFlag{{name: "backup", value: true}}, configFlags...)},
+	{name: "summary", flags: append([]completionFlag{{name: "by", value: true, values: []string{"file", "package"}}}, configFlags...)},
+	{name: "inventory", flags: append([]completionFlag{{name: "format", value: true, values: []string{formatText, formatJSON}}}, configFlags...)},
+	{name: "index", flags: append([]completionFlag{{name: "o", value: true, file: true}}, configFlags...)},
+	{name: "init", flags: []completionFlag{{name: "o", value: true, file: true}, {name: "f"}}},
+	{name: "migrate", flags: []completionFlag{{name: "o", value: true, file: true}}, args: []string{"gci", "reviser"}},
+	{name: "completion", args: completionShells},
+	{name: "doctor", flags: append([]completionFlag{{name: "goroot", value: true, file: true}}, configFlags...)},
+	{name: "bot", flags: append([]completionFlag{
+		{name: "github"},
+		{name: "gitlab"},
+		{name: "bitbucket"},
+		{name: "repo", value: true},
+		{name: "pr", value: true},
+		{name: "event", value: true, file: true},
+		{name: "listen", value: true},
+		{name: "api", value: true},
+		{name: "dry-run"},
+		{name: "v"},
+	}, configFlags...)},
+	{name: "lsp", flags: append([]completionFlag{{name: "otlp-endpoint", value: true}}, configFlags...)},
+	{name: "goimports", flags: append([]completionFlag{{name: "l"}, {name: "w"}, {name: "d"}, {name: "local", value: true}, {name: "srcdir", value: true, file: true}, {name: "e"}, {name: "format-only"}}, configFlags...)},
+	{name: "merge-results", flags: append([]completionFlag{
+		{name: "format", value: true, values: []string{formatText, formatJSON, formatTemplate}},
+		{name: "template", value: true},
+		{name: "fail-on", value: true, values: []string{"error", "error,warning", "error,warning,info"}},
+	}, configFlags...)},
+	{name: "install-hooks", flags: append([]completionFlag{{name: "pre-commit"}, {name: "uninstall"}, {name: "f"}}, configFlags...)},
+	{name: "serve", flags: append([]completionFlag{
+		{name: "addr", value: true},
+		{name: "reload-interval", value: true},
+		{name: "playground-rate", value: true},
+		{name: "tokens", value: true, file: true},
+		{name: "token-rate", value: true},
+		{name: "tls-cert", value: true, file: true},
+		{name: "tls-key", value: true, file: true},
+		{name: "client-ca", value: true, file: true},
+		{name: "max-request-size", value: true},
+		{name: "client-rate", value: true},
+		{name: "max-concurrent", value: true},
+		{name: "otlp-endpoint", value: true},
+		{name: "v"},
+		{name: "log-format", value: true, values: []string{logFormatText, logFormatJSON}},
+	}, configFlags...)},
+}
+
+// completionShells are the shells completion scripts are generated for
+var completionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+// completion writes the completion script for a shell to stdout
+func completion(args []string) int {
+	flags := flag.NewFlagSet("gogroupimports completion", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: gogroupimports completion bash|zsh|fish|powershell")
+		fmt.Fprintln(flags.Output(), "\nFor bash, add to ~/.bashrc: source <(gogroupimports completion bash)")
+		fmt.Fprintln(flags.Output(), "For zsh, add to ~/.zshrc: source <(gogroupimports completion zsh)")
+		fmt.Fprintln(flags.Output(), "For fish: gogroupimports completion fish > ~/.config/fish/completions/gogroupimports.fish")
+		fmt.Fprintln(flags.Output(), "For PowerShell, add to $PROFILE: gogroupimports completion powershell | Out-String | Invoke-Expression")
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	var write func(io.Writer) error
+	switch flags.Arg(0) {
+	case "bash":
+		write = writeBashCompletion
+	case "zsh":
+		write = writeZshCompletion
+	case "fish":
+		write = writeFishCompletion
+	case "powershell":
+		write = writePowerShellCompletion
+	default:
+		log.Printf("unknown shell %q, want one of %s", flags.Arg(0), strings.Join(completionShells, ", "))
+		return 2
+	}
+	if err := write(os.Stdout); err != nil {
+		log.Print(err)
+		return 2
+	}
+	return 0
+}
+
+// subcommandNames returns the names of all commands but the default one
+func subcommandNames() []string {
+	var names []string
+	for _, command := range completionCommands {
+		if command.name != "" {
+			names = append(names, command.name)
+		}
+	}
+	return names
+}
+
+// flagNames returns the flags of command with their leading dash
+func flagNames(command completionCommand) []string {
+	names := make([]string, len(command.flags))
+	for i, flag := range command.flags {
+		names[i] = "-" + flag.name
+	}
+	return names
+}
+
+// shellCompletionCase renders the bash case pattern matching a command, "" for the default one
+func shellCompletionCase(command completionCommand) string {
+	if command.name == "" {
+		return `""`
+	}
+	return command.name
+}
+
+// writeBashCompletion writes a bash completion script. It is also used by zsh through bashcompinit.
+func writeBashCompletion(w io.Writer) error {
+	var script strings.Builder
+	script.WriteString("# bash completion for gogroupimports, generated by gogroupimports completion bash\n")
+	script.WriteString("_gogroupimports() {\n")
+	script.WriteString("\tlocal cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]} cmd=\n")
+	script.WriteString("\tcase ${COMP_WORDS[1]} in\n")
+	fmt.Fprintf(&script, "\t%s) [[ $COMP_CWORD -gt 1 ]] && cmd=${COMP_WORDS[1]} ;;\n", strings.Join(subcommandNames(), "|"))
+	script.WriteString("\tesac\n\n")
+
+	// Flag values
+	script.WriteString("\tcase \"$cmd $prev\" in\n")
+	for _, command := range completionCommands {
+		for _, flag := range command.flags {
+			if !flag.value {
+				continue
+			}
+			fmt.Fprintf(&script, "\t%q)\n", command.name+" -"+flag.name)
+			switch {
+			case flag.file:
+				script.WriteString("\t\tCOMPREPLY=($(compgen -f -- \"$cur\"))\n")
+			case flag.values != nil:
+				fmt.Fprintf(&script, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(flag.values, " "))
+			default:
+				script.WriteString("\t\tCOMPREPLY=()\n")
+			}
+			script.WriteString("\t\treturn ;;\n")
+		}
+	}
+	script.WriteString("\tesac\n\n")
+
+	// Flags, then subcommands and arguments
+	script.WriteString("\tlocal words\n")
+	script.WriteString("\tcase $cmd in\n")
+	for _, command := range completionCommands {
+		fmt.Fprintf(&script, "\t%s)\n", shellCompletionCase(command))
+		words := command.args
+		if command.name == "" {
+			words = subcommandNames()
+		}
+		fmt.Fprintf(&script, "\t\t[[ $cur == -* ]] && words=%q || words=%q ;;\n", strings.Join(flagNames(command), " "), strings.Join(words, " "))
+	}
+	script.WriteString("\tesac\n")
+	script.WriteString("\tCOMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n")
+	script.WriteString("\t[[ $cur == -* ]] || COMPREPLY+=($(compgen -f -- \"$cur\"))\n")
+	script.WriteString("}\n")
+	script.WriteString("complete -o filenames -F _gogroupimports gogroupimports\n")
+	_, err := io.WriteString(w, script.String())
+	return err
+}
+
+// writeZshCompletion writes a zsh completion script wrapping the bash one
+func writeZshCompletion(w io.Writer) error {
+	if _, err := io.WriteString(w, "#compdef gogroupimports\n# zsh completion for gogroupimports, generated by gogroupimports completion zsh\nautoload -U +X bashcompinit && bashcompinit\n"); err != nil {
+		return err
+	}
+	return writeBashCompletion(w)
+}
+
+// writeFishCompletion writes a fish completion script
+func writeFishCompletion(w io.Writer) error {
+	var script strings.Builder
+	script.WriteString("# fish completion for gogroupimports, generated by gogroupimports completion fish\n")
+	subcommands := strings.Join(subcommandNames(), " ")
+	for _, command := range completionCommands {
+		condition := "__fish_seen_subcommand_from " + command.name
+		if command.name == "" {
+			condition = "not __fish_seen_subcommand_from " + subcommands
+			fmt.Fprintf(&script, "complete -c gogroupimports -n %q -a %q\n", "__fish_use_subcommand", subcommands)
+		}
+		if len(command.args) > 0 {
+			fmt.Fprintf(&script, "complete -c gogroupimports -n %q -f -a %q\n", condition, strings.Join(command.args, " "))
+		}
+		for _, flag := range command.flags {
+			line := fmt.Sprintf("complete -c gogroupimports -n %q -o %s", condition, flag.name)
+			switch {
+			case flag.file:
+				line += " -r -F"
+			case flag.values != nil:
+				line += fmt.Sprintf(" -x -a %q", strings.Join(flag.values, " "))
+			case flag.value:
+				line += " -x"
+			}
+			script.WriteString(line + "\n")
+		}
+	}
+	_, err := io.WriteString(w, script.String())
+	return err
+}
+
+// writePowerShellCompletion writes a PowerShell argument completer
+func writePowerShellCompletion(w io.Writer) error {
+	var script strings.Builder
+	script.WriteString("# PowerShell completion for gogroupimports, generated by gogroupimports completion powershell\n")
+	script.WriteString("Register-ArgumentCompleter -Native -CommandName gogroupimports -ScriptBlock {\n")
+	script.WriteString("\tparam($wordToComplete, $commandAst, $cursorPosition)\n")
+	script.WriteString("\t$words = @($commandAst.CommandElements | ForEach-Object { $_.ToString() })\n")
+	script.WriteString("\tif ($wordToComplete -ne '') { $words = $words[0..($words.Count - 2)] }\n")
+	script.WriteString("\t$cmd = ''\n")
+	fmt.Fprintf(&script, "\tif ($words.Count -gt 1 -and @(%s) -contains $words[1]) { $cmd = $words[1] }\n", powerShellList(subcommandNames()))
+	script.WriteString("\t$prev = $words[-1]\n")
+	script.WriteString("\t$candidates = switch (\"$cmd $prev\") {\n")
+	for _, command := range completionCommands {
+		for _, flag := range command.flags {
+			if flag.value && flag.values != nil {
+				fmt.Fprintf(&script, "\t\t'%s' { @(%s) }\n", command.name+" -"+flag.name, powerShellList(flag.values))
+			}
+		}
+	}
+	script.WriteString("\t\tdefault {\n")
+	script.WriteString("\t\t\tswitch ($cmd) {\n")
+	for _, command := range completionCommands {
+		words := command.args
+		if command.name == "" {
+			words = subcommandNames()
+		}
+		fmt.Fprintf(&script, "\t\t\t\t'%s' { if ($wordToComplete -like '-*') { @(%s) } else { @(%s) } }\n",
+			command.name, powerShellList(flagNames(command)), powerShellList(words))
+	}
+	script.WriteString("\t\t\t}\n")
+	script.WriteString("\t\t}\n")
+	script.WriteString("\t}\n")
+	script.WriteString("\t$candidates | Where-Object { $_ -like \"$wordToComplete*\" } | ForEach-Object {\n")
+	script.WriteString("\t\t[System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)\n")
+	script.WriteString("\t}\n")
+	script.WriteString("}\n")
+	_, err := io.WriteString(w, script.String())
+	return err
+}
+
+// powerShellList renders words as the elements of a PowerShell array
+func powerShellList(words []string) string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = "'" + word + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/mod/modfile"
+
+	"github.com/hsivakum/gogroupimports"
+)
+
+// defaultConfigFile is loaded from the current directory when no config is given
+const defaultConfigFile = ".gogroupimports.json"
+
+// requireSignedConfig refuses extended configs without a valid signature, see signaturePolicy
+var requireSignedConfig bool
+
+// configFlag registers the flags selecting the config on flags and returns the config path
+func configFlag(flags *flag.FlagSet) *string {
+	flags.BoolVar(&requireSignedConfig, "require-signed-config", false, "refuse extended configs without a valid signature by one of configPublicKeys")
+	return flags.String("config", "", "path of the JSON or YAML config file (default "+defaultConfigFile+" or "+initConfigFile+" if present)")
+}
+
+// gorootFlag registers the flag overriding the goroot setting on flags
+func gorootFlag(flags *flag.FlagSet) *string {
+	return flags.String("goroot", "", "Go installation whose packages are the builtin ones, overrides the goroot setting (default the GOROOT of go env)")
+}
+
+// loadConfig reads the settings passed to the checker. The own module defaults to the
+// module declared by the nearest go.mod. Without one it is the prefix of the Bazel workspace,
+// see bazelProject, or the project under GOPATH/src, see gopathProject. A config may extend a
+// shared one, see resolveExtends, and environment variables override its settings, see
+// applyEnv.
+func loadConfig(path string) (map[string]interface{}, error) {
+	return loadConfigIn("", path)
+}
+
+// loadConfigIn is loadConfig for the directory dir instead of the current one, which "" stands
+// for. The config defaults to the one in dir and the own module to the one of dir.
+func loadConfigIn(dir, path string) (map[string]interface{}, error) {
+	metaData := make(map[string]interface{})
+
+	if path == "" {
+		path = findConfigFileIn(dir)
+	}
+	if path != "" {
+		var err error
+		if metaData, err = readConfigFile(path, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(metaData); err != nil {
+		return nil, err
+	}
+
+	if selfModule, _ := metaData["selfModule"].(string); selfModule == "" {
+		if dir == "" {
+			var err error
+			if dir, err = os.Getwd(); err != nil {
+				return nil, err
+			}
+		}
+		modulePath, err := findModulePath(dir)
+		if err != nil {
+			return nil, err
+		}
+		if modulePath == "" {
+			// Bazel workspaces name their packages in BUILD files or a mapping file
+			if modulePath, err = bazelProject(dir, metaData); err != nil {
+				return nil, err
+			}
+			if modulePath != "" {
+				verbosef(1, "no go.mod found, using %s from the Bazel workspace as the own module", modulePath)
+			}
+		}
+		if modulePath == "" {
+			// Legacy GOPATH projects are named by their place under GOPATH/src
+			if modulePath = gopathProject(dir); modulePath != "" {
+				verbosef(1, "no go.mod found, using %s from its place under GOPATH as the own module", modulePath)
+			}
+		}
+		if modulePath == "" && path != "" {
+			return nil, fmt.Errorf("invalid config:\n%s: selfModule is empty, no go.mod was found in %s or its parents and it isn't under GOPATH/src, set selfModule", path, dir)
+		}
+		if modulePath != "" {
+			metaData["selfModule"] = modulePath
+		}
+	}
+	return metaData, nil
+}
+
+// findConfigFile returns the config loaded when none is given, "" when there is none
+func findConfigFile() string {
+	return findConfigFileIn("")
+}
+
+// findConfigFileIn returns the config of dir, "" for the current directory, like findConfigFile
+func findConfigFileIn(dir string) string {
+	for _, name := range []string{defaultConfigFile, initConfigFile} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return filepath.Join(dir, name)
+		}
+	}
+	return ""
+}
+
+// readConfigFile reads the config at path and the configs it extends. Extended configs are
+// verified with the keys of the config, or keys when it lists none.
+func readConfigFile(path string, keys interface{}) (map[string]interface{}, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	metaData, err := decodeConfig(path, content)
+	if err != nil {
+		return nil, err
+	}
+	if own, ok := metaData["configPublicKeys"]; ok {
+		keys = own
+	}
+	policy, err := newSignaturePolicy(keys, requireSignedConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return resolveExtends(metaData, filepath.Dir(path), policy, 0)
+}
+
+// checkerFor creates the checker for a loaded config
+func checkerFor(metaData map[string]interface{}) (*gogroupimports.Checker, error) {
+	settings, err := gogroupimports.ParseSettings(metaData)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return gogroupimports.NewChecker(settings)
+}
+
+// findModulePath returns the module path declared by the go.mod in dir or its closest parent
+func findModulePath(dir string) (string, error) {
+	for {
+		path := filepath.Join(dir, "go.mod")
+		content, err := os.ReadFile(path)
+		if err == nil {
+			if modulePath := modfile.ModulePath(content); modulePath != "" {
+				return modulePath, nil
+			}
+			return "", fmt.Errorf("%s has no module directive", path)
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/build"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/hsivakum/gogroupimports"
+)
+
+// doctor checks the environment and the config and prints what the checks will actually use:
+// the Go toolchain and GOROOT the stdlib packages come from, the module, the config file and
+// its environment overrides, the cache directory and the resulting settings
+func doctor(args []string) int {
+	flags := flag.NewFlagSet("gogroupimports doctor", flag.ExitOnError)
+	configPath := configFlag(flags)
+	gorootFlag := gorootFlag(flags)
+	_ = flags.Parse(args)
+
+	failed := false
+	report := func(status, topic, format string, args ...interface{}) {
+		if status == "FAIL" {
+			failed = true
+		}
+		fmt.Printf("%-4s  %-9s %s\n", status, topic, fmt.Sprintf(format, args...))
+	}
+
+	// Toolchain
+	report("ok", "binary", "built with %s for %s/%s", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	goEnv, err := readGoEnv()
+	if err != nil {
+		report("warn", "go", "%v; useGoList and package patterns need the go command", err)
+	} else {
+		report("ok", "go", "%s, GOPRIVATE=%q GOFLAGS=%q", goEnv["GOVERSION"], goEnv["GOPRIVATE"], goEnv["GOFLAGS"])
+	}
+
+	// GOROOT, whose packages are the builtin ones
+	goroot, _ := gogroupimports.Toolchain()
+	if *gorootFlag != "" {
+		goroot = *gorootFlag
+	}
+	switch info, err := os.Stat(filepath.Join(goroot, "src", "fmt")); {
+	case goroot == "":
+		report("FAIL", "GOROOT", "not found, standard library imports will be classified as third party")
+	case err != nil || !info.IsDir():
+		report("FAIL", "GOROOT", "%s has no standard library sources, its imports will be classified as third party; set it with -goroot or the goroot setting", goroot)
+	case filepath.Clean(goroot) != filepath.Clean(build.Default.GOROOT):
+		report("ok", "GOROOT", "%s, selected over %s the binary was built with", goroot, build.Default.GOROOT)
+	default:
+		report("ok", "GOROOT", "%s", goroot)
+	}
+
+	// Module
+	cwd, err := os.Getwd()
+	if err != nil {
+		report("FAIL", "go.mod", "%v", err)
+	} else if root, err := moduleRootOf(cwd, make(map[string]string)); err != nil {
+		report("FAIL", "go.mod", "%v", err)
+	} else if root == "" && gopathProject(cwd) != "" {
+		report("ok", "go.mod", "none found, %s is the own module by its place under GOPATH", gopathProject(cwd))
+	} else if root == "" {
+		report("warn", "go.mod", "none found in %s or its parents, selfModule must be set in the config", cwd)
+	} else if modulePath, err := findModulePath(root); err != nil {
+		report("FAIL", "go.mod", "%v", err)
+	} else {
+		report("ok", "go.mod", "%s declares %s", filepath.Join(root, "go.mod"), modulePath)
+	}
+
+	// Config
+	path := *configPath
+	if path == "" {
+		path = findConfigFile()
+	}
+	if path == "" {
+		report("ok", "config", "none, using the defaults")
+	} else {
+		report("ok", "config", "%s", path)
+	}
+	if overrides := envOverrides(); len(overrides) > 0 {
+		report("ok", "env", "%s override the config", strings.Join(overrides, ", "))
+	}
+	metaData, err := loadConfig(*configPath)
+	if err != nil {
+		report("FAIL", "config", "%v", err)
+		return 1
+	}
+	if *gorootFlag != "" {
+		metaData["goroot"] = *gorootFlag
+	}
+	settings, err := gogroupimports.ParseSettings(metaData)
+	var checker *gogroupimports.Checker
+	if err == nil {
+		checker, err = gogroupimports.NewChecker(settings)
+	}
+	if err != nil {
+		report("FAIL", "settings", "%v", err)
+		return 1
+	}
+	if hash, err := checker.ConfigHash(); err != nil {
+		report("FAIL", "settings", "hashing the config: %v", err)
+	} else {
+		report("ok", "settings", "config hash %s, results cached under another hash are stale", hash[:12])
+	}
+	if settings.SelfModule == "" {
+		report("warn", "settings", "selfModule is empty, no import is classified as own module")
+	}
+
+	// Cache and index
+	if settings.DisableCache {
+		report("ok", "cache", "disabled")
+	} else if dir, err := cacheDirectory(settings); err != nil {
+		report("warn", "cache", "%v, lookups are repeated on every run", err)
+	} else {
+		report("ok", "cache", "%s is writable", dir)
+	}
+	if settings.IndexFile != "" {
+		if _, err := os.Stat(settings.IndexFile); err != nil {
+			report("FAIL", "index", "%v", err)
+		} else {
+			report("ok", "index", "%s, the environment isn't probed", settings.IndexFile)
+		}
+	}
+
+	fmt.Println("\nsettings:")
+	effective, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		report("FAIL", "settings", "%v", err)
+		return 1
+	}
+	fmt.Println(string(effective))
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// readGoEnv returns the variables of the go command relevant to classifying imports
+func readGoEnv() (map[string]string, error) {
+	if _, err := exec.LookPath("go"); err != nil {
+		return nil, fmt.Errorf("go command not found in PATH")
+	}
+	out, err := exec.Command("go", "env", "-json", "GOVERSION", "GOROOT", "GOPRIVATE", "GOFLAGS").Output()
+	if err != nil {
+		return nil, fmt.Errorf("go env: %w", err)
+	}
+	env := make(map[string]string)
+	if err := json.Unmarshal(out, &env); err != nil {
+		return nil, fmt.Errorf("go env: %w", err)
+	}
+	return env, nil
+}
+
+// envOverrides returns the environment variables set to override settings, see applyEnv
+func envOverrides() []string {
+	var overrides []string
+	for name := range settingFields() {
+		if _, ok := os.LookupEnv(envName(name)); ok {
+			overrides = append(overrides, envName(name))
+		}
+	}
+	sort.Strings(overrides)
+	return overrides
+}
+
+// cacheDirectory returns the cache directory used with settings after making sure it can be
+// written to
+func cacheDirectory(settings gogroupimports.Settings) (string, error) {
+	dir := settings.CacheDir
+	if dir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(userCacheDir, "gogroupimports")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	probe, err := os.CreateTemp(dir, "doctor.*.tmp")
+	if err != nil {
+		return "", err
+	}
+	probe.Close()
+	return dir, os.Remove(probe.Name())
+}
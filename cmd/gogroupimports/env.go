@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/hsivakum/gogroupimports"
+)
+
+// envPrefix starts the names of the environment variables overriding settings
+const envPrefix = "GOGROUPIMPORTS_"
+
+// applyEnv overrides the settings of metaData with environment variables. Every setting can be
+// set through GOGROUPIMPORTS_ followed by its name in upper snake case, e.g.
+// GOGROUPIMPORTS_SELF_MODULE or GOGROUPIMPORTS_INTERNAL_PRIVATE_DOMAINS. Lists are comma
+// separated, maps are written as key=value pairs separated by commas, with the values of
+// deprecatedImports separated by |. Values starting with [ or { are decoded as JSON.
+func applyEnv(metaData map[string]interface{}) error {
+	fields := settingFields()
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		variable := envName(name)
+		value, ok := os.LookupEnv(variable)
+		if !ok {
+			continue
+		}
+		parsed, err := parseEnvValue(fields[name], value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", variable, err)
+		}
+		metaData[name] = parsed
+	}
+	return nil
+}
+
+// settingFields returns the types of the Settings fields by their json names
+func settingFields() map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	settings := reflect.TypeOf(gogroupimports.Settings{})
+	for i := 0; i < settings.NumField(); i++ {
+		field := settings.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// envName returns the environment variable of the setting with the json name, e.g.
+// GOGROUPIMPORTS_SELF_MODULE for selfModule
+func envName(name string) string {
+	var variable strings.Builder
+	variable.WriteString(envPrefix)
+	for i, r := range name {
+		if unicode.IsUpper(r) && i > 0 {
+			variable.WriteByte('_')
+		}
+		variable.WriteRune(unicode.ToUpper(r))
+	}
+	return variable.String()
+}
+
+// parseEnvValue converts value to the JSON representation of a setting of type t
+func parseEnvValue(t reflect.Type, value string) (interface{}, error) {
+	if trimmed := strings.TrimSpace(value); strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "{") {
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(trimmed), &parsed); err != nil {
+			return nil, err
+		}
+		return parsed, nil
+	}
+
+	switch {
+	case t.Kind() == reflect.String:
+		return value, nil
+	case t.Kind() == reflect.Bool:
+		return strconv.ParseBool(value)
+	case t.Kind() == reflect.Int || t.Kind() == reflect.Int64:
+		return strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String:
+		// An empty variable means an empty list, which differs from an unset one for some settings
+		return append([]string{}, splitList(value)...), nil
+	case t.Kind() == reflect.Map && t.Elem().Kind() == reflect.String:
+		pairs := make(map[string]string)
+		for _, pair := range splitList(value) {
+			key, element, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, fmt.Errorf("%q is not a key=value pair", pair)
+			}
+			pairs[strings.TrimSpace(key)] = strings.TrimSpace(element)
+		}
+		return pairs, nil
+	case t.Kind() == reflect.Map && t.Elem().Kind() == reflect.Slice:
+		pairs := make(map[string][]string)
+		for _, pair := range splitList(value) {
+			key, elements, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, fmt.Errorf("%q is not a key=value pair", pair)
+			}
+			pairs[strings.TrimSpace(key)] = splitListBy(elements, "|")
+		}
+		return pairs, nil
+	default:
+		return nil, fmt.Errorf("expected JSON for a setting of type %s", t)
+	}
+}
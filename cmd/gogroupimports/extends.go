@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// extendsTimeout bounds fetching a remote base config
+const extendsTimeout = 10 * time.Second
+
+// maxExtendsDepth stops chains of configs extending each other
+const maxExtendsDepth = 8
+
+// resolveExtends merges metaData over the config named by its extends key, recursively. Local
+// configs are resolved relative to dir, remote ones have to be https URLs. Settings of
+// metaData replace those of the base config as a whole, lists are not merged. Every extended
+// config has to pass policy.
+func resolveExtends(metaData map[string]interface{}, dir string, policy *signaturePolicy, depth int) (map[string]interface{}, error) {
+	extends, ok := metaData["extends"]
+	if !ok {
+		return metaData, nil
+	}
+	delete(metaData, "extends")
+	location, ok := extends.(string)
+	if !ok || location == "" {
+		return nil, errors.New("extends must be a file path or an https URL")
+	}
+	if depth >= maxExtendsDepth {
+		return nil, fmt.Errorf("extends %s: too many nested configs", location)
+	}
+
+	fetch := os.ReadFile
+	baseDir := dir
+	switch {
+	case strings.HasPrefix(location, "https://"):
+		fetch = fetchConfig
+	case strings.Contains(location, "://"):
+		return nil, fmt.Errorf("extends %s: only https URLs are supported", location)
+	default:
+		if !filepath.IsAbs(location) {
+			location = filepath.Join(dir, location)
+		}
+		baseDir = filepath.Dir(location)
+	}
+	content, err := fetch(location)
+	if err != nil {
+		return nil, fmt.Errorf("extends %s: %w", location, err)
+	}
+	if err := policy.check(location, content, fetch); err != nil {
+		return nil, fmt.Errorf("extends %s: %w", location, err)
+	}
+
+	base, err := decodeConfig(location, content)
+	if err != nil {
+		return nil, err
+	}
+	base, err = resolveExtends(base, baseDir, policy, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range metaData {
+		base[key] = value
+	}
+	return base, nil
+}
+
+// decodeConfig decodes a JSON or, judging by its name, YAML config
+func decodeConfig(name string, content []byte) (map[string]interface{}, error) {
+	metaData := make(map[string]interface{})
+	var err error
+	if strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml") {
+		err = yaml.Unmarshal(content, &metaData)
+	} else {
+		err = json.Unmarshal(content, &metaData)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", name, err)
+	}
+	if err := validateConfig(name, content, metaData); err != nil {
+		return nil, fmt.Errorf("invalid config:\n%w", err)
+	}
+	return metaData, nil
+}
+
+// fetchConfig downloads a remote config. The last copy is kept in the user cache directory and
+// revalidated with its ETag, so unchanged configs aren't downloaded again and the cached copy
+// is used when the server can't be reached.
+func fetchConfig(url string) ([]byte, error) {
+	cached, etag, cachePath := readCachedConfig(url)
+
+	ctx, cancel := context.WithTimeout(context.Background(), extendsTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil && etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if cached != nil {
+			log.Printf("using cached copy of %s: %v", url, err)
+			return cached, nil
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		return cached, nil
+	case resp.StatusCode != http.StatusOK:
+		if cached != nil {
+			log.Printf("using cached copy of %s: %s", url, resp.Status)
+			return cached, nil
+		}
+		return nil, errors.New(resp.Status)
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	writeCachedConfig(cachePath, content, resp.Header.Get("ETag"))
+	return content, nil
+}
+
+// readCachedConfig returns the cached copy of url and its ETag, along with the path the copy
+// is stored at. The path is empty when there is no cache directory.
+func readCachedConfig(url string) (content []byte, etag string, path string) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, "", ""
+	}
+	hash := sha256.Sum256([]byte(url))
+	path = filepath.Join(dir, "gogroupimports", "extends", hex.EncodeToString(hash[:]))
+
+	content, err = os.ReadFile(path)
+	if err != nil {
+		return nil, "", path
+	}
+	tag, _ := os.ReadFile(path + ".etag")
+	return content, string(tag), path
+}
+
+// writeCachedConfig stores a downloaded config. Failing to do so only costs the offline
+// fallback, so errors are ignored.
+func writeCachedConfig(path string, content []byte, etag string) {
+	if path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	if os.WriteFile(path, content, 0o644) != nil {
+		return
+	}
+	if etag == "" {
+		_ = os.Remove(path + ".etag")
+		return
+	}
+	_ = os.WriteFile(path+".etag", []byte(etag), 0o644)
+}
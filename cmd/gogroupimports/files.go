@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"go/build"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hsivakum/gogroupimports"
+)
+
+// useGitignore skips the paths matched by .gitignore files when walking the top directory of
+// a git repository
+var useGitignore = true
+
+// includeTemplates checks the Go source of code generation templates found in directories and
+// file lists as well
+var includeTemplates bool
+
+// goFiles expands paths into the Go files to process. Directories are walked recursively,
+// skipping vendor, testdata and hidden directories like the go tool does. Arguments that don't
+// exist on disk are package patterns, like std or example.com/app/..., which the go tool
+// resolves to the files of the matching packages including their tests, unless they are
+// spelled like paths, e.g. ./cmd/... or /src/app, which then don't exist. Files named by
+// several arguments, like a directory and a file in it, are returned once.
+func goFiles(paths []string) ([]string, error) {
+	files, err := expandPaths(paths)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(files))
+	unique := files[:0]
+	for _, file := range files {
+		if key := filepath.Clean(file); !seen[key] {
+			seen[key] = true
+			unique = append(unique, file)
+		}
+	}
+	return unique, nil
+}
+
+// isPathLike reports whether the argument names a file system path rather than a package
+// pattern, because it is absolute or relative like the go tool's local imports
+func isPathLike(arg string) bool {
+	return filepath.IsAbs(arg) || build.IsLocalImport(filepath.ToSlash(arg))
+}
+
+// expandPaths is goFiles keeping duplicates
+func expandPaths(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	var files []string
+	var patterns []string
+	for _, root := range paths {
+		// Directories are always walked recursively, so the go tool's pattern adds nothing
+		if root == "..." {
+			root = "."
+		}
+		// On Windows the go tool accepts both separators before ...
+		dir := strings.TrimSuffix(strings.TrimSuffix(root, "/..."), string(filepath.Separator)+"...")
+
+		info, err := os.Stat(dir)
+		if errors.Is(err, fs.ErrNotExist) && !strings.HasSuffix(root, ".go") && !isPathLike(dir) {
+			patterns = append(patterns, root)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, dir)
+			continue
+		}
+
+		found, err := gogroupimports.FindGoFilesWith(dir, gogroupimports.WalkOptions{
+			Skipped: func(path string) {
+				verbosef(1, "skipping %s", path)
+			},
+			NoGitignore: !useGitignore,
+			Templates:   includeTemplates,
+		})
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, found...)
+	}
+
+	if len(patterns) > 0 {
+		found, err := gogroupimports.FindPackageFiles(".", patterns)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range found {
+			files = append(files, relativePath(file))
+		}
+	}
+	return files, nil
+}
+
+// splitManifests separates the arguments naming file lists, @ followed by the path of the
+// list like @changed.txt, from the paths to check
+func splitManifests(args []string) (paths, manifests []string) {
+	for _, arg := range args {
+		if manifest, ok := strings.CutPrefix(arg, "@"); ok && manifest != "" {
+			manifests = append(manifests, manifest)
+			continue
+		}
+		paths = append(paths, arg)
+	}
+	return paths, manifests
+}
+
+// listedGoFiles reads the paths listed in the file list, "-" for stdin, one per line or
+// separated by NUL bytes with nul. Like the output of git diff --name-only or find, the list may
+// name other files and files that were deleted, those are skipped. Directories are walked.
+func listedGoFiles(list string, nul bool) ([]string, error) {
+	var content []byte
+	var err error
+	if list == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(list)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	separator := []byte("\n")
+	if nul {
+		separator = []byte{0}
+	}
+	var files []string
+	for _, entry := range bytes.Split(content, separator) {
+		path := string(entry)
+		if !nul {
+			path = strings.TrimSuffix(path, "\r")
+		}
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			verbosef(1, "skipping %s, it doesn't exist", path)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			if strings.HasSuffix(path, ".go") || includeTemplates && gogroupimports.IsTemplateFile(path) {
+				files = append(files, path)
+			}
+			continue
+		}
+		found, err := goFiles([]string{path})
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, found...)
+	}
+	return files, nil
+}
+
+// relativePath shortens an absolute path below the working directory for display
+func relativePath(path string) string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(cwd, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return rel
+}
+
+// moduleFiles drops the files of nested modules, those below a directory with a go.mod of its
+// own, keeping the files of the module containing the working directory
+func moduleFiles(files []string) ([]string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	roots := make(map[string]string)
+	own, err := moduleRootOf(cwd, roots)
+	if err != nil {
+		return nil, err
+	}
+
+	var kept []string
+	for _, file := range files {
+		dir, err := filepath.Abs(filepath.Dir(file))
+		if err != nil {
+			return nil, err
+		}
+		root, err := moduleRootOf(dir, roots)
+		if err != nil {
+			return nil, err
+		}
+		if root != own {
+			verbosef(1, "skipping %s of the module in %s", file, root)
+			continue
+		}
+		kept = append(kept, file)
+	}
+	return kept, nil
+}
+
+// moduleRootOf returns the nearest directory containing a go.mod at or above the absolute
+// directory dir, or "" when there is none. roots caches the answer for every directory visited.
+func moduleRootOf(dir string, roots map[string]string) (string, error) {
+	if root, ok := roots[dir]; ok {
+		return root, nil
+	}
+	var root string
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+		root = dir
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	} else if parent := filepath.Dir(dir); parent != dir {
+		if root, err = moduleRootOf(parent, roots); err != nil {
+			return "", err
+		}
+	}
+	roots[dir] = root
+	return root, nil
+}
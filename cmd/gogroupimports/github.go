@@ -0,0 +1,223 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// githubPullRequest is a pull request reviewed by the bot
+type githubPullRequest struct {
+	client *botClient
+	repo   string // owner/name
+	number int
+	head   string // Commit the files are read at and the review is posted on
+}
+
+// githubEvent holds the fields of pull_request webhook payloads the bot uses
+type githubEvent struct {
+	Action      string `json:"action"`
+	PullRequest struct {
+		Number int `json:"number"`
+		Head   struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+	} `json:"pull_request"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// newGitHubClient returns a client for the GitHub API at api, with the token in GITHUB_TOKEN
+func newGitHubClient(api string) *botClient {
+	header := http.Header{"Accept": {"application/vnd.github+json"}, "X-Github-Api-Version": {"2022-11-28"}}
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	return &botClient{
+		api:       strings.TrimSuffix(cmp.Or(api, "https://api.github.com"), "/"),
+		header:    header,
+		rawAccept: "application/vnd.github.raw",
+	}
+}
+
+// githubPullRequestFor returns the pull request number of repo, or the one of the webhook
+// payload at eventPath when number is 0
+func githubPullRequestFor(ctx context.Context, client *botClient, repo string, number int, eventPath string) (*githubPullRequest, error) {
+	switch {
+	case number != 0 && repo == "":
+		return nil, errors.New("-pr requires -repo")
+	case number != 0:
+		var pr struct {
+			Head struct {
+				SHA string `json:"sha"`
+			} `json:"head"`
+		}
+		if err := client.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/pulls/%d", repo, number), nil, &pr); err != nil {
+			return nil, err
+		}
+		return &githubPullRequest{client: client, repo: repo, number: number, head: pr.Head.SHA}, nil
+	case eventPath != "":
+		event, err := readGitHubEvent(eventPath)
+		if err != nil {
+			return nil, err
+		}
+		return event.pullRequest(client), nil
+	}
+	return nil, errors.New("name the pull request with -repo and -pr or -event, or receive webhooks with -listen")
+}
+
+// pullRequest returns the pull request of the event
+func (event *githubEvent) pullRequest(client *botClient) *githubPullRequest {
+	return &githubPullRequest{client: client, repo: event.Repository.FullName, number: event.PullRequest.Number, head: event.PullRequest.Head.SHA}
+}
+
+func (pr *githubPullRequest) String() string {
+	return fmt.Sprintf("%s#%d", pr.repo, pr.number)
+}
+
+// changedFiles returns the Go files the pull request adds or modifies, at its head
+func (pr *githubPullRequest) changedFiles(ctx context.Context) ([]botFile, error) {
+	var files []botFile
+	for page := 1; ; page++ {
+		var listed []struct {
+			Filename string `json:"filename"`
+			Status   string `json:"status"`
+			Patch    string `json:"patch"`
+		}
+		path := fmt.Sprintf("/repos/%s/pulls/%d/files?per_page=100&page=%d", pr.repo, pr.number, page)
+		if err := pr.client.do(ctx, http.MethodGet, path, nil, &listed); err != nil {
+			return nil, err
+		}
+		for _, file := range listed {
+			if file.Status == "removed" || !strings.HasSuffix(file.Filename, ".go") {
+				continue
+			}
+			var src []byte
+			path := fmt.Sprintf("/repos/%s/contents/%s?ref=%s", pr.repo, escapePath(file.Filename), url.QueryEscape(pr.head))
+			if err := pr.client.do(ctx, http.MethodGet, path, nil, &src); err != nil {
+				return nil, err
+			}
+			hunks, old := patchHunks(file.Patch)
+			files = append(files, botFile{path: file.Filename, src: src, hunks: hunks, old: old})
+		}
+		if len(listed) < 100 {
+			return files, nil
+		}
+	}
+}
+
+// post submits the review with its inline comments on the head commit
+func (pr *githubPullRequest) post(ctx context.Context, review *botReview) error {
+	type comment struct {
+		Path      string `json:"path"`
+		Line      int    `json:"line"`
+		Side      string `json:"side"`
+		StartLine int    `json:"start_line,omitempty"`
+		StartSide string `json:"start_side,omitempty"`
+		Body      string `json:"body"`
+	}
+	comments := []comment{}
+	for _, c := range review.comments {
+		posted := comment{Path: c.path, Line: c.end, Side: "RIGHT", Body: c.body}
+		if c.start != c.end {
+			posted.StartLine, posted.StartSide = c.start, "RIGHT"
+		}
+		comments = append(comments, posted)
+	}
+	body := map[string]interface{}{
+		"commit_id": pr.head,
+		"event":     "COMMENT",
+		"body":      review.summary(),
+		"comments":  comments,
+	}
+	return pr.client.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/pulls/%d/reviews", pr.repo, pr.number), body, nil)
+}
+
+// escapePath escapes the elements of a slash separated path for a URL
+func escapePath(path string) string {
+	elements := strings.Split(path, "/")
+	for i, element := range elements {
+		elements[i] = url.PathEscape(element)
+	}
+	return strings.Join(elements, "/")
+}
+
+// readGitHubEvent reads a pull_request webhook payload, like the one GitHub Actions leaves at
+// GITHUB_EVENT_PATH
+func readGitHubEvent(path string) (*githubEvent, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var event githubEvent
+	if err := json.Unmarshal(content, &event); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if event.PullRequest.Number == 0 || event.Repository.FullName == "" {
+		return nil, fmt.Errorf("%s: not a pull_request event", path)
+	}
+	return &event, nil
+}
+
+// githubWebhook answers GitHub webhook deliveries, verifying their signature with secret, and
+// calls review for every pull request that was opened or got new commits. review runs in the
+// background, GitHub gives up on deliveries after ten seconds.
+func githubWebhook(client *botClient, secret string, review func(botPullRequest)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if !validSignature(secret, payload, r.Header.Get("X-Hub-Signature-256")) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("X-GitHub-Event") != "pull_request" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		var event githubEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch event.Action {
+		case "opened", "reopened", "synchronize", "ready_for_review":
+			go review(event.pullRequest(client))
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+// validSignature reports whether signature is the sha256=<hex> HMAC of payload with secret
+func validSignature(secret string, payload []byte, signature string) bool {
+	sum, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sum)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hmac.Equal(got, mac.Sum(nil))
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// gitlabMergeRequest is a merge request reviewed by the bot
+type gitlabMergeRequest struct {
+	client  *botClient
+	project string // group/name
+	iid     int
+	refs    gitlabDiffRefs
+}
+
+// gitlabDiffRefs are the commits positions of discussions refer to
+type gitlabDiffRefs struct {
+	BaseSHA  string `json:"base_sha"`
+	StartSHA string `json:"start_sha"`
+	HeadSHA  string `json:"head_sha"`
+}
+
+// newGitLabClient returns a client for the GitLab API at api, with the token in GITLAB_TOKEN
+func newGitLabClient(api string) *botClient {
+	header := http.Header{}
+	if token := os.Getenv("GITLAB_TOKEN"); token != "" {
+		header.Set("Private-Token", token)
+	}
+	return &botClient{api: strings.TrimSuffix(cmp.Or(api, "https://gitlab.com/api/v4"), "/"), header: header}
+}
+
+// gitlabMergeRequestFor returns the merge request iid of project at its current head
+func gitlabMergeRequestFor(ctx context.Context, c *botClient, project string, iid int) (*gitlabMergeRequest, error) {
+	if project == "" || iid == 0 {
+		return nil, errors.New("name the merge request with -repo and -pr, or run in a merge request pipeline")
+	}
+	var mr struct {
+		DiffRefs gitlabDiffRefs `json:"diff_refs"`
+	}
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/projects/%s/merge_requests/%d", url.PathEscape(project), iid), nil, &mr); err != nil {
+		return nil, err
+	}
+	return &gitlabMergeRequest{client: c, project: project, iid: iid, refs: mr.DiffRefs}, nil
+}
+
+func (mr *gitlabMergeRequest) String() string {
+	return fmt.Sprintf("%s!%d", mr.project, mr.iid)
+}
+
+// path returns the API path of the merge request followed by elements
+func (mr *gitlabMergeRequest) path(elements string) string {
+	return fmt.Sprintf("/projects/%s/merge_requests/%d%s", url.PathEscape(mr.project), mr.iid, elements)
+}
+
+// changedFiles returns the Go files the merge request adds or modifies, at its head
+func (mr *gitlabMergeRequest) changedFiles(ctx context.Context) ([]botFile, error) {
+	var files []botFile
+	for page := 1; ; page++ {
+		var listed []struct {
+			NewPath     string `json:"new_path"`
+			DeletedFile bool   `json:"deleted_file"`
+			Diff        string `json:"diff"`
+		}
+		if err := mr.client.do(ctx, http.MethodGet, mr.path(fmt.Sprintf("/diffs?per_page=100&page=%d", page)), nil, &listed); err != nil {
+			return nil, err
+		}
+		for _, file := range listed {
+			if file.DeletedFile || !strings.HasSuffix(file.NewPath, ".go") {
+				continue
+			}
+			var src []byte
+			path := fmt.Sprintf("/projects/%s/repository/files/%s/raw?ref=%s", url.PathEscape(mr.project), url.PathEscape(file.NewPath), url.QueryEscape(mr.refs.HeadSHA))
+			if err := mr.client.do(ctx, http.MethodGet, path, nil, &src); err != nil {
+				return nil, err
+			}
+			hunks, old := patchHunks(file.Diff)
+			files = append(files, botFile{path: file.NewPath, src: src, hunks: hunks, old: old})
+		}
+		if len(listed) < 100 {
+			return files, nil
+		}
+	}
+}
+
+// post starts a discussion on the first line of every comment and adds the summary as a note.
+// GitLab suggestions cover the lines after the one commented on with suggestion:-0+n.
+func (mr *gitlabMergeRequest) post(ctx context.Context, review *botReview) error {
+	for _, comment := range review.comments {
+		position := map[string]interface{}{
+			"position_type": "text",
+			"base_sha":      mr.refs.BaseSHA,
+			"start_sha":     mr.refs.StartSHA,
+			"head_sha":      mr.refs.HeadSHA,
+			"old_path":      comment.path,
+			"new_path":      comment.path,
+			"new_line":      comment.start,
+		}
+		// Unchanged lines are positioned on both sides of the diff
+		if comment.oldStart != 0 {
+			position["old_line"] = comment.oldStart
+		}
+		body := strings.Replace(comment.body, "```suggestion\n", fmt.Sprintf("```suggestion:-0+%d\n", comment.end-comment.start), 1)
+		discussion := map[string]interface{}{"body": body, "position": position}
+		if err := mr.client.do(ctx, http.MethodPost, mr.path("/discussions"), discussion, nil); err != nil {
+			return err
+		}
+	}
+	return mr.client.do(ctx, http.MethodPost, mr.path("/notes"), map[string]string{"body": review.summary()}, nil)
+}
+
+// gitlabEvent holds the fields of merge request webhook payloads the bot uses
+type gitlabEvent struct {
+	ObjectKind string `json:"object_kind"`
+	Project    struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+	ObjectAttributes struct {
+		IID    int    `json:"iid"`
+		Action string `json:"action"`
+		OldRev string `json:"oldrev"` // Set by updates pushing commits
+	} `json:"object_attributes"`
+}
+
+// gitlabWebhook answers GitLab webhook deliveries, checking their token against secret, and
+// calls review for every merge request that was opened or got new commits. review runs in the
+// background, GitLab expects an answer within seconds.
+func gitlabWebhook(client *botClient, secret string, review func(botPullRequest)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Gitlab-Token")), []byte(secret)) != 1 {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		var event gitlabEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		attributes := event.ObjectAttributes
+		if event.ObjectKind != "merge_request" || !(attributes.Action == "open" || attributes.Action == "reopen" || attributes.Action == "update" && attributes.OldRev != "") {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		go func() {
+			// The event lacks the diff refs, the merge request has them
+			mr, err := gitlabMergeRequestFor(context.Background(), client, event.Project.PathWithNamespace, attributes.IID)
+			if err != nil {
+				verbosef(0, "%s!%d: %v", event.Project.PathWithNamespace, attributes.IID, err)
+				return
+			}
+			review(mr)
+		}()
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
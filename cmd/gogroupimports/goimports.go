@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// goimportsCompat formats files like goimports does, with the flags of goimports, so that
+// editors and scripts running goimports can run gogroupimports instead. It regroups the
+// imports and formats the files, it doesn't add or remove imports.
+func goimportsCompat(args []string) int {
+	flags := flag.NewFlagSet("goimports", flag.ExitOnError)
+	configPath := configFlag(flags)
+	list := flags.Bool("l", false, "list files whose formatting differs from gogroupimports's")
+	write := flags.Bool("w", false, "write result to (source) file instead of stdout")
+	diff := flags.Bool("d", false, "display diffs instead of rewriting files")
+	local := flags.String("local", "", "put imports beginning with this string after 3rd-party packages; comma-separated list")
+	srcdir := flags.String("srcdir", "", "choose imports as if source code is from `dir`. When operating on a single file, dir may instead be the complete file name.")
+	_ = flags.Bool("e", false, "report all errors (not just the first 10 on different lines)")
+	_ = flags.Bool("format-only", false, "accepted for compatibility, imports are never added or removed")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: goimports [flags] [path ...]")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	dir := ""
+	stdinName := "<standard input>"
+	if *srcdir != "" {
+		dir = *srcdir
+		if strings.HasSuffix(dir, ".go") {
+			dir, stdinName = filepath.Dir(dir), *srcdir
+		} else {
+			stdinName = filepath.Join(dir, "stdin.go")
+		}
+	}
+	metaData, err := loadConfigIn(dir, *configPath)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	var overrides map[string]interface{}
+	if *local != "" {
+		overrides = localSettings(metaData, *local)
+	}
+	absDir, err := filepath.Abs(cmp.Or(dir, "."))
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	checkers, err := newCheckerTreeIn(absDir, metaData, overrides)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+
+	run := &goimportsRun{checkers: checkers, list: *list, write: *write, diff: *diff}
+	if flags.NArg() == 0 {
+		if *write {
+			log.Print("can't use -w on stdin")
+			return 2
+		}
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Print(err)
+			return 2
+		}
+		if err := run.process(stdinName, "<standard input>", src); err != nil {
+			log.Print(err)
+			return 2
+		}
+		return 0
+	}
+
+	files, err := goFiles(flags.Args())
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	exitCode := 0
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err == nil {
+			err = run.process(file, file, src)
+		}
+		if err != nil {
+			log.Print(err)
+			exitCode = 2
+		}
+	}
+	return exitCode
+}
+
+// localSettings maps the -local prefixes of goimports onto the settings overriding the config
+// metaData: the first one is the own module, later ones are internal private domains added to
+// those of the config, both grouped after third party imports
+func localSettings(metaData map[string]interface{}, local string) map[string]interface{} {
+	prefixes := strings.Split(local, ",")
+	settings := map[string]interface{}{"selfModule": strings.TrimSpace(prefixes[0])}
+	if len(prefixes) == 1 {
+		return settings
+	}
+	domains, _ := metaData["internalPrivateDomains"].([]interface{})
+	domains = slices.Clone(domains)
+	for _, prefix := range prefixes[1:] {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			domains = append(domains, prefix)
+		}
+	}
+	settings["internalPrivateDomains"] = domains
+	return settings
+}
+
+// goimportsRun formats files for goimportsCompat
+type goimportsRun struct {
+	checkers          *checkerTree
+	list, write, diff bool
+}
+
+// process formats src, the contents of file, shown as name, and prints or writes the result
+// as the flags ask
+func (run *goimportsRun) process(file, name string, src []byte) error {
+	checker, err := run.checkers.forFile(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	fixed, _, err := checker.FixSource(context.Background(), file, src)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if fixed, err = format.Source(fixed); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	changed := !bytes.Equal(src, fixed)
+	if run.list && changed {
+		fmt.Println(name)
+	}
+	if run.write && changed {
+		if err := writeFile(file, fixed, ""); err != nil {
+			return err
+		}
+	}
+	if run.diff && changed {
+		oldName, newName := filepath.ToSlash(filepath.Join("a", name)), filepath.ToSlash(filepath.Join("b", name))
+		fmt.Printf("diff -u %s %s\n%s", oldName, newName, unifiedDiff(oldName, newName, src, fixed))
+	}
+	if !run.list && !run.write && !run.diff {
+		_, err = os.Stdout.Write(fixed)
+	}
+	return err
+}
+
+// diffContext is the number of unchanged lines around the changes of a hunk
+const diffContext = 3
+
+// maxDiffLines bounds the changed region diffed line by line, larger ones are shown replaced
+// as a whole rather than spending quadratic time and memory
+const maxDiffLines = 2000
+
+// unifiedDiff returns the unified diff from old to new
+func unifiedDiff(oldName, newName string, old, new []byte) string {
+	a, b := linesOf(old), linesOf(new)
+
+	// Imports only change a small region, so the lines around it are matched directly
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	// ops holds ' ', '-' or '+' for every line of the diff, in order
+	var ops []byte
+	var lines []string
+	add := func(op byte, line string) {
+		ops = append(ops, op)
+		lines = append(lines, line)
+	}
+	for _, line := range a[:prefix] {
+		add(' ', line)
+	}
+	middleA, middleB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(middleA) > maxDiffLines || len(middleB) > maxDiffLines {
+		for _, line := range middleA {
+			add('-', line)
+		}
+		for _, line := range middleB {
+			add('+', line)
+		}
+	} else {
+		// Longest common subsequence of the remaining lines, from the end
+		common := make([][]int, len(middleA)+1)
+		for i := range common {
+			common[i] = make([]int, len(middleB)+1)
+		}
+		for i := len(middleA) - 1; i >= 0; i-- {
+			for j := len(middleB) - 1; j >= 0; j-- {
+				if middleA[i] == middleB[j] {
+					common[i][j] = common[i+1][j+1] + 1
+				} else {
+					common[i][j] = max(common[i+1][j], common[i][j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(middleA) || j < len(middleB) {
+			switch {
+			case i < len(middleA) && j < len(middleB) && middleA[i] == middleB[j]:
+				add(' ', middleA[i])
+				i++
+				j++
+			case j == len(middleB) || i < len(middleA) && common[i+1][j] >= common[i][j+1]:
+				add('-', middleA[i])
+				i++
+			default:
+				add('+', middleB[j])
+				j++
+			}
+		}
+	}
+	for _, line := range a[len(a)-suffix:] {
+		add(' ', line)
+	}
+
+	var diff strings.Builder
+	fmt.Fprintf(&diff, "--- %s\n+++ %s\n", oldName, newName)
+	oldLine, newLine := 1, 1 // Of ops[start]
+	for start := 0; start < len(ops); {
+		// Find the next change and extend the hunk while changes are close enough
+		first := start
+		for first < len(ops) && ops[first] == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		hunkStart := max(first-diffContext, start)
+		for k := start; k < hunkStart; k++ {
+			oldLine++
+			newLine++
+		}
+		end := first
+		for end < len(ops) {
+			next := end
+			for next < len(ops) && ops[next] == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*diffContext {
+				break
+			}
+			end = next + 1
+		}
+		hunkEnd := min(end+diffContext, len(ops))
+
+		var oldCount, newCount int
+		for _, op := range ops[hunkStart:hunkEnd] {
+			if op != '+' {
+				oldCount++
+			}
+			if op != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&diff, "@@ -%s +%s @@\n", hunkRange(oldLine, oldCount), hunkRange(newLine, newCount))
+		for k := hunkStart; k < hunkEnd; k++ {
+			diff.WriteByte(ops[k])
+			diff.WriteString(lines[k])
+			if !strings.HasSuffix(lines[k], "\n") {
+				diff.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		oldLine += oldCount
+		newLine += newCount
+		start = hunkEnd
+	}
+	return diff.String()
+}
+
+// linesOf splits src into lines, keeping their line endings
+func linesOf(src []byte) []string {
+	lines := strings.SplitAfter(string(src), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// hunkRange formats the start and count of a hunk side, empty sides start at the line before
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"go/build"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// vcsDirs mark the root of a repository checked out under GOPATH/src
+var vcsDirs = []string{".git", ".hg", ".svn", ".bzr"}
+
+// gopathProject returns the import path of the project containing dir when dir lies under the
+// src directory of a GOPATH entry, for legacy projects without a go.mod. The project is the
+// repository checked out there, or the first three elements of the path on the public hosts,
+// like github.com/org/repo. It returns "" outside of GOPATH.
+func gopathProject(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for _, gopath := range filepath.SplitList(build.Default.GOPATH) {
+		src := filepath.Join(gopath, "src")
+		rel, err := filepath.Rel(src, dir)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		elements := strings.Split(filepath.ToSlash(rel), "/")
+
+		// The innermost repository root wins, nested checkouts are projects of their own
+		for i := len(elements); i > 0; i-- {
+			for _, vcs := range vcsDirs {
+				if _, err := os.Stat(filepath.Join(src, filepath.Join(elements[:i]...), vcs)); err == nil {
+					return strings.Join(elements[:i], "/")
+				}
+			}
+		}
+		if publicHosts[elements[0]] && len(elements) >= 3 {
+			return strings.Join(elements[:3], "/")
+		}
+		return strings.Join(elements, "/")
+	}
+	return ""
+}
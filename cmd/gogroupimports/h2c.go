@@ -0,0 +1,15 @@
+//go:build go1.24
+
+package main
+
+import "net/http"
+
+// allowCleartextHTTP2 lets httpServer speak HTTP/2 without TLS, which gRPC health probes of
+// orchestrators use. Over TLS HTTP/2 is negotiated anyway.
+func allowCleartextHTTP2(httpServer *http.Server) {
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	httpServer.Protocols = &protocols
+}
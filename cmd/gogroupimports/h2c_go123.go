@@ -0,0 +1,9 @@
+//go:build !go1.24
+
+package main
+
+import "net/http"
+
+// allowCleartextHTTP2 does nothing before Go 1.24, whose net/http can't serve HTTP/2 without
+// TLS; the gRPC health service then needs -tls-cert
+func allowCleartextHTTP2(httpServer *http.Server) {}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// hookVersion is increased whenever the hook script changes, so that install-hooks can tell
+// installed hooks that need an upgrade
+const hookVersion = 2
+
+// hookMarker identifies the files and config entries written by install-hooks, followed by
+// the hook version
+const hookMarker = "# installed by gogroupimports install-hooks, hook version "
+
+// preCommitConfigFile is the config of the pre-commit framework, https://pre-commit.com
+const preCommitConfigFile = ".pre-commit-config.yaml"
+
+// preCommitEnd closes the entry install-hooks adds to preCommitConfigFile
+const preCommitEnd = "# end of gogroupimports install-hooks"
+
+// installHooks installs, upgrades or removes the git pre-commit hook checking the staged Go
+// files, or the equivalent entry of the pre-commit framework config
+func installHooks(args []string) int {
+	flags := flag.NewFlagSet("gogroupimports install-hooks", flag.ExitOnError)
+	configPath := configFlag(flags)
+	preCommit := flags.Bool("pre-commit", false, "add a local hook to "+preCommitConfigFile+" of the pre-commit framework instead of writing .git/hooks/pre-commit")
+	uninstall := flags.Bool("uninstall", false, "remove the hook installed before, restoring the hook it replaced")
+	force := flags.Bool("f", false, "replace a pre-commit hook that wasn't installed by install-hooks, keeping it as pre-commit.orig")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: gogroupimports install-hooks [-config file] [-pre-commit] [-uninstall] [-f]")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+
+	var message string
+	var err error
+	switch {
+	case *preCommit && *uninstall:
+		message, err = uninstallPreCommitEntry(preCommitConfigFile)
+	case *preCommit:
+		message, err = installPreCommitEntry(preCommitConfigFile, *configPath)
+	default:
+		var path string
+		if path, err = hookPath(); err != nil {
+			break
+		}
+		if *uninstall {
+			message, err = uninstallHook(path)
+		} else {
+			message, err = installHook(path, *configPath, *force)
+		}
+	}
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	fmt.Println(message)
+	return 0
+}
+
+// hookPath returns the path of the pre-commit hook of the repository in the current
+// directory, honoring core.hooksPath and worktrees
+func hookPath() (string, error) {
+	out, err := exec.Command("git", "rev-parse", "--git-path", "hooks/pre-commit").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("not in a git repository: %s", bytes.TrimSpace(exitErr.Stderr))
+		}
+		return "", err
+	}
+	return filepath.FromSlash(strings.TrimSpace(string(out))), nil
+}
+
+// hookScript returns the pre-commit hook checking the staged Go files. It checks their
+// contents in the index, which is what gets committed, not the unstaged edits of the work tree.
+func hookScript(configPath string) string {
+	command := hookCommand()
+	if configPath != "" {
+		command += " -config " + shellQuote(configPath)
+	}
+	return fmt.Sprintf(`#!/bin/sh
+%s%d
+# Checks the imports of the staged Go files. Run gogroupimports install-hooks again to upgrade
+# the hook, with -uninstall to remove it, or commit with --no-verify to skip it once.
+git diff --cached --name-only -z --diff-filter=ACMR -- '*.go' |
+	exec %s -files-from - -0 -staged -progress=false
+`, hookMarker, hookVersion, command)
+}
+
+// stagedContent returns the contents of file in the git index
+func stagedContent(file string) ([]byte, error) {
+	cmd := exec.Command("git", "show", ":./"+filepath.Base(file))
+	cmd.Dir = filepath.Dir(file)
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("reading the staged contents: %s", bytes.TrimSpace(exitErr.Stderr))
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// hookCommand returns how hooks run gogroupimports: by name when it is found on PATH, else by
+// the path of the running binary
+func hookCommand() string {
+	if _, err := exec.LookPath("gogroupimports"); err == nil {
+		return "gogroupimports"
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return "gogroupimports"
+	}
+	return shellQuote(executable)
+}
+
+// shellQuote quotes s for sh when it has characters sh would interpret
+func shellQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"\\$`*?[]{}()<>|&;#~!") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// installedVersion returns the hook version recorded in content by install-hooks, 0 when it
+// wasn't written by install-hooks
+func installedVersion(content string) int {
+	_, after, ok := strings.Cut(content, hookMarker)
+	if !ok {
+		return 0
+	}
+	var version int
+	if _, err := fmt.Sscanf(after, "%d", &version); err != nil {
+		return 0
+	}
+	return version
+}
+
+// installHook writes the hook to path. A hook installed before is upgraded, any other hook is
+// only replaced with force, keeping it next to the new one.
+func installHook(path, configPath string, force bool) (string, error) {
+	script := hookScript(configPath)
+	existing, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return "", err
+		}
+	case err != nil:
+		return "", err
+	case string(existing) == script:
+		return fmt.Sprintf("%s is up to date", path), nil
+	case installedVersion(string(existing)) > 0:
+		if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+			return "", err
+		}
+		if version := installedVersion(string(existing)); version != hookVersion {
+			return fmt.Sprintf("upgraded %s from hook version %d to %d", path, version, hookVersion), nil
+		}
+		return fmt.Sprintf("rewrote %s, it was changed or installed with other flags", path), nil
+	case !force:
+		return "", fmt.Errorf("%s exists and wasn't installed by install-hooks, use -f to replace it, keeping it as %s.orig, or -pre-commit if it runs the pre-commit framework", path, filepath.Base(path))
+	default:
+		if err := os.Rename(path, path+".orig"); err != nil {
+			return "", err
+		}
+	}
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("installed %s", path), nil
+}
+
+// uninstallHook removes the hook at path if install-hooks wrote it, restoring the hook it
+// replaced
+func uninstallHook(path string) (string, error) {
+	existing, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Sprintf("%s isn't installed", path), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if installedVersion(string(existing)) == 0 {
+		return "", fmt.Errorf("%s wasn't installed by install-hooks, leaving it alone", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return "", err
+	}
+	if err := os.Rename(path+".orig", path); err == nil {
+		return fmt.Sprintf("removed %s, restored the hook it replaced", path), nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	return fmt.Sprintf("removed %s", path), nil
+}
+
+// preCommitEntry returns the local hook added to the repos of the pre-commit config, indented
+// like its other entries. pre-commit passes the staged Go files as arguments.
+func preCommitEntry(indent, configPath string) string {
+	args := "[-progress=false]"
+	if configPath != "" {
+		args = fmt.Sprintf("[-progress=false, -config, %q]", configPath)
+	}
+	lines := []string{
+		hookMarker + fmt.Sprint(hookVersion),
+		"- repo: local",
+		"  hooks:",
+		"    - id: gogroupimports",
+		"      name: gogroupimports",
+		"      entry: gogroupimports",
+		"      args: " + args,
+		"      language: system",
+		"      types: [go]",
+		preCommitEnd,
+	}
+	var entry strings.Builder
+	for _, line := range lines {
+		entry.WriteString(indent + line + "\n")
+	}
+	return entry.String()
+}
+
+// preCommitBlock matches the entry added by install-hooks, from its marker to preCommitEnd
+var preCommitBlock = regexp.MustCompile(`(?m)^[ \t]*` + regexp.QuoteMeta(hookMarker) + `\d+\n(?:.*\n)*?[ \t]*` + regexp.QuoteMeta(preCommitEnd) + `\n?`)
+
+// reposLine matches the repos key of the pre-commit config and the first line after it
+var reposLine = regexp.MustCompile(`(?m)^repos:[ \t]*\n(?:[ \t]*\n)*([ \t]*)`)
+
+// installPreCommitEntry adds the gogroupimports hook to the pre-commit config at path, or
+// upgrades the entry added before. The rest of the file is kept as it is.
+func installPreCommitEntry(path, configPath string) (string, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if err := os.WriteFile(path, []byte("repos:\n"+preCommitEntry("  ", configPath)), 0o644); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("wrote %s, run pre-commit install to enable it", path), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	text := string(content)
+	if block := preCommitBlock.FindStringIndex(text); block != nil {
+		indent := text[block[0] : block[0]+strings.Index(text[block[0]:], "#")]
+		entry := preCommitEntry(indent, configPath)
+		if text[block[0]:block[1]] == entry {
+			return fmt.Sprintf("%s is up to date", path), nil
+		}
+		text = text[:block[0]] + entry + text[block[1]:]
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("upgraded the gogroupimports hook in %s", path), nil
+	}
+	if strings.Contains(text, "id: gogroupimports") {
+		return "", fmt.Errorf("%s has a gogroupimports hook that wasn't added by install-hooks, leaving it alone", path)
+	}
+
+	match := reposLine.FindStringSubmatchIndex(text)
+	if match == nil {
+		return "", fmt.Errorf("%s has no repos list to add the hook to", path)
+	}
+	// The entry goes first, indented like the entry it precedes
+	indent := text[match[2]:match[3]]
+	text = text[:match[2]] + preCommitEntry(indent, configPath) + text[match[2]:]
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("added the gogroupimports hook to %s", path), nil
+}
+
+// uninstallPreCommitEntry removes the entry added by install-hooks from the pre-commit config
+func uninstallPreCommitEntry(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Sprintf("%s doesn't exist", path), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	block := preCommitBlock.FindIndex(content)
+	if block == nil {
+		return fmt.Sprintf("%s has no hook added by install-hooks", path), nil
+	}
+	content = append(content[:block[0]:block[0]], content[block[1]:]...)
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("removed the gogroupimports hook from %s", path), nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"log"
+)
+
+// defaultIndexFile is where the index command writes to when no output is given
+const defaultIndexFile = ".gogroupimports.index.json"
+
+// index writes the classification index for the given paths
+func index(args []string) int {
+	flags := flag.NewFlagSet("gogroupimports index", flag.ExitOnError)
+	configPath := configFlag(flags)
+	output := flags.String("o", defaultIndexFile, "file to write the index to")
+	_ = flags.Parse(args)
+
+	metaData, err := loadConfig(*configPath)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	// The index is built from the environment, not from a previous index
+	delete(metaData, "indexFile")
+	checker, err := checkerFor(metaData)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+
+	files, err := goFiles(flags.Args())
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+
+	if err := checker.WriteIndex(*output, files); err != nil {
+		log.Print(err)
+		return 2
+	}
+	return 0
+}
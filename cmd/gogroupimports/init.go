@@ -0,0 +1,249 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hsivakum/gogroupimports"
+)
+
+// initConfigFile is the config written by the init command, YAML so that it can carry comments
+const initConfigFile = ".gogroupimports.yaml"
+
+// publicHosts never host internal code, so their imports don't hint at internal domains
+var publicHosts = map[string]bool{
+	"github.com":        true,
+	"gitlab.com":        true,
+	"bitbucket.org":     true,
+	"golang.org":        true,
+	"google.golang.org": true,
+	"gopkg.in":          true,
+	"go.uber.org":       true,
+	"k8s.io":            true,
+	"sigs.k8s.io":       true,
+}
+
+// testFrameworks are suggested as test-only imports when only test files import them
+var testFrameworks = []string{
+	"github.com/stretchr/testify",
+	"github.com/golang/mock",
+	"go.uber.org/mock",
+	"github.com/onsi/ginkgo",
+	"github.com/onsi/gomega",
+}
+
+// initConfig writes a starter config based on go.mod, GOPRIVATE and the imports of the given paths
+func initConfig(args []string) int {
+	flags := flag.NewFlagSet("gogroupimports init", flag.ExitOnError)
+	output := flags.String("o", initConfigFile, "file to write the config to")
+	force := flags.Bool("f", false, "overwrite an existing config")
+	_ = flags.Parse(args)
+
+	if _, err := os.Stat(*output); err == nil && !*force {
+		log.Printf("%s already exists, use -f to overwrite it", *output)
+		return 2
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	modulePath, err := findModulePath(dir)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+
+	checker, err := gogroupimports.NewChecker(gogroupimports.Settings{SelfModule: modulePath})
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	files, err := goFiles(flags.Args())
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+
+	// Hosts of third party imports and the test frameworks used by tests and production code
+	hosts := make(map[string]int)
+	usedByTests := make(map[string]bool)
+	usedByProduction := make(map[string]bool)
+	for _, file := range files {
+		imports, err := checker.ListImports(file)
+		if err != nil {
+			log.Printf("%s: %v", file, err)
+			continue
+		}
+		for _, info := range imports {
+			if info.Type != "public_open_source_or_third_party" {
+				continue
+			}
+			host, _, _ := strings.Cut(info.Path, "/")
+			hosts[host]++
+			for _, framework := range testFrameworks {
+				if info.Path != framework && !strings.HasPrefix(info.Path, framework+"/") {
+					continue
+				}
+				if strings.HasSuffix(file, "_test.go") {
+					usedByTests[framework] = true
+				} else {
+					usedByProduction[framework] = true
+				}
+			}
+		}
+	}
+	var testOnly []string
+	for _, framework := range testFrameworks {
+		if usedByTests[framework] && !usedByProduction[framework] {
+			testOnly = append(testOnly, framework)
+		}
+	}
+
+	content := starterConfig(modulePath, guessInternalDomains(modulePath), hosts, testOnly)
+	if err := os.WriteFile(*output, []byte(content), 0o644); err != nil {
+		log.Print(err)
+		return 2
+	}
+	fmt.Printf("wrote %s, review the guessed settings before committing it\n", *output)
+	return 0
+}
+
+// guessInternalDomains derives internal domains from GOPRIVATE and the host of the own module
+func guessInternalDomains(modulePath string) []string {
+	seen := make(map[string]bool)
+	var domains []string
+	add := func(domain string) {
+		if domain != "" && !seen[domain] {
+			seen[domain] = true
+			domains = append(domains, domain)
+		}
+	}
+
+	for _, pattern := range strings.Split(goEnv("GOPRIVATE"), ",") {
+		// internalPrivateDomains match by substring, so leading wildcards aren't needed
+		add(strings.TrimPrefix(strings.TrimSpace(pattern), "*."))
+	}
+
+	// A module on a private host suggests that the host serves internal code. On public hosts
+	// only the organization of the module is internal.
+	host, rest, _ := strings.Cut(modulePath, "/")
+	if publicHosts[host] {
+		if org, _, _ := strings.Cut(rest, "/"); org != "" {
+			add(host + "/" + org + "/")
+		}
+	} else if strings.Contains(host, ".") {
+		add(host)
+	}
+	return domains
+}
+
+// goEnv returns the value of a go environment variable, including those set with go env -w
+func goEnv(name string) string {
+	out, err := exec.Command("go", "env", name).Output()
+	if err != nil {
+		return os.Getenv(name)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// starterConfig renders the config written by the init command
+func starterConfig(modulePath string, domains []string, hosts map[string]int, testOnly []string) string {
+	var config strings.Builder
+	config.WriteString("# gogroupimports configuration, generated by gogroupimports init.\n")
+	config.WriteString("# Imports are expected in the groups: builtin, third party, internal private,\n")
+	config.WriteString("# own module and side effect, separated by blank lines.\n\n")
+
+	config.WriteString("# selfModule is the module whose packages form the own module group. It defaults\n")
+	config.WriteString("# to the module of the nearest go.mod.\n")
+	if modulePath == "" {
+		config.WriteString("# selfModule: example.com/app\n\n")
+	} else {
+		config.WriteString("selfModule: " + strconv.Quote(modulePath) + "\n\n")
+	}
+
+	config.WriteString("# internalPrivateDomains are matched as substrings of import paths, imports matching\n")
+	config.WriteString("# one go into the internal private group. Guessed from GOPRIVATE and the module path.\n")
+	writeList(&config, "internalPrivateDomains", domains)
+	if observed := observedHosts(hosts, domains); len(observed) > 0 {
+		config.WriteString("# Other hosts imported from, add the internal ones above:\n")
+		for _, host := range observed {
+			config.WriteString(fmt.Sprintf("#   %s (%d imports)\n", host, hosts[host]))
+		}
+	}
+	config.WriteString("\n")
+
+	if containsAny(modulePath, domains) {
+		config.WriteString("# The own module matches an internal domain, so it is told apart from other\n")
+		config.WriteString("# internal modules with the build list reported by go list.\n")
+		config.WriteString("useGoList: true\n\n")
+	}
+
+	config.WriteString("# testOnlyImports may only be imported from _test.go files. Filled with the test\n")
+	config.WriteString("# frameworks that only tests import so far.\n")
+	writeList(&config, "testOnlyImports", testOnly)
+	config.WriteString("\n")
+
+	config.WriteString("# testPackagePatterns match mock and testdata packages that production code may\n")
+	config.WriteString("# not import. The rule is off until they are set.\n")
+	config.WriteString("# testPackagePatterns: [\"**/mocks\", \"**/mocks/**\", \"**/mock_*\", \"**/testdata/**\"]\n\n")
+
+	config.WriteString("# deprecatedImports maps import paths to their replacements, fixes migrate them.\n")
+	config.WriteString("# deprecatedImports:\n")
+	config.WriteString("#   github.com/pkg/errors: [errors]\n\n")
+
+	config.WriteString("# sideEffectImports lists packages whose blank imports go into a trailing group.\n")
+	config.WriteString("# sideEffectImports: [github.com/lib/pq, net/http/pprof]\n\n")
+
+	config.WriteString("# generatedImports lists generated packages, like protobuf code, that get a group\n")
+	config.WriteString("# of their own after the own module imports.\n")
+	config.WriteString("# generatedImports: [git.corp.com/proto/gen/**]\n")
+	return config.String()
+}
+
+// writeList renders a YAML list, commented out as an example when empty
+func writeList(config *strings.Builder, key string, values []string) {
+	if len(values) == 0 {
+		config.WriteString("# " + key + ": []\n")
+		return
+	}
+	config.WriteString(key + ":\n")
+	for _, value := range values {
+		config.WriteString("  - " + strconv.Quote(value) + "\n")
+	}
+}
+
+// observedHosts returns the imported hosts that are neither well known public hosts nor covered
+// by domains, most used first
+func observedHosts(hosts map[string]int, domains []string) []string {
+	var observed []string
+	for host := range hosts {
+		if !publicHosts[host] && strings.Contains(host, ".") && !containsAny(host+"/", domains) {
+			observed = append(observed, host)
+		}
+	}
+	sort.Slice(observed, func(i, j int) bool {
+		if hosts[observed[i]] != hosts[observed[j]] {
+			return hosts[observed[i]] > hosts[observed[j]]
+		}
+		return observed[i] < observed[j]
+	})
+	return observed
+}
+
+// containsAny reports whether s contains one of substrings
+func containsAny(s string, substrings []string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// inventoryModule lists the packages of one external module and how often they are imported
+type inventoryModule struct {
+	Module   string         `json:"module"`
+	Type     string         `json:"type"`
+	Count    int            `json:"count"`
+	Packages map[string]int `json:"packages"`
+}
+
+// inventory prints every external import grouped by domain and module with usage counts
+func inventory(args []string) int {
+	flags := flag.NewFlagSet("gogroupimports inventory", flag.ExitOnError)
+	configPath := configFlag(flags)
+	format := flags.String("format", formatText, "output format: text or json")
+	_ = flags.Parse(args)
+
+	if *format != formatText && *format != formatJSON {
+		log.Printf("unknown format %q, want %s or %s", *format, formatText, formatJSON)
+		return 2
+	}
+
+	checkers, err := newCheckers(*configPath)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+
+	files, err := goFiles(flags.Args())
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+
+	exitCode := 0
+	modules := make(map[string]*inventoryModule)
+	for _, file := range files {
+		checker, err := checkers.forFile(file)
+		if err != nil {
+			log.Printf("%s: %v", file, err)
+			exitCode = 2
+			continue
+		}
+		imports, err := checker.ListImports(file)
+		if err != nil {
+			log.Printf("%s: %v", file, err)
+			exitCode = 2
+			continue
+		}
+		for _, info := range imports {
+			if info.Type == "builtin" || info.Type == "own_module" {
+				continue
+			}
+			module, ok := modules[info.Module]
+			if !ok {
+				module = &inventoryModule{Module: info.Module, Type: info.Type, Packages: make(map[string]int)}
+				modules[info.Module] = module
+			}
+			module.Count++
+			module.Packages[info.Path]++
+		}
+	}
+
+	sorted := make([]*inventoryModule, 0, len(modules))
+	for _, module := range modules {
+		sorted = append(sorted, module)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Module < sorted[j].Module
+	})
+
+	if *format == formatJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(sorted); err != nil {
+			log.Print(err)
+			return 2
+		}
+		return exitCode
+	}
+
+	domain := ""
+	for _, module := range sorted {
+		if moduleDomain, _, _ := strings.Cut(module.Module, "/"); moduleDomain != domain {
+			domain = moduleDomain
+			fmt.Println(domain)
+		}
+		fmt.Printf("  %s (%s) %d\n", module.Module, module.Type, module.Count)
+
+		packages := make([]string, 0, len(module.Packages))
+		for path := range module.Packages {
+			packages = append(packages, path)
+		}
+		sort.Strings(packages)
+		for _, path := range packages {
+			fmt.Printf("    %s %d\n", path, module.Packages[path])
+		}
+	}
+	return exitCode
+}
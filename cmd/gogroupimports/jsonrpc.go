@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+)
+
+// JSON-RPC error codes used by the language server
+const (
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+// rpcMessage is a JSON-RPC request or notification, the latter without an id
+type rpcMessage struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcError is the error of a failed request
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// rpcConn reads and writes JSON-RPC messages framed with Content-Length headers, as the
+// Language Server Protocol does
+type rpcConn struct {
+	in *textproto.Reader
+
+	mu  sync.Mutex // Guards out, notifications and responses may be written concurrently
+	out io.Writer
+}
+
+func newRPCConn(in io.Reader, out io.Writer) *rpcConn {
+	return &rpcConn{in: textproto.NewReader(bufio.NewReader(in)), out: out}
+}
+
+// read returns the next message
+func (conn *rpcConn) read() (*rpcMessage, error) {
+	header, err := conn.in.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(conn.in.R, body); err != nil {
+		return nil, err
+	}
+	var message rpcMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+	return &message, nil
+}
+
+// reply answers the request with id with result, or with err when it failed
+func (conn *rpcConn) reply(id json.RawMessage, result interface{}, err error) error {
+	response := map[string]interface{}{"jsonrpc": "2.0", "id": id}
+	if err != nil {
+		rpcErr, ok := err.(*rpcError)
+		if !ok {
+			rpcErr = &rpcError{Code: rpcInternalError, Message: err.Error()}
+		}
+		response["error"] = rpcErr
+	} else {
+		response["result"] = result
+	}
+	return conn.write(response)
+}
+
+// notify sends the notification method with params
+func (conn *rpcConn) notify(method string, params interface{}) error {
+	return conn.write(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (conn *rpcConn) write(message interface{}) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if _, err := fmt.Fprintf(conn.out, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = conn.out.Write(body)
+	return err
+}
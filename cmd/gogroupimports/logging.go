@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/hsivakum/gogroupimports"
+)
+
+// Log formats selectable with -log-format
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// jsonLog receives the log lines with -log-format=json, nil for plain text
+var jsonLog *slog.Logger
+
+// setLogFormat selects how the check command logs to stderr. JSON lines carry a level, the
+// message, the id of the run so that concurrent runs can be told apart, and the file and
+// duration a line is about, for ingestion by log pipelines.
+func setLogFormat(format string) error {
+	switch format {
+	case logFormatText:
+		return nil
+	case logFormatJSON:
+	default:
+		return fmt.Errorf("unknown log format %q, want %s or %s", format, logFormatText, logFormatJSON)
+	}
+
+	level := slog.LevelWarn
+	switch {
+	case verbosity >= 2:
+		level = slog.LevelDebug
+	case verbosity == 1:
+		level = slog.LevelInfo
+	}
+	handler := slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level})
+	jsonLog = slog.New(handler).With("run", runID())
+
+	// Everything logged through the log package is an error
+	log.SetPrefix("")
+	log.SetOutput(logWriter{})
+	if verbosity >= 2 {
+		gogroupimports.SetDebugLogger(slog.NewLogLogger(jsonLog.Handler(), slog.LevelDebug))
+	}
+	return nil
+}
+
+// runID returns a random id for the log lines of this run
+func runID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
+
+// logWriter turns the lines of the log package into JSON log lines of error level
+type logWriter struct{}
+
+func (logWriter) Write(p []byte) (int, error) {
+	jsonLog.Error(strings.TrimSpace(string(p)))
+	return len(p), nil
+}
+
+// verbosef logs to stderr when the verbosity is at least level
+func verbosef(level int, format string, args ...interface{}) {
+	verboseAttrs(level, nil, format, args...)
+}
+
+// verboseAttrs logs like verbosef. With -log-format=json the attrs, key value pairs like
+// "file", file, are added to the line, an "error" attribute makes it an error line.
+func verboseAttrs(level int, attrs []interface{}, format string, args ...interface{}) {
+	if verbosity < level {
+		return
+	}
+	if jsonLog == nil {
+		log.Printf(format, args...)
+		return
+	}
+
+	slogLevel := slog.LevelDebug
+	switch level {
+	case 0:
+		slogLevel = slog.LevelWarn
+		for i := 0; i+1 < len(attrs); i += 2 {
+			if attrs[i] == "error" {
+				slogLevel = slog.LevelError
+			}
+		}
+	case 1:
+		slogLevel = slog.LevelInfo
+	}
+	jsonLog.Log(context.Background(), slogLevel, fmt.Sprintf(format, args...), attrs...)
+}
@@ -0,0 +1,488 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hsivakum/gogroupimports"
+)
+
+// Language Server Protocol types, only the fields the server uses
+type (
+	lspPosition struct {
+		Line      int `json:"line"`
+		Character int `json:"character"`
+	}
+	lspRange struct {
+		Start lspPosition `json:"start"`
+		End   lspPosition `json:"end"`
+	}
+	lspDiagnostic struct {
+		Range    lspRange `json:"range"`
+		Severity int      `json:"severity"`
+		Code     string   `json:"code"`
+		Source   string   `json:"source"`
+		Message  string   `json:"message"`
+	}
+	lspFolder struct {
+		URI  string `json:"uri"`
+		Name string `json:"name"`
+	}
+	lspDocument struct {
+		URI     string `json:"uri"`
+		Text    string `json:"text"`
+		Version int    `json:"version"`
+	}
+	lspTextEdit struct {
+		Range   lspRange `json:"range"`
+		NewText string   `json:"newText"`
+	}
+	lspCodeAction struct {
+		Title string `json:"title"`
+		Kind  string `json:"kind"`
+		Edit  struct {
+			Changes map[string][]lspTextEdit `json:"changes"`
+		} `json:"edit"`
+	}
+)
+
+// organizeImports is the code action kind editors run to organize imports, on save too
+const organizeImports = "source.organizeImports"
+
+// lspSeverities maps diagnostic severities to those of the protocol
+var lspSeverities = map[string]int{
+	gogroupimports.SeverityError:   1,
+	gogroupimports.SeverityWarning: 2,
+	gogroupimports.SeverityInfo:    3,
+}
+
+// lspConfigInterval is how often the configs of a folder are looked at for changes, at most,
+// while documents are edited
+const lspConfigInterval = time.Second
+
+// lspRoot is a workspace folder with the checkers of its own config and module
+type lspRoot struct {
+	dir      string
+	checkers *checkerTree
+	stamps   map[string]fileStamp // Of the configs checkers were created from
+	checked  time.Time            // When stamps were last compared with the configs
+}
+
+// lspServer checks the documents open in an editor. Every workspace folder gets the checkers
+// of its own config and go.mod, documents are checked with those of the innermost folder
+// containing them. Documents outside every folder are checked with the config of their module.
+type lspServer struct {
+	conn       *rpcConn
+	configPath string              // Config for every folder instead of their own, when set
+	roots      map[string]*lspRoot // Workspace folders by directory
+	implicit   map[string]*lspRoot // Module roots of documents outside the folders
+	documents  map[string]string   // Text of the open documents by URI
+	failed     map[string]bool     // Folders whose config failed to load, reported once
+	shutdown   bool
+}
+
+// lsp runs the language server on stdin and stdout
+func lsp(args []string) int {
+	flags := flag.NewFlagSet("gogroupimports lsp", flag.ExitOnError)
+	configPath := configFlag(flags)
+	otlpEndpoint := otlpFlag(flags)
+	_ = flags.Parse(args)
+	defer startTracing(*otlpEndpoint)()
+
+	s := &lspServer{
+		conn:       newRPCConn(os.Stdin, os.Stdout),
+		configPath: *configPath,
+		roots:      make(map[string]*lspRoot),
+		implicit:   make(map[string]*lspRoot),
+		documents:  make(map[string]string),
+		failed:     make(map[string]bool),
+	}
+	for {
+		message, err := s.conn.read()
+		if errors.Is(err, io.EOF) {
+			return 1
+		}
+		if err != nil {
+			log.Print(err)
+			return 2
+		}
+		if message.Method == "exit" {
+			if s.shutdown {
+				return 0
+			}
+			return 1
+		}
+
+		result, err := s.handle(message)
+		if message.ID == nil {
+			if err != nil {
+				log.Printf("%s: %v", message.Method, err)
+			}
+			continue
+		}
+		if err := s.conn.reply(message.ID, result, err); err != nil {
+			log.Print(err)
+			return 2
+		}
+	}
+}
+
+// handle dispatches a request or notification and returns the result of requests
+func (s *lspServer) handle(message *rpcMessage) (interface{}, error) {
+	switch message.Method {
+	case "initialize":
+		var params struct {
+			RootURI          string      `json:"rootUri"`
+			WorkspaceFolders []lspFolder `json:"workspaceFolders"`
+		}
+		if err := decodeParams(message, &params); err != nil {
+			return nil, err
+		}
+		folders := params.WorkspaceFolders
+		if len(folders) == 0 && params.RootURI != "" {
+			folders = []lspFolder{{URI: params.RootURI}}
+		}
+		for _, folder := range folders {
+			s.addRoot(folder)
+		}
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync": map[string]interface{}{
+					"openClose": true,
+					"change":    1, // Full documents
+					"save":      map[string]bool{"includeText": true},
+				},
+				"codeActionProvider": map[string]interface{}{
+					"codeActionKinds": []string{organizeImports},
+				},
+				"workspace": map[string]interface{}{
+					"workspaceFolders": map[string]bool{"supported": true, "changeNotifications": true},
+				},
+			},
+			"serverInfo": map[string]string{"name": "gogroupimports"},
+		}, nil
+
+	case "initialized":
+		return nil, nil
+
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "workspace/didChangeWorkspaceFolders":
+		var params struct {
+			Event struct {
+				Added   []lspFolder `json:"added"`
+				Removed []lspFolder `json:"removed"`
+			} `json:"event"`
+		}
+		if err := decodeParams(message, &params); err != nil {
+			return nil, err
+		}
+		for _, folder := range params.Event.Removed {
+			if dir, err := uriPath(folder.URI); err == nil {
+				delete(s.roots, dir)
+				delete(s.failed, dir)
+			}
+		}
+		for _, folder := range params.Event.Added {
+			s.addRoot(folder)
+		}
+		// Documents may belong to another folder now
+		for uri := range s.documents {
+			s.publish(uri)
+		}
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument lspDocument `json:"textDocument"`
+		}
+		if err := decodeParams(message, &params); err != nil {
+			return nil, err
+		}
+		s.documents[params.TextDocument.URI] = params.TextDocument.Text
+		return nil, s.publish(params.TextDocument.URI)
+
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument   lspDocument `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := decodeParams(message, &params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			s.documents[params.TextDocument.URI] = params.ContentChanges[n-1].Text
+		}
+		return nil, s.publish(params.TextDocument.URI)
+
+	case "textDocument/didSave":
+		var params struct {
+			TextDocument lspDocument `json:"textDocument"`
+			Text         *string     `json:"text"`
+		}
+		if err := decodeParams(message, &params); err != nil {
+			return nil, err
+		}
+		if params.Text != nil {
+			s.documents[params.TextDocument.URI] = *params.Text
+		}
+		return nil, s.publish(params.TextDocument.URI)
+
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument lspDocument `json:"textDocument"`
+		}
+		if err := decodeParams(message, &params); err != nil {
+			return nil, err
+		}
+		delete(s.documents, params.TextDocument.URI)
+		return nil, s.conn.notify("textDocument/publishDiagnostics", map[string]interface{}{
+			"uri":         params.TextDocument.URI,
+			"diagnostics": []lspDiagnostic{},
+		})
+
+	case "textDocument/codeAction":
+		var params struct {
+			TextDocument lspDocument `json:"textDocument"`
+			Context      struct {
+				Only []string `json:"only"`
+			} `json:"context"`
+		}
+		if err := decodeParams(message, &params); err != nil {
+			return nil, err
+		}
+		return s.codeActions(params.TextDocument.URI, params.Context.Only)
+	}
+
+	if message.ID != nil {
+		return nil, &rpcError{Code: rpcMethodNotFound, Message: "method not supported: " + message.Method}
+	}
+	return nil, nil
+}
+
+// addRoot adds the workspace folder with the checkers of its config
+func (s *lspServer) addRoot(folder lspFolder) {
+	dir, err := uriPath(folder.URI)
+	if err != nil {
+		log.Printf("workspace folder %s: %v", folder.URI, err)
+		return
+	}
+	s.roots[dir] = &lspRoot{dir: dir}
+}
+
+// rootFor returns the innermost workspace folder containing path, or the module root of path
+// when no folder does
+func (s *lspServer) rootFor(path string) *lspRoot {
+	var root *lspRoot
+	for dir, candidate := range s.roots {
+		if (path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))) && (root == nil || len(dir) > len(root.dir)) {
+			root = candidate
+		}
+	}
+	if root != nil {
+		return root
+	}
+
+	dir := filepath.Dir(path)
+	if moduleRoot, err := moduleRootOf(dir, make(map[string]string)); err == nil && moduleRoot != "" {
+		dir = moduleRoot
+	}
+	if s.implicit[dir] == nil {
+		s.implicit[dir] = &lspRoot{dir: dir}
+	}
+	return s.implicit[dir]
+}
+
+// checkersFor returns the checkers of root, loading its config the first time and again
+// whenever one of the configs changed, which is looked at every lspConfigInterval
+func (s *lspServer) checkersFor(root *lspRoot) (*checkerTree, error) {
+	if root.checkers != nil {
+		if time.Since(root.checked) < lspConfigInterval {
+			return root.checkers, nil
+		}
+		root.checked = time.Now()
+		current := configStampsIn(root.dir, root.checkers, s.configPath)
+		if !stampsChanged(root.stamps, current) {
+			root.stamps = current
+			return root.checkers, nil
+		}
+		log.Printf("reloading the config of %s", root.dir)
+		gogroupimports.ClearCaches()
+		root.checkers = nil
+		delete(s.failed, root.dir)
+	}
+	metaData, err := loadConfigIn(root.dir, s.configPath)
+	if err != nil {
+		return nil, err
+	}
+	if root.checkers, err = newCheckerTreeIn(root.dir, metaData, nil); err != nil {
+		return nil, err
+	}
+	root.stamps, root.checked = configStampsIn(root.dir, root.checkers, s.configPath), time.Now()
+	return root.checkers, nil
+}
+
+// publish checks the open document uri and sends its diagnostics
+func (s *lspServer) publish(uri string) error {
+	text, ok := s.documents[uri]
+	if !ok {
+		return nil
+	}
+	path, err := uriPath(uri)
+	if err != nil || !strings.HasSuffix(path, ".go") {
+		return nil
+	}
+
+	checker, err := s.checkerFor(path)
+	if checker == nil {
+		return err
+	}
+	diagnostics := []lspDiagnostic{}
+	found, err := checker.CheckSource(context.Background(), path, []byte(text))
+	if err == nil {
+		for _, diagnostic := range found {
+			diagnostics = append(diagnostics, lspDiagnostic{
+				Range:    lineRange(text, diagnostic.Line, diagnostic.Column),
+				Severity: lspSeverities[diagnostic.Severity],
+				Code:     diagnostic.Rule,
+				Source:   "gogroupimports",
+				Message:  diagnostic.Message,
+			})
+		}
+	}
+	// Sources that don't parse are left to the Go language server
+	return s.conn.notify("textDocument/publishDiagnostics", map[string]interface{}{
+		"uri":         uri,
+		"diagnostics": diagnostics,
+	})
+}
+
+// checkerFor returns the checker for the document at path, nil when the config of its folder
+// failed to load, which is reported to the user once
+func (s *lspServer) checkerFor(path string) (*gogroupimports.Checker, error) {
+	root := s.rootFor(path)
+	checkers, err := s.checkersFor(root)
+	if err != nil {
+		// Every document of the folder fails the same way
+		if !s.failed[root.dir] {
+			s.failed[root.dir] = true
+			return nil, s.conn.notify("window/showMessage", map[string]interface{}{
+				"type":    1,
+				"message": fmt.Sprintf("gogroupimports: %s: %v", root.dir, err),
+			})
+		}
+		return nil, nil
+	}
+	return checkers.forFile(path)
+}
+
+// codeActions returns the organize imports action for the open document uri, which replaces
+// the document with its fixed source. There is none when only other kinds were asked for or
+// nothing needs fixing.
+func (s *lspServer) codeActions(uri string, only []string) ([]lspCodeAction, error) {
+	actions := []lspCodeAction{}
+	if len(only) > 0 && !slices.ContainsFunc(only, func(kind string) bool {
+		return kind == organizeImports || strings.HasPrefix(organizeImports, kind+".")
+	}) {
+		return actions, nil
+	}
+	text, ok := s.documents[uri]
+	if !ok {
+		return actions, nil
+	}
+	path, err := uriPath(uri)
+	if err != nil || !strings.HasSuffix(path, ".go") {
+		return actions, nil
+	}
+	checker, err := s.checkerFor(path)
+	if checker == nil {
+		return actions, err
+	}
+	fixed, _, err := checker.FixSource(context.Background(), path, []byte(text))
+	if err != nil || string(fixed) == text {
+		// Sources that don't parse can't be fixed, their errors are for the Go language server
+		return actions, nil
+	}
+
+	action := lspCodeAction{Title: "Organize imports (gogroupimports)", Kind: organizeImports}
+	action.Edit.Changes = map[string][]lspTextEdit{
+		uri: {{Range: documentRange(text), NewText: string(fixed)}},
+	}
+	return append(actions, action), nil
+}
+
+// documentRange returns the range covering all of text
+func documentRange(text string) lspRange {
+	lines := strings.Split(text, "\n")
+	last := len(lines) - 1
+	return lspRange{End: lspPosition{Line: last, Character: utf16Length(lines[last])}}
+}
+
+// lineRange returns the range from the 1-based byte column of the 1-based line of text to the
+// end of the line, in the UTF-16 code units of the protocol
+func lineRange(text string, line, column int) lspRange {
+	lines := strings.Split(text, "\n")
+	if line < 1 || line > len(lines) {
+		return lspRange{}
+	}
+	content := strings.TrimSuffix(lines[line-1], "\r")
+	start := min(max(column-1, 0), len(content))
+	return lspRange{
+		Start: lspPosition{Line: line - 1, Character: utf16Length(content[:start])},
+		End:   lspPosition{Line: line - 1, Character: utf16Length(content)},
+	}
+}
+
+// utf16Length returns the length of s in UTF-16 code units
+func utf16Length(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 && r <= utf8.MaxRune {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// uriPath returns the file path of a file URI
+func uriPath(uri string) (string, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Scheme != "file" {
+		return "", fmt.Errorf("unsupported URI scheme %q", parsed.Scheme)
+	}
+	path := filepath.FromSlash(parsed.Path)
+	// file:///C:/dir on Windows
+	if len(path) > 2 && path[0] == filepath.Separator && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.Clean(path), nil
+}
+
+// decodeParams decodes the parameters of message into params
+func decodeParams(message *rpcMessage, params interface{}) error {
+	if err := json.Unmarshal(message.Params, params); err != nil {
+		return &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
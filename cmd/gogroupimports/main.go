@@ -0,0 +1,714 @@
+// Command gogroupimports checks that Go imports are split into builtin, third party,
+// internal private and own module groups.
+//
+// Usage:
+//
+//	gogroupimports [-config file] [-goroot dir] [-index file] [-format text|json|template] [-template text] [-q | -v | -vv] [-progress] [-w [-backup suffix]] [-this-module-only] [-gitignore=false] [-templates] [-files-from file [-0]] [-staged] [-report-unclassified] [-fixable-only | -unfixable-only] [-fail-on severities] [-shard N/M] [-j n] [-timeout duration] [-log-format text|json] [-cpuprofile file] [-memprofile file] [-trace file] [-watch [-watch-debounce duration] [-watch-ignore globs]] [path | @file ...]
+//	gogroupimports rename-module [-config file] [-backup suffix] old/path new/path [path ...]
+//	gogroupimports summary [-config file] [-by file|package] [path ...]
+//	gogroupimports inventory [-config file] [-format text|json] [path ...]
+//	gogroupimports index [-config file] [-o file] [path ...]
+//	gogroupimports init [-o file] [-f] [path ...]
+//	gogroupimports migrate [-o file] gci [.golangci.yml] | reviser [goimports-reviser flags]
+//	gogroupimports completion bash|zsh|fish|powershell
+//	gogroupimports doctor [-config file] [-goroot dir]
+//	gogroupimports bot -github|-gitlab|-bitbucket [-config file] [-repo owner/name -pr n | -event file | -listen addr] [-api url] [-dry-run] [-v]
+//	gogroupimports lsp [-config file] [-otlp-endpoint url]
+//	gogroupimports merge-results [-config file] [-format text|json|template] [-template text] [-fail-on severities] file ...
+//	gogroupimports install-hooks [-config file] [-pre-commit] [-uninstall] [-f]
+//	gogroupimports goimports [-config file] [-l] [-w] [-d] [-local prefixes] [-srcdir dir] [path ...]
+//	gogroupimports serve [-config file] [-addr host:port] [-reload-interval duration] [-playground-rate n] [-tokens file [-token-rate n]] [-tls-cert file -tls-key file [-client-ca file]] [-max-request-size bytes] [-client-rate n] [-max-concurrent n] [-otlp-endpoint url] [-v] [-log-format text|json]
+//
+// Paths may be files or directories, which are walked recursively. A trailing /... is
+// accepted for familiarity with the go tool. Without paths the current directory is checked.
+// Arguments that aren't files or directories are package patterns, like std or
+// example.com/app/..., and select every Go file of the matching packages including tests.
+// Arguments spelled like paths, absolute or starting with ./ or ../, are reported missing
+// instead. Files named by several arguments, like a directory and a file in it, are checked
+// once.
+//
+// The config is JSON, or YAML when its name ends in .yaml or .yml. Its "extends" setting may
+// name a config shared by many repositories, as a path or an https URL. Remote configs are
+// cached, revalidated with their ETag and used from the cache when the server is unreachable.
+// Extended configs signed with minisign are verified against the public keys listed in
+// "configPublicKeys"; -require-signed-config refuses extended configs without a valid signature.
+// Every config is validated when loaded: unknown settings, values of the wrong type and invalid
+// patterns are all reported with their line and refuse the config, settings that are repeated,
+// overlap or have no effect are logged as warnings.
+//
+// The own module defaults to the module of the nearest go.mod. Legacy projects without one
+// that are checked out under GOPATH/src are named by their place there instead: the
+// repository root, or github.com/org/repo on the public hosts. In Bazel workspaces without
+// go.mod files, "gazelle": true takes it from the closest # gazelle:prefix directive of the
+// BUILD files, and "bazelPrefixes" from a file mapping workspace directories to prefixes. Both
+// are looked up for the directory of every file.
+//
+// Standard library packages are those of the GOROOT reported by go env, which follows the
+// toolchain directive of go.mod and GOTOOLCHAIN, rather than the one the binary was built with.
+// The "goroot" setting or -goroot names another Go installation, e.g. in containers without
+// the go command.
+//
+// The init command writes a commented starter config to .gogroupimports.yaml, guessing the
+// internal domains from GOPRIVATE and the module path and the test-only imports from the
+// imports of test files.
+//
+// The doctor command checks the toolchain, GOROOT, go.mod, config and cache directory and
+// prints the settings the checks will actually use, the first thing to look at when imports
+// are classified unexpectedly.
+//
+// The bot command reviews the Go files changed by a pull request, named with -repo and -pr or
+// taken from the CI environment. Problems on lines of the diff are posted as inline comments,
+// with a suggestion where the fix only touches lines of one hunk; the others are listed in the
+// review summary. -github posts a pull request review, reading the pull request from the
+// webhook payload at -event when not named, which defaults to the one GitHub Actions provides.
+// -gitlab starts merge request discussions and -bitbucket attaches a Code Insights report
+// annotating every problem. With -listen it receives GitHub or GitLab webhooks instead,
+// reviewing pull requests as they are opened or pushed to. Tokens are read from GITHUB_TOKEN,
+// GITLAB_TOKEN or BITBUCKET_TOKEN, webhook secrets from GITHUB_WEBHOOK_SECRET or
+// GITLAB_WEBHOOK_SECRET.
+//
+// The lsp command runs a language server on stdin and stdout, publishing the diagnostics of
+// the open documents. Every workspace folder is checked with its own config and go.mod, or
+// with -config when given; documents outside the folders with those of their module. The fix
+// is offered as the source.organizeImports code action, which editors run on save. Changed
+// configs apply to the documents checked after the change, like with serve.
+// -otlp-endpoint exports the spans of the checks and fixes to an OpenTelemetry collector over
+// OTLP/HTTP, as does the flag of serve; it defaults to the traces endpoint of the
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT environment variables.
+//
+// The install-hooks command writes a git pre-commit hook checking the staged contents of the
+// staged Go files, or with -pre-commit adds a local hook to the .pre-commit-config.yaml of the pre-commit framework.
+// Running it again upgrades a hook installed by an older version, -uninstall removes it. A
+// pre-commit hook of another tool is only replaced with -f, which keeps it as pre-commit.orig
+// and restores it on -uninstall.
+//
+// The goimports command takes the flags of goimports and behaves like it: without flags the
+// formatted files, or stdin, are printed, -l lists the files that would change, -w rewrites
+// them and -d shows their diffs. -local names the own module, further comma separated prefixes
+// are internal private domains. A link named goimports to the binary runs this command, so
+// editors and scripts running goimports need no changes. Imports are regrouped and formatted,
+// never added or removed.
+//
+// The serve command runs the checks as an HTTP service: sources POSTed to /check?path=file.go
+// and /fix?path=file.go are answered with their diagnostics as JSON, and the fixed source for
+// /fix. /healthz answers while the process runs and /readyz once the config is loaded, for
+// orchestrator probes, as does the grpc.health.v1 Check method for gRPC probes. Paths must be
+// relative and stay within the directory the server runs in. Changed configs, including nested
+// and extended ones, are reloaded without a restart, remote ones are fetched again every
+// minute; a config that fails to load keeps the previous one in use. Sources POSTed to
+// /playground/format with inline settings are fixed without a config, for docs sites, at
+// most -playground-rate times a minute per client. /metrics reports requests, violations by
+// rule, cache hits and check latency in the Prometheus text format. -tokens requires a bearer
+// token of a file of client names, tokens and requests per minute for /check and /fix;
+// -tls-cert and -tls-key serve HTTPS, and -client-ca requires client certificates.
+// -max-request-size, -client-rate and -max-concurrent bound request bodies, the requests per
+// minute of every client address and the requests answered at once. -otlp-endpoint exports
+// the spans of checks and fixes to an OpenTelemetry collector.
+//
+// The migrate command converts the gci settings of a golangci-lint config or goimports-reviser
+// flags into a gogroupimports config, noting what can't be carried over.
+//
+// Every setting can be overridden with an environment variable named GOGROUPIMPORTS_ and the
+// setting in upper snake case, e.g. GOGROUPIMPORTS_INTERNAL_PRIVATE_DOMAINS=corp.com,corp.dev.
+//
+// -staged checks the contents of the files staged in the git index instead of those in the work
+// tree, so that what is committed is checked, not the edits left unstaged.
+//
+// -files-from reads the paths to check from a file or, with -, from stdin, in addition to the
+// paths given as arguments. -0 separates them by NUL bytes so that paths with spaces and
+// newlines pass through pipelines safely, e.g. git diff --name-only -z | gogroupimports
+// -files-from=- -0. Listed paths that don't exist anymore or aren't Go files are skipped.
+// Arguments like @files.txt name such lists as well, with one path per line, for change sets
+// computed by an earlier CI step that would exceed the command line length limit.
+//
+// Walking the top directory of a git repository skips the paths matched by its .gitignore
+// files, like build output and local scratch files; -gitignore=false walks them too.
+//
+// -templates checks code generation templates as well, .gotmpl and .tmpl files like
+// model.go.tmpl, so that the templates behind thousands of generated files follow the
+// convention too. Template actions are opaque: actions on lines of their own count as comments,
+// others as identifiers, and -w puts them back unchanged or leaves the template alone.
+//
+// -report-unclassified lists the third party imports that look internal instead of checking:
+// those matching GOPRIVATE or sharing the host of the own module. It suggests the
+// internalPrivateDomains that would move them to the internal private group.
+//
+// -this-module-only skips the files of nested modules when run at the root of a repository
+// holding several modules, so that each module is checked on its own with its own config.
+//
+// Config files in subdirectories override the settings of the config above them for the files
+// below them, see checkerTree.
+//
+// -log-format=json writes the log lines on stderr as JSON objects with a level, the id of the
+// run and the file and duration they are about, for log pipelines; the diagnostics on stdout
+// follow -format.
+//
+// -watch keeps running after the first check and checks the files that change, polling for
+// changes. Saves are collected until none happened for -watch-debounce, so that bursts of them
+// are checked once. -watch-ignore lists globs, like *_gen.go or **/mocks/**, of files whose
+// changes are ignored, so that code generators don't trigger storms of checks. Changed configs
+// are reloaded and every file is checked again.
+//
+// -j sets how many files are checked at once, the number of CPUs by default; lower it in CI
+// containers with a CPU limit or to leave room for an editor. The diagnostics of every file are
+// printed as soon as it and the files before it are checked, so that long runs show their
+// results early and can be interrupted once they did, while the output keeps the order of the
+// paths whatever -j.
+//
+// -timeout bounds the whole run, the files left when it expires are reported as not checked.
+// The parseTimeout, maxFileSize and maxImports settings bound the time spent on a single file,
+// files exceeding them are reported as skipped rather than stalling the run.
+//
+// The exit code is 1 when a file has diagnostics of error severity and 2 when files couldn't
+// be checked. Rules demoted to warnings with the "severities" setting are reported without
+// failing the run. The "failOn" setting lists the severities that fail instead, and -fail-on
+// overrides it for one run, e.g. -fail-on error,warning in CI for warnings that only advise
+// locally.
+//
+// With -w the imports are fixed in place first and only the problems left are reported.
+// -backup keeps the original of every rewritten file next to it, e.g. -backup=.orig writes
+// main.go.orig, for bulk fixes outside version control.
+//
+// Every diagnostic tells whether -w fixes it, in the fixable field of the JSON output and
+// {{.Fixable}} of templates. -fixable-only reports just those and -unfixable-only the others,
+// which need a person to look at them; the exit code only counts the diagnostics reported.
+//
+// -shard N/M checks only the files of shard N out of M, picked by the hash of their path, so
+// that M CI jobs split a huge repository between them deterministically. Each job writes its
+// diagnostics with -format json, which records the files that couldn't be checked as
+// diagnostics of the "unchecked" rule, and merge-results combines those files into one sorted
+// report. It exits with 2 when a shard couldn't check a file and with 1 when a diagnostic has a
+// severity of -fail-on, the failOn setting of the config by default.
+//
+// -cpuprofile, -memprofile and -trace write a CPU profile, a heap profile taken at the end and
+// an execution trace of the run, for go tool pprof and go tool trace, to attach to reports of
+// slow runs.
+//
+// The index command records the stdlib packages, module build lists and vanity roots the
+// checks need. Passing the result to -index, or setting indexFile in the config, skips all
+// probing of the environment, which speeds up CI runs.
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hsivakum/gogroupimports"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("gogroupimports: ")
+
+	// A link named goimports to the binary stands in for goimports
+	if name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe"); name == "goimports" {
+		os.Exit(goimportsCompat(os.Args[1:]))
+	}
+
+	args := os.Args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		case "rename-module":
+			os.Exit(renameModule(args[1:]))
+		case "summary":
+			os.Exit(summary(args[1:]))
+		case "inventory":
+			os.Exit(inventory(args[1:]))
+		case "index":
+			os.Exit(index(args[1:]))
+		case "init":
+			os.Exit(initConfig(args[1:]))
+		case "migrate":
+			os.Exit(migrate(args[1:]))
+		case "completion":
+			os.Exit(completion(args[1:]))
+		case "doctor":
+			os.Exit(doctor(args[1:]))
+		case "serve":
+			os.Exit(serve(args[1:]))
+		case "bot":
+			os.Exit(bot(args[1:]))
+		case "lsp":
+			os.Exit(lsp(args[1:]))
+		case "merge-results":
+			os.Exit(mergeResults(args[1:]))
+		case "install-hooks":
+			os.Exit(installHooks(args[1:]))
+		case "goimports":
+			os.Exit(goimportsCompat(args[1:]))
+		}
+	}
+	os.Exit(check(args))
+}
+
+// check reports every file whose imports are not properly grouped
+func check(args []string) int {
+	flags := flag.NewFlagSet("gogroupimports", flag.ExitOnError)
+	configPath := configFlag(flags)
+	goroot := gorootFlag(flags)
+	indexPath := flags.String("index", "", "classification index written by the index command, overrides indexFile of the config")
+	format := flags.String("format", formatText, "output format: text, json or template")
+	templateText := flags.String("template", "", "text/template executed for every diagnostic with -format=template, e.g. '{{.Path}}:{{.Line}} {{.Rule}}'")
+	quiet := flags.Bool("q", false, "print nothing, only set the exit code")
+	verbose := flags.Bool("v", false, "print skipped paths and the time spent on every file")
+	veryVerbose := flags.Bool("vv", false, "like -v, and print cache hits and misses")
+	showProgress := flags.Bool("progress", isTerminal(os.Stderr), "print the number of files done and the current package on stderr")
+	fix := flags.Bool("w", false, "fix the imports in place before checking")
+	backup := flags.String("backup", "", "with -w, keep the original of every rewritten file with this suffix, e.g. .orig")
+	thisModuleOnly := flags.Bool("this-module-only", false, "skip files of nested modules, directories with a go.mod of their own")
+	flags.BoolVar(&useGitignore, "gitignore", true, "skip paths matched by .gitignore files when walking the top directory of a git repository")
+	flags.BoolVar(&includeTemplates, "templates", false, "check the Go source of code generation templates, .gotmpl and .tmpl files, treating template actions as opaque")
+	filesFrom := flags.String("files-from", "", "read the paths to check from this file, - for stdin, one per line")
+	timeout := flags.Duration("timeout", 0, "stop checking once the run took this long, e.g. 5m, reporting the files left unchecked")
+	unclassified := flags.Bool("report-unclassified", false, "instead of checking, list third party imports that look internal, matching GOPRIVATE or the host of the own module, and suggest internalPrivateDomains for them")
+	staged := flags.Bool("staged", false, "check the contents staged in the git index instead of the work tree, like the pre-commit hook")
+	nul := flags.Bool("0", false, "with -files-from, the paths are separated by NUL bytes, like the output of git diff -z or find -print0")
+	logFormat := flags.String("log-format", logFormatText, "format of the log lines on stderr: text or json, with the run id, file and duration of every line")
+	fixableOnly := flags.Bool("fixable-only", false, "report only the diagnostics -w fixes")
+	unfixableOnly := flags.Bool("unfixable-only", false, "report only the diagnostics -w can't fix")
+	shardFlag := flags.String("shard", "", "check only shard N of M, e.g. 2/8, splitting the files across M CI jobs by the hash of their path")
+	failOn := flags.String("fail-on", "", "comma separated severities that set exit code 1, overriding the failOn setting, e.g. error,warning")
+	watch := flags.Bool("watch", false, "keep running and check the files that change, and all files when a config changes")
+	watchDebounce := flags.Duration("watch-debounce", 300*time.Millisecond, "with -watch, wait until files stopped changing for this long before checking them")
+	watchIgnore := flags.String("watch-ignore", "", "with -watch, comma separated globs of files whose changes don't trigger checks, e.g. *_gen.go,**/mocks/**")
+	jobs := flags.Int("j", runtime.NumCPU(), "check this many files at once, e.g. the CPU limit of a CI container")
+	profiling := profileFlags(flags)
+	_ = flags.Parse(args)
+
+	switch {
+	case *quiet:
+		verbosity = -1
+	case *veryVerbose:
+		verbosity = 2
+		gogroupimports.SetDebugLogger(log.New(os.Stderr, "gogroupimports: ", 0))
+	case *verbose:
+		verbosity = 1
+	}
+	if err := setLogFormat(*logFormat); err != nil {
+		log.Print(err)
+		return 2
+	}
+	if *fixableOnly && *unfixableOnly {
+		log.Print("-fixable-only and -unfixable-only exclude each other")
+		return 2
+	}
+	if *jobs < 1 {
+		log.Printf("invalid -j %d, want at least 1", *jobs)
+		return 2
+	}
+	if *staged && (*fix || *watch) {
+		log.Print("-staged can't be used with -w or -watch")
+		return 2
+	}
+	stopProfiles, err := profiling.start()
+	defer func() {
+		if err := stopProfiles(); err != nil {
+			log.Print(err)
+		}
+	}()
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+
+	write, err := newFormatter(*format, *templateText)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	var failOnOverride []string
+	if *failOn != "" {
+		if failOnOverride, err = gogroupimports.ParseFailOn(*failOn); err != nil {
+			log.Printf("invalid -fail-on: %v", err)
+			return 2
+		}
+	}
+
+	loadCheckers := func() (*checkerTree, error) {
+		metaData, err := loadConfig(*configPath)
+		if err != nil {
+			return nil, err
+		}
+		overrides := make(map[string]interface{})
+		if *indexPath != "" {
+			overrides["indexFile"] = *indexPath
+		}
+		if *goroot != "" {
+			overrides["goroot"] = *goroot
+		}
+		return newCheckerTree(metaData, overrides)
+	}
+	checkers, err := loadCheckers()
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+
+	var selected *shard
+	if *shardFlag != "" {
+		parsed, err := parseShard(*shardFlag)
+		if err != nil {
+			log.Print(err)
+			return 2
+		}
+		selected = &parsed
+	}
+
+	// A list read from stdin can only be read once
+	var listed []string
+	if *filesFrom != "" {
+		if listed, err = listedGoFiles(*filesFrom, *nul); err != nil {
+			log.Print(err)
+			return 2
+		}
+	}
+	paths, manifests := splitManifests(flags.Args())
+	for _, manifest := range manifests {
+		found, err := listedGoFiles(manifest, false)
+		if err != nil {
+			log.Print(err)
+			return 2
+		}
+		listed = append(listed, found...)
+	}
+	listFiles := func() ([]string, error) {
+		var files []string
+		if *filesFrom == "" && len(manifests) == 0 || len(paths) > 0 {
+			if files, err = goFiles(paths); err != nil {
+				return nil, err
+			}
+		}
+		files = append(files, listed...)
+		if selected != nil {
+			files = selected.filter(files)
+		}
+		if *thisModuleOnly {
+			return moduleFiles(files)
+		}
+		return files, nil
+	}
+
+	if *unclassified {
+		files, err := listFiles()
+		if err != nil {
+			log.Print(err)
+			return 2
+		}
+		return reportUnclassified(checkers, files)
+	}
+
+	run := &checkRun{
+		checkers:     checkers,
+		write:        write,
+		fix:          *fix,
+		backup:       *backup,
+		showProgress: *showProgress,
+		timeout:      *timeout,
+		jobs:         *jobs,
+		failOn:       failOnOverride,
+		recordErrors: *format == formatJSON,
+		staged:       *staged,
+	}
+	if *fixableOnly || *unfixableOnly {
+		run.fixable = fixableOnly
+	}
+	if *watch {
+		return run.watch(listFiles, loadCheckers, *configPath, *watchDebounce, splitList(*watchIgnore))
+	}
+	files, err := listFiles()
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	return run.checkFiles(files)
+}
+
+// checkRun checks files with the options of the check command
+type checkRun struct {
+	checkers     *checkerTree
+	write        formatter
+	fix          bool
+	backup       string
+	showProgress bool
+	timeout      time.Duration
+	jobs         int      // Files checked at once
+	fixable      *bool    // Report only the diagnostics whose Fixable is this, when set
+	failOn       []string // Severities failing the run instead of those of the configs, when set
+	recordErrors bool     // Write a ruleUnchecked diagnostic for every file that couldn't be checked
+	staged       bool     // Check the contents in the git index instead of the work tree
+}
+
+// checkFiles checks files with run.jobs workers, printing the diagnostics of every file in order
+// as soon as it and the files before it are checked, and returns the exit code
+func (run *checkRun) checkFiles(files []string) int {
+	// Progress would be interleaved with the verbose lines
+	bar := newProgress(run.showProgress && verbosity == 0, len(files))
+	defer bar.clear()
+
+	var deadline time.Time
+	if run.timeout > 0 {
+		deadline = time.Now().Add(run.timeout)
+	}
+
+	// Buffered for every file, so that workers never wait when printing stops early
+	results := make(chan fileResult, len(files))
+	next := make(chan int)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(next)
+		for i := range files {
+			select {
+			case next <- i:
+			case <-done:
+				return
+			}
+		}
+	}()
+	for range min(max(run.jobs, 1), len(files)) {
+		go func() {
+			for i := range next {
+				if !deadline.IsZero() && time.Now().After(deadline) {
+					results <- fileResult{index: i, file: files[i], timedOut: true}
+					continue
+				}
+				result := run.checkFile(files[i])
+				result.index = i
+				results <- result
+			}
+		}()
+	}
+
+	exitCode := 0
+	unchecked := 0
+	// The records of files that couldn't be checked keep shards from losing them, see mergeResults
+	record := func(file, message string) error {
+		if !run.recordErrors {
+			return nil
+		}
+		return run.write(os.Stdout, gogroupimports.Diagnostic{
+			Path:     file,
+			Line:     1,
+			Column:   1,
+			Rule:     ruleUnchecked,
+			Severity: gogroupimports.SeverityError,
+			Message:  message,
+		})
+	}
+	emit := func(result fileResult) error {
+		file := result.file
+		if result.timedOut {
+			unchecked++
+			return record(file, "not checked, the run took longer than -timeout "+run.timeout.String())
+		}
+		if result.fixed {
+			verboseAttrs(1, []interface{}{"file", file}, "fixed %s", file)
+		}
+		if result.err == nil {
+			verboseAttrs(1, []interface{}{"file", file, "duration", result.elapsed}, "checked %s in %s", file, result.elapsed)
+		}
+		bar.step(file)
+		if result.err != nil {
+			bar.clear()
+			verboseAttrs(0, []interface{}{"file", file, "error", result.err}, "%s: %v", file, result.err)
+			exitCode = 2
+			return record(file, result.err.Error())
+		}
+		if len(result.diagnostics) == 0 {
+			return nil
+		}
+
+		if result.fails {
+			exitCode = max(exitCode, 1)
+		}
+		if verbosity < 0 {
+			return nil
+		}
+		bar.clear()
+		for _, diagnostic := range result.diagnostics {
+			if err := run.write(os.Stdout, diagnostic); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Files finish in any order but are printed in the order given, each as soon as those
+	// before it are done, so that the output doesn't depend on -j
+	pending := make(map[int]fileResult)
+	printed := 0
+	for range files {
+		result := <-results
+		pending[result.index] = result
+		for result, ok := pending[printed]; ok; result, ok = pending[printed] {
+			delete(pending, printed)
+			printed++
+			if err := emit(result); err != nil {
+				log.Print(err)
+				return 2
+			}
+		}
+	}
+	if unchecked > 0 {
+		bar.clear()
+		verboseAttrs(0, []interface{}{"error", "timeout", "files", unchecked},
+			"%d files not checked, the run took longer than -timeout %s", unchecked, run.timeout)
+		return 2
+	}
+	return exitCode
+}
+
+// fileResult is the outcome of checking one file of a run
+type fileResult struct {
+	index       int // Of the file among those of the run
+	file        string
+	diagnostics []gogroupimports.Diagnostic
+	fails       bool // The diagnostics fail the run
+	fixed       bool // -w rewrote the file
+	elapsed     time.Duration
+	err         error
+	timedOut    bool // The file wasn't checked, the run took longer than -timeout
+}
+
+// checkFile fixes file when asked to and checks it. It is called by several workers at once.
+func (run *checkRun) checkFile(file string) fileResult {
+	start := time.Now()
+	checker, err := run.checkers.forFile(file)
+	if err != nil {
+		return fileResult{file: file, err: err}
+	}
+	result := fileResult{file: file}
+	if run.fix {
+		if result.fixed, err = fixFile(checker, file, run.backup); err != nil {
+			return fileResult{file: file, err: err}
+		}
+	}
+	if run.staged {
+		var src []byte
+		if src, err = stagedContent(file); err != nil {
+			return fileResult{file: file, err: err}
+		}
+		result.diagnostics, result.err = checker.CheckSource(context.Background(), file, src)
+	} else {
+		result.diagnostics, result.err = checker.Check(file)
+	}
+	if result.err == nil && run.fixable != nil {
+		result.diagnostics = slices.DeleteFunc(result.diagnostics, func(diagnostic gogroupimports.Diagnostic) bool {
+			return diagnostic.Fixable != *run.fixable
+		})
+	}
+	if run.failOn != nil {
+		result.fails = gogroupimports.FailsOn(result.diagnostics, run.failOn)
+	} else {
+		result.fails = checker.Fails(result.diagnostics)
+	}
+	result.elapsed = time.Since(start)
+	return result
+}
+
+// verbosity selects how much the check command prints: -1 for nothing, 0 for diagnostics
+// and errors, 1 and 2 for increasingly detailed progress
+var verbosity int
+
+// renameModule rewrites the imports of one module path to another across the given paths
+func renameModule(args []string) int {
+	flags := flag.NewFlagSet("gogroupimports rename-module", flag.ExitOnError)
+	configPath := configFlag(flags)
+	backup := flags.String("backup", "", "keep the original of every rewritten file with this suffix, e.g. .orig")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: gogroupimports rename-module [-config file] [-backup suffix] old/path new/path [path ...]")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return 2
+	}
+	oldPath, newPath := flags.Arg(0), flags.Arg(1)
+
+	checkers, err := newCheckers(*configPath)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+
+	files, err := goFiles(flags.Args()[2:])
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+
+	exitCode := 0
+	for _, file := range files {
+		checker, err := checkers.forFile(file)
+		if err != nil {
+			log.Printf("%s: %v", file, err)
+			return 2
+		}
+		renamed, diagnostics, err := checker.RenameModule(file, oldPath, newPath)
+		if err != nil {
+			log.Printf("%s: %v", file, err)
+			return 2
+		}
+		if len(diagnostics) > 0 {
+			fmt.Println(gogroupimports.Diagnostics(diagnostics))
+			if checker.Fails(diagnostics) {
+				exitCode = 1
+			}
+		}
+		if renamed == nil {
+			continue
+		}
+		if err := writeFile(file, renamed, *backup); err != nil {
+			log.Print(err)
+			return 2
+		}
+		fmt.Println(file)
+	}
+	return exitCode
+}
+
+// fixFile fixes the imports of file in place and reports whether it changed. Problems the
+// fixer leaves behind are not returned, the check following the fix reports them.
+func fixFile(checker *gogroupimports.Checker, file, backup string) (bool, error) {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return false, err
+	}
+	fixed, _, err := checker.Fix(file)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(src, fixed) {
+		return false, nil
+	}
+	return true, writeFile(file, fixed, backup)
+}
+
+// writeFile replaces the contents of an existing file, keeping its permissions. With a backup
+// suffix the previous contents are saved first to the path with the suffix appended.
+func writeFile(path string, content []byte, backup string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if backup != "" {
+		original, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path+backup, original, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("writing backup: %w", err)
+		}
+	}
+	return os.WriteFile(path, content, info.Mode().Perm())
+}
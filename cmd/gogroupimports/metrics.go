@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hsivakum/gogroupimports"
+)
+
+// latencyBuckets are the upper bounds in seconds of the check latency histogram buckets
+var latencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metrics counts the requests of the server for Prometheus, served at /metrics in its text
+// exposition format
+type metrics struct {
+	mu         sync.Mutex
+	requests   map[[2]string]uint64         // By endpoint and status code
+	violations map[string]uint64            // Diagnostics answered, by rule
+	latency    map[string]*latencyHistogram // By endpoint
+}
+
+// latencyHistogram counts the durations falling in each of latencyBuckets
+type latencyHistogram struct {
+	buckets []uint64 // Not cumulative, the last one is +Inf
+	count   uint64
+	sum     float64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		requests:   make(map[[2]string]uint64),
+		violations: make(map[string]uint64),
+		latency:    make(map[string]*latencyHistogram),
+	}
+}
+
+// observe records a request to endpoint answered with status after duration
+func (m *metrics) observe(endpoint string, status int, duration time.Duration, diagnostics []gogroupimports.Diagnostic) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[[2]string{endpoint, strconv.Itoa(status)}]++
+	for _, diagnostic := range diagnostics {
+		m.violations[diagnostic.Rule]++
+	}
+	histogram := m.latency[endpoint]
+	if histogram == nil {
+		histogram = &latencyHistogram{buckets: make([]uint64, len(latencyBuckets)+1)}
+		m.latency[endpoint] = histogram
+	}
+	seconds := duration.Seconds()
+	histogram.buckets[sort.SearchFloat64s(latencyBuckets, seconds)]++
+	histogram.count++
+	histogram.sum += seconds
+}
+
+// serveHTTP answers /metrics
+func (m *metrics) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+// write writes the metrics in the Prometheus text exposition format
+func (m *metrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP gogroupimports_requests_total Requests answered, by endpoint and status code.")
+	fmt.Fprintln(w, "# TYPE gogroupimports_requests_total counter")
+	requests := make([][2]string, 0, len(m.requests))
+	for key := range m.requests {
+		requests = append(requests, key)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i][0] < requests[j][0] || requests[i][0] == requests[j][0] && requests[i][1] < requests[j][1]
+	})
+	for _, key := range requests {
+		fmt.Fprintf(w, "gogroupimports_requests_total{endpoint=%s,code=%s} %d\n", labelValue(key[0]), labelValue(key[1]), m.requests[key])
+	}
+
+	fmt.Fprintln(w, "# HELP gogroupimports_violations_total Diagnostics answered, by rule.")
+	fmt.Fprintln(w, "# TYPE gogroupimports_violations_total counter")
+	for _, rule := range sortedKeys(m.violations) {
+		fmt.Fprintf(w, "gogroupimports_violations_total{rule=%s} %d\n", labelValue(rule), m.violations[rule])
+	}
+
+	hits, misses := gogroupimports.CacheStats()
+	fmt.Fprintln(w, "# HELP gogroupimports_cache_hits_total Module and stdlib lookups found in the cache.")
+	fmt.Fprintln(w, "# TYPE gogroupimports_cache_hits_total counter")
+	fmt.Fprintf(w, "gogroupimports_cache_hits_total %d\n", hits)
+	fmt.Fprintln(w, "# HELP gogroupimports_cache_misses_total Module and stdlib lookups missing from the cache.")
+	fmt.Fprintln(w, "# TYPE gogroupimports_cache_misses_total counter")
+	fmt.Fprintf(w, "gogroupimports_cache_misses_total %d\n", misses)
+
+	fmt.Fprintln(w, "# HELP gogroupimports_check_duration_seconds Time to answer check and fix requests, by endpoint.")
+	fmt.Fprintln(w, "# TYPE gogroupimports_check_duration_seconds histogram")
+	for _, endpoint := range sortedKeys(m.latency) {
+		histogram := m.latency[endpoint]
+		var cumulative uint64
+		for i, count := range histogram.buckets {
+			cumulative += count
+			le := "+Inf"
+			if i < len(latencyBuckets) {
+				le = strconv.FormatFloat(latencyBuckets[i], 'g', -1, 64)
+			}
+			fmt.Fprintf(w, "gogroupimports_check_duration_seconds_bucket{endpoint=%s,le=%q} %d\n", labelValue(endpoint), le, cumulative)
+		}
+		fmt.Fprintf(w, "gogroupimports_check_duration_seconds_sum{endpoint=%s} %g\n", labelValue(endpoint), histogram.sum)
+		fmt.Fprintf(w, "gogroupimports_check_duration_seconds_count{endpoint=%s} %d\n", labelValue(endpoint), histogram.count)
+	}
+}
+
+// labelValue quotes a label value as the exposition format wants it
+func labelValue(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hsivakum/gogroupimports"
+)
+
+// migratedConfig is a config converted from another import grouping tool together with notes
+// about the parts gogroupimports can't express
+type migratedConfig struct {
+	source          string
+	selfModule      string
+	internalDomains []string
+	sideEffects     []string
+	notes           []string
+}
+
+// migrate converts the import grouping config of gci or goimports-reviser
+func migrate(args []string) int {
+	flags := flag.NewFlagSet("gogroupimports migrate", flag.ExitOnError)
+	output := flags.String("o", "", "file to write the config to instead of stdout")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: gogroupimports migrate [-o file] gci [.golangci.yml]")
+		fmt.Fprintln(flags.Output(), "       gogroupimports migrate [-o file] reviser [goimports-reviser flags]")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return 2
+	}
+
+	var config *migratedConfig
+	var err error
+	switch tool := flags.Arg(0); tool {
+	case "gci":
+		path := flags.Arg(1)
+		if path == "" {
+			path = ".golangci.yml"
+			if _, err := os.Stat(path); err != nil {
+				path = ".golangci.yaml"
+			}
+		}
+		config, err = migrateGci(path)
+	case "reviser":
+		config, err = migrateReviser(flags.Args()[1:])
+	default:
+		log.Printf("unknown tool %q, want gci or reviser", tool)
+		return 2
+	}
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+
+	w := io.Writer(os.Stdout)
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			log.Print(err)
+			return 2
+		}
+		defer file.Close()
+		w = file
+	}
+	if _, err := io.WriteString(w, config.render()); err != nil {
+		log.Print(err)
+		return 2
+	}
+	return 0
+}
+
+// migrateGci converts the gci settings of a golangci-lint config
+func migrateGci(path string) (*migratedConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var golangci map[string]interface{}
+	if err := yaml.Unmarshal(content, &golangci); err != nil {
+		return nil, fmt.Errorf("invalid golangci-lint config %s: %w", path, err)
+	}
+
+	// golangci-lint v1 keeps gci under linters-settings, v2 under formatters.settings
+	gci, ok := lookupYAML(golangci, "linters-settings", "gci")
+	if !ok {
+		gci, ok = lookupYAML(golangci, "formatters", "settings", "gci")
+	}
+	if !ok {
+		return nil, fmt.Errorf("%s has no gci settings", path)
+	}
+
+	config := &migratedConfig{source: "the gci settings of " + path}
+	if prefixes, ok := gci["local-prefixes"].(string); ok {
+		config.internalDomains = append(config.internalDomains, splitList(prefixes)...)
+	}
+
+	sections, _ := gci["sections"].([]interface{})
+	var order []string
+	prefixSections := 0
+	for _, section := range sections {
+		name, _ := section.(string)
+		switch {
+		case name == "standard" || name == "std":
+			order = append(order, "builtin")
+		case name == "default":
+			order = append(order, "public_open_source_or_third_party")
+		case strings.HasPrefix(name, "prefix(") && strings.HasSuffix(name, ")"):
+			prefixSections++
+			order = append(order, "internal_private")
+			config.internalDomains = append(config.internalDomains, splitList(name[len("prefix("):len(name)-1])...)
+		case name == "localmodule":
+			order = append(order, "own_module")
+		case name == "blank":
+			order = append(order, "side_effect")
+			config.sideEffects = []string{"**"}
+		default:
+			config.notes = append(config.notes, fmt.Sprintf("the gci section %q has no equivalent", name))
+		}
+	}
+	if prefixSections > 1 {
+		config.notes = append(config.notes, "all prefix sections are merged into the single internal private group")
+	}
+	// Without custom-order gci sorts its sections in a fixed order matching ours
+	if customOrder, _ := gci["custom-order"].(bool); customOrder {
+		config.checkOrder(order)
+	}
+	return config, nil
+}
+
+// migrateReviser converts goimports-reviser command line flags
+func migrateReviser(args []string) (*migratedConfig, error) {
+	flags := flag.NewFlagSet("goimports-reviser", flag.ContinueOnError)
+	companyPrefixes := flags.String("company-prefixes", "", "")
+	projectName := flags.String("project-name", "", "")
+	importsOrder := flags.String("imports-order", "std,general,company,project", "")
+	// Flags that don't affect grouping
+	flags.Bool("rm-unused", false, "")
+	flags.Bool("set-alias", false, "")
+	flags.Bool("format", false, "")
+	flags.Bool("recursive", false, "")
+	flags.Bool("use-cache", false, "")
+	flags.Bool("apply-to-generated-files", false, "")
+	flags.String("output", "", "")
+	flags.String("excludes", "", "")
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("goimports-reviser flags: %w", err)
+	}
+
+	config := &migratedConfig{
+		source:          "goimports-reviser " + strings.Join(args, " "),
+		selfModule:      *projectName,
+		internalDomains: splitList(*companyPrefixes),
+	}
+	var order []string
+	for _, group := range splitList(*importsOrder) {
+		switch group {
+		case "std":
+			order = append(order, "builtin")
+		case "general":
+			order = append(order, "public_open_source_or_third_party")
+		case "company":
+			order = append(order, "internal_private")
+		case "project":
+			order = append(order, "own_module")
+		case "blanked":
+			order = append(order, "side_effect")
+			config.sideEffects = []string{"**"}
+		default:
+			config.notes = append(config.notes, fmt.Sprintf("the goimports-reviser group %q has no equivalent", group))
+		}
+	}
+	config.checkOrder(order)
+	return config, nil
+}
+
+// checkOrder notes when the groups of the other tool were ordered differently
+func (config *migratedConfig) checkOrder(order []string) {
+	rank := make(map[string]int)
+	for i, importType := range gogroupimports.ImportTypes() {
+		rank[importType] = i
+	}
+	for i := 1; i < len(order); i++ {
+		if rank[order[i-1]] > rank[order[i]] {
+			config.notes = append(config.notes, "the groups were ordered differently, gogroupimports always expects builtin, third party, internal private, own module and side effect imports in that order")
+			return
+		}
+	}
+}
+
+// render formats the config as YAML
+func (config *migratedConfig) render() string {
+	var out strings.Builder
+	out.WriteString("# gogroupimports configuration converted from " + config.source + ".\n")
+	for _, note := range config.notes {
+		out.WriteString("# Note: " + note + ".\n")
+	}
+	out.WriteString("\n")
+	if config.selfModule != "" {
+		out.WriteString("selfModule: " + strconv.Quote(config.selfModule) + "\n")
+	}
+	writeList(&out, "internalPrivateDomains", config.internalDomains)
+	if len(config.sideEffects) > 0 {
+		writeList(&out, "sideEffectImports", config.sideEffects)
+	}
+	return out.String()
+}
+
+// lookupYAML follows keys through nested YAML mappings
+func lookupYAML(node map[string]interface{}, keys ...string) (map[string]interface{}, bool) {
+	for _, key := range keys {
+		next, ok := node[key].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		node = next
+	}
+	return node, true
+}
+
+// splitList splits a comma separated list, dropping empty elements
+func splitList(list string) []string {
+	return splitListBy(list, ",")
+}
+
+// splitListBy splits list at every separator, dropping empty elements
+func splitListBy(list, separator string) []string {
+	var elements []string
+	for _, element := range strings.Split(list, separator) {
+		if element = strings.TrimSpace(element); element != "" {
+			elements = append(elements, element)
+		}
+	}
+	return elements
+}
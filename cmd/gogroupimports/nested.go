@@ -0,0 +1,183 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hsivakum/gogroupimports"
+)
+
+// checkerTree hands out the checker for every file. Like .editorconfig files, config files in
+// the directories below the current one override the settings of the configs above them for
+// the files they contain, e.g. to relax the rules for examples/. Nested configs replace
+// whole settings, lists are not merged, and one with "root": true inherits nothing. The
+// environment overrides every config, and command line flags override the environment. In
+// Bazel workspaces without go.mod files the own module is the prefix of the directory of
+// every file, see bazelProject, unless a config sets it.
+type checkerTree struct {
+	mu        sync.Mutex // Guards configs and checkers, the workers of a run share the tree
+	cwd       string
+	base      map[string]interface{}             // The config of the current directory
+	overrides map[string]interface{}             // Settings of command line flags
+	configs   map[string]string                  // Nested config by directory, "" for none
+	checkers  map[string]*gogroupimports.Checker // By the deepest directory with a nested config and the Bazel prefix
+	bazel     bool                               // The own module of base is the Bazel prefix of cwd
+	prefixes  map[string]string                  // Bazel prefix by directory
+}
+
+// newCheckers creates the checkers for the config at path, see loadConfig
+func newCheckers(path string) (*checkerTree, error) {
+	metaData, err := loadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return newCheckerTree(metaData, nil)
+}
+
+// newCheckerTree creates the checkers for the loaded config metaData, with the settings of
+// command line flags in overrides
+func newCheckerTree(metaData, overrides map[string]interface{}) (*checkerTree, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return newCheckerTreeIn(cwd, metaData, overrides)
+}
+
+// newCheckerTreeIn is newCheckerTree for the absolute directory dir instead of the current one
+func newCheckerTreeIn(dir string, metaData, overrides map[string]interface{}) (*checkerTree, error) {
+	for key, value := range overrides {
+		metaData[key] = value
+	}
+	root, err := checkerFor(metaData)
+	if err != nil {
+		return nil, err
+	}
+	tree := &checkerTree{
+		cwd:       dir,
+		base:      metaData,
+		overrides: overrides,
+		configs:   make(map[string]string),
+		checkers:  map[string]*gogroupimports.Checker{"": root},
+		prefixes:  make(map[string]string),
+	}
+
+	// loadConfig names the own module by the Bazel prefix of dir when there is no go.mod,
+	// files in other packages of the workspace have prefixes of their own
+	if modulePath, err := findModulePath(dir); err == nil && modulePath == "" {
+		if prefix, err := bazelProject(dir, metaData); err == nil && prefix != "" && prefix == metaData["selfModule"] {
+			tree.bazel = true
+			tree.prefixes[dir] = prefix
+			tree.checkers["\x00"+prefix] = root
+		}
+	}
+	return tree, nil
+}
+
+// bazelPrefix returns the Bazel prefix of dir, "" when it has none
+func (tree *checkerTree) bazelPrefix(dir string) (string, error) {
+	prefix, ok := tree.prefixes[dir]
+	if ok {
+		return prefix, nil
+	}
+	prefix, err := bazelProject(dir, tree.base)
+	if err != nil {
+		return "", err
+	}
+	tree.prefixes[dir] = prefix
+	return prefix, nil
+}
+
+// forFile returns the checker for file
+func (tree *checkerTree) forFile(file string) (*gogroupimports.Checker, error) {
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+	dir, err := filepath.Abs(filepath.Dir(file))
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(tree.cwd, dir)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return tree.checkers[""], nil
+	}
+
+	// The configs between the current directory and file, outermost first
+	var configs []string
+	deepest := ""
+	current := tree.cwd
+	for _, element := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, element)
+		if config := tree.nestedConfig(current); config != "" {
+			configs = append(configs, config)
+			deepest = current
+		}
+	}
+	key := deepest
+	prefix := ""
+	if tree.bazel {
+		if prefix, err = tree.bazelPrefix(dir); err != nil {
+			return nil, err
+		}
+		key += "\x00" + prefix
+	}
+	if checker, ok := tree.checkers[key]; ok {
+		return checker, nil
+	}
+
+	metaData := make(map[string]interface{})
+	for key, value := range tree.base {
+		metaData[key] = value
+	}
+	configuredModule := false
+	for _, config := range configs {
+		nested, err := readConfigFile(config, tree.base["configPublicKeys"])
+		if err != nil {
+			return nil, err
+		}
+		if root, _ := nested["root"].(bool); root {
+			metaData = map[string]interface{}{"selfModule": tree.base["selfModule"]}
+		}
+		delete(nested, "root")
+		if _, ok := nested["selfModule"]; ok {
+			configuredModule = true
+		}
+		for key, value := range nested {
+			metaData[key] = value
+		}
+	}
+	if prefix != "" && !configuredModule {
+		metaData["selfModule"] = prefix
+	}
+	// The environment overrides every config, the flags override the environment
+	if err := applyEnv(metaData); err != nil {
+		return nil, err
+	}
+	for key, value := range tree.overrides {
+		metaData[key] = value
+	}
+
+	checker, err := checkerFor(metaData)
+	if err != nil {
+		return nil, err
+	}
+	tree.checkers[key] = checker
+	return checker, nil
+}
+
+// nestedConfig returns the config file in dir, or "" when there is none
+func (tree *checkerTree) nestedConfig(dir string) string {
+	config, ok := tree.configs[dir]
+	if ok {
+		return config
+	}
+	for _, name := range []string{defaultConfigFile, initConfigFile} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			config = filepath.Join(dir, name)
+			break
+		}
+	}
+	tree.configs[dir] = config
+	return config
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hsivakum/gogroupimports"
+)
+
+// Batching of the exported spans
+const (
+	otlpInterval  = 5 * time.Second // Spans are sent at least this often
+	otlpBatchSize = 512             // Spans are sent early once this many are waiting
+	otlpTimeout   = 10 * time.Second
+)
+
+// otlpFlag defines the -otlp-endpoint flag of the serving commands. It defaults to the traces
+// endpoint of the standard OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT.
+func otlpFlag(flags *flag.FlagSet) *string {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint == "" && base != "" {
+		endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+	return flags.String("otlp-endpoint", endpoint, "OTLP/HTTP traces endpoint to export the spans of checks and fixes to, like http://localhost:4318/v1/traces")
+}
+
+// startTracing exports the spans of checks and fixes to endpoint with SetTracer and returns the
+// function flushing the spans left and turning tracing off. An empty endpoint does nothing.
+func startTracing(endpoint string) func() {
+	if endpoint == "" {
+		return func() {}
+	}
+	exporter := &otlpExporter{endpoint: endpoint, client: &http.Client{Timeout: otlpTimeout}, full: make(chan struct{}, 1), done: make(chan struct{})}
+	gogroupimports.SetTracer(exporter)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		exporter.run()
+	}()
+	return func() {
+		gogroupimports.SetTracer(nil)
+		close(exporter.done)
+		<-stopped
+	}
+}
+
+// otlpSpanKey is the context key of the span its spans are children of
+type otlpSpanKey struct{}
+
+// spanContext identifies a span and its trace
+type spanContext struct {
+	traceID [16]byte
+	spanID  [8]byte
+}
+
+// withTraceParent makes the spans started with ctx children of the span of a W3C traceparent
+// header, like "00-<trace id>-<span id>-01", so that they join the trace of the caller. Invalid
+// headers are ignored.
+func withTraceParent(ctx context.Context, header string) context.Context {
+	parts := strings.Split(header, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return ctx
+	}
+	var parent spanContext
+	if _, err := hex.Decode(parent.traceID[:], []byte(parts[1])); err != nil {
+		return ctx
+	}
+	if _, err := hex.Decode(parent.spanID[:], []byte(parts[2])); err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, otlpSpanKey{}, parent)
+}
+
+// otlpExporter is a gogroupimports.Tracer sending its spans in batches to an OTLP/HTTP
+// collector, encoded as JSON
+type otlpExporter struct {
+	endpoint string
+	client   *http.Client
+	full     chan struct{} // Signals that a batch is ready before the interval
+	done     chan struct{} // Closed to flush the spans left and stop
+
+	mu    sync.Mutex
+	spans []otlpSpan
+}
+
+// otlpSpan is a span in the JSON encoding of OTLP
+type otlpSpan struct {
+	TraceID      string          `json:"traceId"`
+	SpanID       string          `json:"spanId"`
+	ParentSpanID string          `json:"parentSpanId,omitempty"`
+	Name         string          `json:"name"`
+	Kind         int             `json:"kind"`
+	Start        string          `json:"startTimeUnixNano"`
+	End          string          `json:"endTimeUnixNano"`
+	Attributes   []otlpAttribute `json:"attributes"`
+}
+
+// otlpAttribute is a string attribute of a span
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+// Start begins a span, a child of the span of ctx or of a new trace
+func (exporter *otlpExporter) Start(ctx context.Context, name, filename string) (context.Context, func()) {
+	parent, hasParent := ctx.Value(otlpSpanKey{}).(spanContext)
+	current := spanContext{traceID: parent.traceID}
+	if !hasParent {
+		_, _ = rand.Read(current.traceID[:])
+	}
+	_, _ = rand.Read(current.spanID[:])
+
+	span := otlpSpan{
+		TraceID: hex.EncodeToString(current.traceID[:]),
+		SpanID:  hex.EncodeToString(current.spanID[:]),
+		Name:    name,
+		Kind:    1, // Internal
+		Start:   strconv.FormatInt(time.Now().UnixNano(), 10),
+	}
+	if hasParent {
+		span.ParentSpanID = hex.EncodeToString(parent.spanID[:])
+	}
+	file := otlpAttribute{Key: "file"}
+	file.Value.StringValue = filename
+	span.Attributes = []otlpAttribute{file}
+
+	var once sync.Once
+	return context.WithValue(ctx, otlpSpanKey{}, current), func() {
+		once.Do(func() {
+			span.End = strconv.FormatInt(time.Now().UnixNano(), 10)
+			exporter.mu.Lock()
+			exporter.spans = append(exporter.spans, span)
+			full := len(exporter.spans) >= otlpBatchSize
+			exporter.mu.Unlock()
+			if full {
+				select {
+				case exporter.full <- struct{}{}:
+				default:
+				}
+			}
+		})
+	}
+}
+
+// run sends the ended spans every otlpInterval or once a batch is full, until done is closed
+func (exporter *otlpExporter) run() {
+	ticker := time.NewTicker(otlpInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-exporter.full:
+		case <-exporter.done:
+			exporter.flush()
+			return
+		}
+		exporter.flush()
+	}
+}
+
+// flush sends the ended spans. Spans that can't be sent are dropped, tracing must not hold up
+// or break the service.
+func (exporter *otlpExporter) flush() {
+	exporter.mu.Lock()
+	spans := exporter.spans
+	exporter.spans = nil
+	exporter.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+	if err := exporter.send(spans); err != nil {
+		verbosef(0, "exporting %d spans to %s: %v", len(spans), exporter.endpoint, err)
+	}
+}
+
+// send posts spans to the collector
+func (exporter *otlpExporter) send(spans []otlpSpan) error {
+	service := otlpAttribute{Key: "service.name"}
+	service.Value.StringValue = "gogroupimports"
+	request := map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{"attributes": []otlpAttribute{service}},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": "github.com/hsivakum/gogroupimports"},
+				"spans": spans,
+			}},
+		}},
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	response, err := exporter.client.Post(exporter.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("collector answered %s", response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"text/template"
+
+	"github.com/hsivakum/gogroupimports"
+)
+
+// Output formats selectable with -format
+const (
+	formatText     = "text"
+	formatJSON     = "json"
+	formatTemplate = "template"
+)
+
+// formatter writes a single diagnostic
+type formatter func(w io.Writer, diagnostic gogroupimports.Diagnostic) error
+
+// newFormatter returns the formatter for format. The template is only used by the template
+// format and is executed with a gogroupimports.Diagnostic, e.g. '{{.Path}}:{{.Line}} {{.Rule}}'.
+func newFormatter(format, text string) (formatter, error) {
+	switch format {
+	case formatText:
+		return func(w io.Writer, diagnostic gogroupimports.Diagnostic) error {
+			_, err := fmt.Fprintln(w, diagnostic)
+			return err
+		}, nil
+	case formatJSON:
+		return func(w io.Writer, diagnostic gogroupimports.Diagnostic) error {
+			return json.NewEncoder(w).Encode(diagnostic)
+		}, nil
+	case formatTemplate:
+		if text == "" {
+			return nil, errors.New("-format=template requires -template")
+		}
+		tmpl, err := template.New("diagnostic").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -template: %w", err)
+		}
+		return func(w io.Writer, diagnostic gogroupimports.Diagnostic) error {
+			if err := tmpl.Execute(w, diagnostic); err != nil {
+				return err
+			}
+			_, err := fmt.Fprintln(w)
+			return err
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, want %s, %s or %s", format, formatText, formatJSON, formatTemplate)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hsivakum/gogroupimports"
+)
+
+// maxPlaygroundCheckers bounds the checkers the playground keeps for the settings it was sent
+const maxPlaygroundCheckers = 64
+
+// playgroundSettings are the only settings the playground accepts: those classifying and
+// arranging imports by their paths alone. Others, like deprecatedImports or useGoList, load
+// packages, read files, run go list or reach the network on behalf of anyone who can reach the
+// server, so new settings stay unavailable until they are known to be safe.
+var playgroundSettings = map[string]bool{
+	"internalPrivateDomains": true, "selfModule": true, "vanityImports": true,
+	"sideEffectImports": true, "sideEffectComment": true, "generatedImports": true,
+	"groupByHost": true, "hostOrder": true, "mergeInternalAndOwnModule": true,
+	"caseInsensitiveSort": true, "sortOrder": true, "sortPriority": true,
+	"factorImports": true, "packageImportSpacing": true,
+	"requireMajorVersionAliases": true, "majorVersionAliases": true,
+	"testOnlyImports": true, "testPackagePatterns": true,
+	"toolsFiles": true, "includeIgnoredFiles": true,
+	"maxFileSize": true, "maxImports": true, "severities": true, "failOn": true,
+}
+
+// playgroundRequest is the JSON body of /playground/format requests
+type playgroundRequest struct {
+	Source   string                 `json:"source"`
+	Filename string                 `json:"filename"` // Only its base name is used, defaults to main.go
+	Settings map[string]interface{} `json:"settings"` // Keys of the config file
+}
+
+// playground formats sources with the settings sent along, for docs sites demoing the house
+// style. It needs no config and no authentication, so it only accepts settings that don't touch
+// the file system or network, and every client is rate limited.
+type playground struct {
+	limiter *rateLimiter
+	maxSize int64 // Of request bodies
+	metrics *metrics
+
+	mu       sync.Mutex
+	checkers map[string]*gogroupimports.Checker // By settings, encoded as JSON
+}
+
+func newPlayground(perMinute int, maxSize int64, metrics *metrics) *playground {
+	return &playground{limiter: newRateLimiter(perMinute), maxSize: maxSize, metrics: metrics, checkers: make(map[string]*gogroupimports.Checker)}
+}
+
+// format answers POST /playground/format with the fixed source and its diagnostics
+func (p *playground) format(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	status, response := p.answer(w, r)
+	writeJSON(w, status, response)
+	p.metrics.observe(r.URL.Path, status, time.Since(start), response.Diagnostics)
+	verbosef(1, "%s from %s %d", r.URL.Path, clientAddress(r), status)
+}
+
+func (p *playground) answer(w http.ResponseWriter, r *http.Request) (int, serveResponse) {
+	response := serveResponse{Diagnostics: []gogroupimports.Diagnostic{}}
+	fail := func(status int, err error) (int, serveResponse) {
+		response.Error = err.Error()
+		return status, response
+	}
+	if r.Method != http.MethodPost {
+		return fail(http.StatusMethodNotAllowed, errors.New("use POST"))
+	}
+	if !p.limiter.allow(clientAddress(r)) {
+		w.Header().Set("Retry-After", "60")
+		return fail(http.StatusTooManyRequests, errors.New("too many requests, try again later"))
+	}
+	var request playgroundRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, p.maxSize)).Decode(&request); err != nil {
+		return fail(http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+	}
+	checker, err := p.checkerFor(request.Settings)
+	if err != nil {
+		return fail(http.StatusBadRequest, err)
+	}
+
+	filename := "main.go"
+	if request.Filename != "" {
+		filename = filepath.Base(request.Filename)
+	}
+	fixed, diagnostics, err := checker.FixSource(r.Context(), filename, []byte(request.Source))
+	if err != nil {
+		return fail(http.StatusUnprocessableEntity, err)
+	}
+	source := string(fixed)
+	response.Source = &source
+	if diagnostics != nil {
+		response.Diagnostics = diagnostics
+	}
+	return http.StatusOK, response
+}
+
+// checkerFor returns the checker for settings, created the first time they are used
+func (p *playground) checkerFor(settings map[string]interface{}) (*gogroupimports.Checker, error) {
+	var unavailable []string
+	for setting := range settings {
+		if !playgroundSettings[setting] {
+			unavailable = append(unavailable, setting)
+		}
+	}
+	if len(unavailable) > 0 {
+		sort.Strings(unavailable)
+		return nil, fmt.Errorf("%s isn't available in the playground", strings.Join(unavailable, ", "))
+	}
+	key, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if checker, ok := p.checkers[string(key)]; ok {
+		return checker, nil
+	}
+	parsed, err := gogroupimports.ParseSettings(settings)
+	if err != nil {
+		return nil, fmt.Errorf("invalid settings: %w", err)
+	}
+	parsed.DisableCache = true
+	checker, err := gogroupimports.NewChecker(parsed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid settings: %w", err)
+	}
+	if len(p.checkers) >= maxPlaygroundCheckers {
+		clear(p.checkers)
+	}
+	p.checkers[string(key)] = checker
+	return checker, nil
+}
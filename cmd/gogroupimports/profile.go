@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// profiles holds the files named by the profiling flags of the check command, empty when not
+// profiling
+type profiles struct {
+	cpu, mem, trace *string
+}
+
+// profileFlags registers -cpuprofile, -memprofile and -trace on flags
+func profileFlags(flags *flag.FlagSet) *profiles {
+	return &profiles{
+		cpu:   flags.String("cpuprofile", "", "write a CPU profile of the run to `file`, for go tool pprof"),
+		mem:   flags.String("memprofile", "", "write a heap profile to `file` at the end of the run, for go tool pprof"),
+		trace: flags.String("trace", "", "write an execution trace of the run to `file`, for go tool trace"),
+	}
+}
+
+// start starts the CPU profile and the trace. The returned stop function ends them and writes
+// the heap profile, it must be called once the run is done, also when start fails.
+func (p *profiles) start() (stop func() error, err error) {
+	var stops []func() error
+	stop = func() error {
+		var errs []error
+		for i := len(stops) - 1; i >= 0; i-- {
+			errs = append(errs, stops[i]())
+		}
+		return errors.Join(errs...)
+	}
+
+	if *p.cpu != "" {
+		file, err := os.Create(*p.cpu)
+		if err != nil {
+			return stop, err
+		}
+		if err := pprof.StartCPUProfile(file); err != nil {
+			file.Close()
+			return stop, fmt.Errorf("starting the CPU profile: %w", err)
+		}
+		stops = append(stops, func() error {
+			pprof.StopCPUProfile()
+			return file.Close()
+		})
+	}
+	if *p.trace != "" {
+		file, err := os.Create(*p.trace)
+		if err != nil {
+			return stop, err
+		}
+		if err := trace.Start(file); err != nil {
+			file.Close()
+			return stop, fmt.Errorf("starting the trace: %w", err)
+		}
+		stops = append(stops, func() error {
+			trace.Stop()
+			return file.Close()
+		})
+	}
+	if *p.mem != "" {
+		// Created up front so that a bad path fails before the run rather than after it
+		file, err := os.Create(*p.mem)
+		if err != nil {
+			return stop, err
+		}
+		stops = append(stops, func() error {
+			// Up to date statistics of what is still live at the end of the run
+			runtime.GC()
+			if err := pprof.WriteHeapProfile(file); err != nil {
+				file.Close()
+				return fmt.Errorf("writing the heap profile: %w", err)
+			}
+			return file.Close()
+		})
+	}
+	return stop, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// progressInterval limits how often the progress line is redrawn
+const progressInterval = 100 * time.Millisecond
+
+// progress draws a "files done / total, current package" line on a terminal
+type progress struct {
+	w       io.Writer
+	total   int
+	done    int
+	drawn   bool
+	lastRun time.Time
+}
+
+// newProgress returns a progress line for total files, or nil when it is disabled. A nil
+// progress ignores every call.
+func newProgress(enabled bool, total int) *progress {
+	if !enabled {
+		return nil
+	}
+	return &progress{w: os.Stderr, total: total}
+}
+
+// isTerminal reports whether file is attached to a terminal
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// step records that file was checked
+func (p *progress) step(file string) {
+	if p == nil {
+		return
+	}
+	p.done++
+	if p.done < p.total && time.Since(p.lastRun) < progressInterval {
+		return
+	}
+	p.lastRun = time.Now()
+	fmt.Fprintf(p.w, "\r\033[K%d/%d %s", p.done, p.total, filepath.Dir(file))
+	p.drawn = true
+}
+
+// clear removes the progress line so other output starts on a clean line
+func (p *progress) clear() {
+	if p == nil || !p.drawn {
+		return
+	}
+	fmt.Fprint(p.w, "\r\033[K")
+	p.drawn = false
+	p.lastRun = time.Time{}
+}
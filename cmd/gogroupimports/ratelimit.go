@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxRateBuckets bounds the clients a rateLimiter remembers, full buckets are dropped beyond it
+const maxRateBuckets = 10000
+
+// rateLimiter allows every key a number of requests per minute, with bursts of up to that
+// many requests
+type rateLimiter struct {
+	perMinute int
+
+	mu      sync.Mutex
+	buckets map[string]*rateBucket
+}
+
+// rateBucket holds the requests a key may still send, refilled as time passes
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{perMinute: perMinute, buckets: make(map[string]*rateBucket)}
+}
+
+// allow reports whether key may send another request now, taking it from its bucket if so
+func (l *rateLimiter) allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	capacity := float64(l.perMinute)
+	bucket, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxRateBuckets {
+			l.dropFull(now)
+		}
+		bucket = &rateBucket{tokens: capacity, last: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = min(capacity, bucket.tokens+now.Sub(bucket.last).Minutes()*capacity)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// dropFull forgets the keys whose buckets refilled completely, they start out full anyway
+func (l *rateLimiter) dropFull(now time.Time) {
+	for key, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Minutes()*float64(l.perMinute) >= float64(l.perMinute) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// clientAddress returns the IP address the request r came from
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileStamp identifies a version of a file, the zero value stands for a missing file
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	sum     [sha256.Size]byte // Of the contents of remote configs, which have no modification time
+}
+
+// remoteConfigInterval is how often the remote configs extended are fetched again to tell
+// whether they changed
+const remoteConfigInterval = time.Minute
+
+// remoteStamps holds the remote configs fetched by configStamps, by URL
+var remoteStamps = struct {
+	sync.Mutex
+	configs map[string]remoteConfig
+}{configs: make(map[string]remoteConfig)}
+
+// remoteConfig is a remote config fetched by configStamps
+type remoteConfig struct {
+	stamp   fileStamp
+	content []byte
+	fetched time.Time
+}
+
+// configStamps returns the stamps of every config the checkers of tree depend on: the config
+// at configPath or the default one, the local configs they extend and the nested configs of
+// the directories visited so far, including those that don't exist yet. Remote configs they
+// extend are fetched again every remoteConfigInterval. tree is nil when no checkers could be
+// created.
+func configStamps(tree *checkerTree, configPath string) map[string]fileStamp {
+	return configStampsIn("", tree, configPath)
+}
+
+// configStampsIn is configStamps with the default config in dir instead of the current directory
+func configStampsIn(dir string, tree *checkerTree, configPath string) map[string]fileStamp {
+	var configs []string
+	if configPath != "" {
+		configs = append(configs, configPath)
+	} else {
+		configs = append(configs, filepath.Join(dir, defaultConfigFile), filepath.Join(dir, initConfigFile))
+	}
+	var dirs []string
+	if tree != nil {
+		tree.mu.Lock()
+		for dir := range tree.configs {
+			dirs = append(dirs, dir)
+		}
+		tree.mu.Unlock()
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		configs = append(configs, filepath.Join(dir, defaultConfigFile), filepath.Join(dir, initConfigFile))
+	}
+
+	stamps := make(map[string]fileStamp)
+	for _, config := range configs {
+		addConfigStamps(stamps, config, 0)
+	}
+	return stamps
+}
+
+// addConfigStamps adds the stamp of config and of the configs it extends to stamps
+func addConfigStamps(stamps map[string]fileStamp, config string, depth int) {
+	if _, ok := stamps[config]; ok || depth > maxExtendsDepth {
+		return
+	}
+	var content []byte
+	if strings.HasPrefix(config, "https://") {
+		content = addRemoteStamp(stamps, config)
+	} else {
+		info, err := os.Stat(config)
+		if err != nil {
+			stamps[config] = fileStamp{}
+			return
+		}
+		stamps[config] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		content, _ = os.ReadFile(config)
+	}
+	if content == nil {
+		return
+	}
+
+	metaData, err := decodeConfig(config, content)
+	if err != nil {
+		return
+	}
+	location, _ := metaData["extends"].(string)
+	if location == "" || strings.Contains(location, "://") && !strings.HasPrefix(location, "https://") {
+		return
+	}
+	if !strings.Contains(location, "://") && !filepath.IsAbs(location) {
+		if strings.Contains(config, "://") {
+			// Remote configs extend local ones relative to the directory the config was loaded in
+			return
+		}
+		location = filepath.Join(filepath.Dir(config), location)
+	}
+	addConfigStamps(stamps, location, depth+1)
+}
+
+// addRemoteStamp adds the stamp of the remote config at url to stamps, fetching it when it
+// wasn't within remoteConfigInterval, and returns its contents
+func addRemoteStamp(stamps map[string]fileStamp, url string) []byte {
+	remoteStamps.Lock()
+	remote, ok := remoteStamps.configs[url]
+	remoteStamps.Unlock()
+	if !ok || time.Since(remote.fetched) >= remoteConfigInterval {
+		// Unreachable servers don't count as changes, the config stays as it was loaded
+		if content, err := fetchConfig(url); err == nil {
+			remote = remoteConfig{stamp: fileStamp{size: int64(len(content)), sum: sha256.Sum256(content)}, content: content, fetched: time.Now()}
+			remoteStamps.Lock()
+			remoteStamps.configs[url] = remote
+			remoteStamps.Unlock()
+		}
+	}
+	stamps[url] = remote.stamp
+	return remote.content
+}
+
+// stampsChanged reports whether any config of old changed, appeared or disappeared in new.
+// Configs only in new belong to directories visited since, they were read as they are now.
+func stampsChanged(old, new map[string]fileStamp) bool {
+	for path, stamp := range old {
+		if current := new[path]; !current.modTime.Equal(stamp.modTime) || current.size != stamp.size || current.sum != stamp.sum {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/hsivakum/gogroupimports"
+)
+
+// maxRequestSize bounds the request bodies accepted by the servers, serve can be told otherwise
+const maxRequestSize = 10 << 20
+
+// server checks and fixes sources sent over HTTP with the checkers of the config. The config
+// is loaded in the background after the server starts listening, /readyz tells when it's done.
+type server struct {
+	configPath     string
+	requests       atomic.Uint64 // Numbers the requests for the log lines
+	metrics        *metrics
+	maxRequestSize int64
+	clientLimiter  *rateLimiter  // Limits the requests of every client address, nil for no limit
+	slots          chan struct{} // Holds a value for every request being answered, nil for no limit
+
+	mu       sync.Mutex // Guards the fields below, the checker tree locks itself
+	state    string     // "loading", "ready" or "failed"
+	err      error      // Why loading or the last reload failed
+	checkers *checkerTree
+}
+
+// serveResponse is the JSON answer to /check and /fix requests
+type serveResponse struct {
+	Source      *string                     `json:"source,omitempty"` // The fixed source, only for /fix
+	Diagnostics []gogroupimports.Diagnostic `json:"diagnostics"`
+	Error       string                      `json:"error,omitempty"`
+}
+
+// serve runs the checker as an HTTP service for bots, editors and other tools:
+//
+//	POST /check?path=file.go  checks the Go source in the body
+//	POST /fix?path=file.go    fixes it, returning the fixed source too
+//	GET  /healthz             answers as long as the process runs
+//	GET  /readyz              answers once the config is loaded and the stdlib index warm
+//	POST /playground/format   fixes the source of a JSON body with inline settings, see playground
+//	GET  /metrics             reports request, violation, cache and latency metrics to Prometheus
+//	grpc.health.v1.Health/Check answers SERVING once /readyz would, over HTTP/2
+//
+// Changes to the configs are picked up without a restart, see watchConfig. With -tokens, /check
+// and /fix require a bearer token, since the sources sent to a shared service shouldn't be open
+// to the whole network, and -client-ca adds mutual TLS to the HTTPS of -tls-cert. Request bodies
+// are bounded by -max-request-size, each client address by -client-rate requests per minute
+// and the requests answered at once by -max-concurrent. -otlp-endpoint exports the spans of
+// every check and fix to an OpenTelemetry collector, as children of the span of a traceparent
+// header.
+//
+// path names the file the source belongs to, relative to the directory the server runs in and
+// within it. It selects the nested config, module and build variant. The file itself isn't
+// read, its build constraints are taken from the source, but the module lookups of
+// useGoList run in its directory.
+func serve(args []string) int {
+	flags := flag.NewFlagSet("gogroupimports serve", flag.ExitOnError)
+	configPath := configFlag(flags)
+	addr := flags.String("addr", "localhost:7777", "address to listen on")
+	verbose := flags.Bool("v", false, "log every request with its duration")
+	logFormat := flags.String("log-format", logFormatText, "format of the log lines on stderr: text or json")
+	reloadInterval := flags.Duration("reload-interval", 2*time.Second, "how often to look for config changes to reload, 0 turns reloading off")
+	playgroundRate := flags.Int("playground-rate", 30, "requests per minute each client may send to /playground/format, 0 turns it off")
+	tokensPath := flags.String("tokens", "", "file of client names and bearer tokens required by /check and /fix, see tokenAuth")
+	tokenRate := flags.Int("token-rate", 0, "requests per minute each token may send unless the tokens file says otherwise, 0 for no limit")
+	tlsCert := flags.String("tls-cert", "", "certificate to serve HTTPS with, PEM encoded")
+	tlsKey := flags.String("tls-key", "", "private key of -tls-cert, PEM encoded")
+	clientCA := flags.String("client-ca", "", "CA certificates clients must present a certificate of, for mutual TLS; requires -tls-cert")
+	maxSize := flags.Int64("max-request-size", maxRequestSize, "largest request body accepted, in bytes")
+	clientRate := flags.Int("client-rate", 0, "requests per minute each client address may send to /check and /fix, 0 for no limit")
+	maxConcurrent := flags.Int("max-concurrent", 0, "requests to /check and /fix answered at once, others are refused with 503; 0 for no limit")
+	otlpEndpoint := otlpFlag(flags)
+	_ = flags.Parse(args)
+
+	if *verbose {
+		verbosity = 1
+	}
+	if err := setLogFormat(*logFormat); err != nil {
+		log.Print(err)
+		return 2
+	}
+
+	if (*tlsCert == "") != (*tlsKey == "") || *clientCA != "" && *tlsCert == "" {
+		log.Print("-tls-cert and -tls-key go together, -client-ca requires them")
+		return 2
+	}
+	serverTLS, err := tlsConfig(*clientCA)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	authorized := func(handler http.HandlerFunc) http.HandlerFunc { return handler }
+	if *tokensPath != "" {
+		auth, err := loadTokens(*tokensPath, *tokenRate)
+		if err != nil {
+			log.Print(err)
+			return 2
+		}
+		authorized = auth.wrap
+	}
+
+	defer startTracing(*otlpEndpoint)()
+
+	s := &server{configPath: *configPath, state: "loading", metrics: newMetrics(), maxRequestSize: *maxSize}
+	if *clientRate > 0 {
+		s.clientLimiter = newRateLimiter(*clientRate)
+	}
+	if *maxConcurrent > 0 {
+		s.slots = make(chan struct{}, *maxConcurrent)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.healthz)
+	mux.HandleFunc("/readyz", s.readyz)
+	mux.HandleFunc("/metrics", s.metrics.serveHTTP)
+	mux.HandleFunc("/check", authorized(s.limited(s.handle(false))))
+	mux.HandleFunc("/fix", authorized(s.limited(s.handle(true))))
+	if *playgroundRate > 0 {
+		mux.HandleFunc("/playground/format", newPlayground(*playgroundRate, *maxSize, s.metrics).format)
+	}
+	mux.HandleFunc("/grpc.health.v1.Health/Check", s.grpcHealthCheck)
+	mux.HandleFunc("/grpc.health.v1.Health/Watch", s.grpcHealthWatch)
+	httpServer := &http.Server{Addr: *addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second, TLSConfig: serverTLS}
+	allowCleartextHTTP2(httpServer)
+
+	go s.watchConfig(*reloadInterval)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdown)
+	}()
+
+	verbosef(0, "listening on %s", *addr)
+	if *tlsCert != "" {
+		err = httpServer.ListenAndServeTLS(*tlsCert, *tlsKey)
+	} else {
+		err = httpServer.ListenAndServe()
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		log.Print(err)
+		return 2
+	}
+	return 0
+}
+
+// load creates the checkers, which reads the config and warms up the stdlib index, and returns
+// the stamps of the configs they were created from. When a reload fails the server keeps the
+// checkers it has. The lookups shared by the checkers, like vanity roots, are made again.
+func (s *server) load() map[string]fileStamp {
+	gogroupimports.ClearCaches()
+	checkers, err := newCheckers(s.configPath)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case err != nil && s.checkers != nil:
+		s.err = err
+		log.Printf("reloading the config, keeping the previous one: %v", err)
+	case err != nil:
+		s.state, s.err = "failed", err
+		log.Printf("loading the config: %v", err)
+	default:
+		if s.checkers != nil {
+			verbosef(0, "reloaded the config")
+		}
+		s.state, s.err, s.checkers = "ready", nil, checkers
+	}
+	return configStamps(s.checkers, s.configPath)
+}
+
+// watchConfig loads the checkers and creates them anew whenever one of their configs changes,
+// so that policy changes apply without a restart. The configs are polled every interval.
+func (s *server) watchConfig(interval time.Duration) {
+	stamps := s.load()
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.mu.Lock()
+		current := configStamps(s.checkers, s.configPath)
+		s.mu.Unlock()
+		if stampsChanged(stamps, current) {
+			stamps = s.load()
+		} else {
+			stamps = current
+		}
+	}
+}
+
+// healthz reports that the process is alive
+func (s *server) healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyz reports whether the config is loaded, and why not when loading failed
+func (s *server) readyz(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	state, err := s.state, s.err
+	s.mu.Unlock()
+	status := map[string]string{"status": state}
+	if err != nil {
+		status["error"] = err.Error()
+	}
+	if state != "ready" {
+		writeJSON(w, http.StatusServiceUnavailable, status)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// checkerFor returns the checker for path, nil with the status to answer when there is none
+func (s *server) checkerFor(path string) (*gogroupimports.Checker, int, error) {
+	s.mu.Lock()
+	state, checkers := s.state, s.checkers
+	s.mu.Unlock()
+	if state != "ready" {
+		return nil, http.StatusServiceUnavailable, fmt.Errorf("the config is %s", state)
+	}
+	checker, err := checkers.forFile(path)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return checker, http.StatusOK, nil
+}
+
+// limited refuses requests of clients over -client-rate with 429 and requests beyond
+// -max-concurrent with 503 before passing them to next, so one misbehaving CI job can't
+// degrade the service for everyone
+func (s *server) limited(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		refuse := func(status int, retryAfter, message string) {
+			w.Header().Set("Retry-After", retryAfter)
+			writeJSON(w, status, serveResponse{Diagnostics: []gogroupimports.Diagnostic{}, Error: message})
+			s.metrics.observe(r.URL.Path, status, 0, nil)
+			verbosef(1, "%s from %s: %s", r.URL.Path, clientAddress(r), message)
+		}
+		if s.clientLimiter != nil && !s.clientLimiter.allow(clientAddress(r)) {
+			refuse(http.StatusTooManyRequests, "60", "too many requests, try again later")
+			return
+		}
+		if s.slots != nil {
+			select {
+			case s.slots <- struct{}{}:
+				defer func() { <-s.slots }()
+			default:
+				refuse(http.StatusServiceUnavailable, "1", "too many requests at once, try again shortly")
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// handle answers /check requests, or /fix requests when fix is set
+func (s *server) handle(fix bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := s.requests.Add(1)
+		path := r.URL.Query().Get("path")
+		status, response := s.answer(w, r, path, fix)
+		writeJSON(w, status, response)
+		s.metrics.observe(r.URL.Path, status, time.Since(start), response.Diagnostics)
+
+		attrs := []interface{}{"request", id, "file", path, "status", status, "duration", time.Since(start)}
+		if response.Error != "" {
+			attrs = append(attrs, "error", response.Error)
+		}
+		verboseAttrs(1, attrs, "%s %s %d in %s", r.URL.Path, path, status, time.Since(start))
+	}
+}
+
+// answer checks or fixes the source of the request r
+func (s *server) answer(w http.ResponseWriter, r *http.Request, path string, fix bool) (int, serveResponse) {
+	response := serveResponse{Diagnostics: []gogroupimports.Diagnostic{}}
+	fail := func(status int, err error) (int, serveResponse) {
+		response.Error = err.Error()
+		return status, response
+	}
+	if r.Method != http.MethodPost {
+		return fail(http.StatusMethodNotAllowed, errors.New("use POST"))
+	}
+	if path == "" {
+		return fail(http.StatusBadRequest, errors.New("missing path parameter"))
+	}
+	if !filepath.IsLocal(path) {
+		return fail(http.StatusBadRequest, fmt.Errorf("path %s must be relative and stay within the directory of the server", path))
+	}
+	src, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.maxRequestSize))
+	if err != nil {
+		return fail(http.StatusRequestEntityTooLarge, err)
+	}
+	checker, status, err := s.checkerFor(path)
+	if err != nil {
+		return fail(status, err)
+	}
+
+	ctx := withTraceParent(r.Context(), r.Header.Get("Traceparent"))
+	var diagnostics []gogroupimports.Diagnostic
+	if fix {
+		var fixed []byte
+		fixed, diagnostics, err = checker.FixSource(ctx, path, src)
+		if err == nil {
+			source := string(fixed)
+			response.Source = &source
+		}
+	} else {
+		diagnostics, err = checker.CheckSource(ctx, path, src)
+	}
+	if err != nil {
+		return fail(http.StatusUnprocessableEntity, err)
+	}
+	if diagnostics != nil {
+		response.Diagnostics = diagnostics
+	}
+	return http.StatusOK, response
+}
+
+// writeJSON answers with value encoded as JSON
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hsivakum/gogroupimports"
+)
+
+// ruleUnchecked is the rule of the records -format json writes for files that couldn't be
+// checked, so that merge-results doesn't lose them
+const ruleUnchecked = "unchecked"
+
+// shard selects the files of one of count CI jobs splitting a run, by the hash of their path
+type shard struct {
+	index, count int // index is 1-based
+}
+
+// parseShard parses -shard N/M
+func parseShard(value string) (shard, error) {
+	n, m, ok := strings.Cut(value, "/")
+	index, err1 := strconv.Atoi(n)
+	count, err2 := strconv.Atoi(m)
+	if !ok || err1 != nil || err2 != nil || count < 1 || index < 1 || index > count {
+		return shard{}, fmt.Errorf("invalid -shard %q, want N/M with 1 <= N <= M", value)
+	}
+	return shard{index: index, count: count}, nil
+}
+
+// contains reports whether file belongs to the shard. Files are assigned by the hash of their
+// cleaned, slash separated path, so every job agrees on the split without coordination and a
+// file stays in its shard when others are added or removed.
+func (s shard) contains(file string) bool {
+	hash := fnv.New32a()
+	hash.Write([]byte(filepath.ToSlash(filepath.Clean(file))))
+	return int(hash.Sum32()%uint32(s.count)) == s.index-1
+}
+
+// filter returns the files of files in the shard
+func (s shard) filter(files []string) []string {
+	var selected []string
+	for _, file := range files {
+		if s.contains(file) {
+			selected = append(selected, file)
+		}
+	}
+	return selected
+}
+
+// mergeResults combines the -format json outputs of the shards of a run into one report,
+// sorted and without duplicates, and sets the exit code like a single run would: 2 when a
+// shard couldn't check a file, 1 when a diagnostic has a severity of -fail-on, which defaults
+// to the failOn setting of the config
+func mergeResults(args []string) int {
+	flags := flag.NewFlagSet("gogroupimports merge-results", flag.ExitOnError)
+	configPath := configFlag(flags)
+	format := flags.String("format", formatText, "output format: text, json or template")
+	templateText := flags.String("template", "", "text/template executed for every diagnostic with -format=template")
+	failOn := flags.String("fail-on", "", "comma separated severities that set exit code 1, overriding the failOn setting")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: gogroupimports merge-results [-config file] [-format text|json|template] [-template text] [-fail-on severities] file ...")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	write, err := newFormatter(*format, *templateText)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	var severities []string
+	if *failOn != "" {
+		if severities, err = gogroupimports.ParseFailOn(*failOn); err != nil {
+			log.Printf("invalid -fail-on: %v", err)
+			return 2
+		}
+	} else {
+		metaData, err := loadConfig(*configPath)
+		if err != nil {
+			log.Print(err)
+			return 2
+		}
+		settings, err := gogroupimports.ParseSettings(metaData)
+		if err != nil {
+			log.Printf("invalid config: %v", err)
+			return 2
+		}
+		severities = settings.FailOn
+		if len(severities) == 0 {
+			severities = []string{gogroupimports.SeverityError}
+		}
+	}
+
+	var diagnostics []gogroupimports.Diagnostic
+	seen := make(map[gogroupimports.Diagnostic]bool)
+	for _, name := range flags.Args() {
+		shardDiagnostics, err := readResults(name)
+		if err != nil {
+			log.Print(err)
+			return 2
+		}
+		for _, diagnostic := range shardDiagnostics {
+			if !seen[diagnostic] {
+				seen[diagnostic] = true
+				diagnostics = append(diagnostics, diagnostic)
+			}
+		}
+	}
+	gogroupimports.SortDiagnostics(diagnostics)
+
+	unchecked := 0
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Rule == ruleUnchecked {
+			unchecked++
+		}
+		if err := write(os.Stdout, diagnostic); err != nil {
+			log.Print(err)
+			return 2
+		}
+	}
+	if unchecked > 0 {
+		log.Printf("%d files weren't checked", unchecked)
+		return 2
+	}
+	if gogroupimports.FailsOn(diagnostics, severities) {
+		return 1
+	}
+	return 0
+}
+
+// readResults reads the diagnostics of a -format json output, "-" for stdin
+func readResults(name string) ([]gogroupimports.Diagnostic, error) {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		r = file
+	}
+	var diagnostics []gogroupimports.Diagnostic
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var diagnostic gogroupimports.Diagnostic
+		err := decoder.Decode(&diagnostic)
+		if err == io.EOF {
+			return diagnostics, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: not the -format json output of a check: %w", name, err)
+		}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// signaturePolicy decides which extended configs are trusted. An extended config is verified
+// against the minisign signature stored next to it, with .minisig appended to its path or URL.
+// The trusted keys only come from the config passed on the command line, so an extended config
+// can't vouch for itself.
+type signaturePolicy struct {
+	keys    []minisignKey
+	require bool // Refuse extended configs without a valid signature
+}
+
+// minisignKey is an Ed25519 public key in the format used by minisign
+type minisignKey struct {
+	id  [8]byte
+	key ed25519.PublicKey
+}
+
+// newSignaturePolicy creates the policy for the config keys, a list of minisign public keys
+func newSignaturePolicy(keys interface{}, require bool) (*signaturePolicy, error) {
+	policy := &signaturePolicy{require: require}
+	list, ok := keys.([]interface{})
+	if !ok && keys != nil {
+		return nil, errors.New("configPublicKeys must be a list of minisign public keys")
+	}
+	for _, key := range list {
+		text, _ := key.(string)
+		parsed, err := parseMinisignKey(text)
+		if err != nil {
+			return nil, err
+		}
+		policy.keys = append(policy.keys, parsed)
+	}
+	if require && len(policy.keys) == 0 {
+		return nil, errors.New("-require-signed-config needs the trusted keys in configPublicKeys")
+	}
+	return policy, nil
+}
+
+// check verifies an extended config read from location. fetch returns its signature file.
+func (policy *signaturePolicy) check(location string, content []byte, fetch func(string) ([]byte, error)) error {
+	if len(policy.keys) == 0 && !policy.require {
+		return nil
+	}
+	signature, err := fetch(location + ".minisig")
+	if err != nil {
+		if policy.require {
+			return fmt.Errorf("refusing unsigned config: %w", err)
+		}
+		return nil
+	}
+	if err := policy.verify(content, signature); err != nil {
+		return fmt.Errorf("signature %s.minisig: %w", location, err)
+	}
+	return nil
+}
+
+// parseMinisignKey decodes a base64 minisign public key, like the second line of a minisign.pub file
+func parseMinisignKey(text string) (minisignKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+	if err != nil || len(raw) != 2+8+ed25519.PublicKeySize || string(raw[:2]) != "Ed" {
+		return minisignKey{}, fmt.Errorf("invalid minisign public key %q", text)
+	}
+	var key minisignKey
+	copy(key.id[:], raw[2:10])
+	key.key = ed25519.PublicKey(raw[10:])
+	return key, nil
+}
+
+// verify checks a config against its minisign signature file
+func (policy *signaturePolicy) verify(content, signature []byte) error {
+	lines := strings.Split(strings.TrimSpace(string(signature)), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return errors.New("malformed minisign signature")
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return errors.New("malformed minisign signature")
+	}
+	globalSig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(globalSig) != ed25519.SignatureSize {
+		return errors.New("malformed minisign signature")
+	}
+
+	message := content
+	switch string(sig[:2]) {
+	case "Ed":
+	case "ED":
+		hash := blake2b.Sum512(content)
+		message = hash[:]
+	default:
+		return fmt.Errorf("unsupported signature algorithm %q", sig[:2])
+	}
+
+	for _, key := range policy.keys {
+		if !bytes.Equal(key.id[:], sig[2:10]) {
+			continue
+		}
+		if !ed25519.Verify(key.key, message, sig[10:]) {
+			return errors.New("invalid signature")
+		}
+		trustedComment := strings.TrimPrefix(strings.TrimRight(lines[2], "\r"), "trusted comment: ")
+		signed := append(append([]byte(nil), sig[10:]...), trustedComment...)
+		if !ed25519.Verify(key.key, signed, globalSig) {
+			return errors.New("invalid signature of the trusted comment")
+		}
+		return nil
+	}
+	return fmt.Errorf("signed by unknown key %X", sig[2:10])
+}
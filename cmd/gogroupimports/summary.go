@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hsivakum/gogroupimports"
+)
+
+// summary prints how many imports fall into each group, per file or per package
+func summary(args []string) int {
+	flags := flag.NewFlagSet("gogroupimports summary", flag.ExitOnError)
+	configPath := configFlag(flags)
+	by := flags.String("by", "file", "aggregate the counts by file or package")
+	_ = flags.Parse(args)
+
+	if *by != "file" && *by != "package" {
+		log.Printf("unknown -by %q, want file or package", *by)
+		return 2
+	}
+
+	checkers, err := newCheckers(*configPath)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+
+	files, err := goFiles(flags.Args())
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+
+	exitCode := 0
+	totals := make(map[string]map[string]int)
+	for _, file := range files {
+		checker, err := checkers.forFile(file)
+		if err != nil {
+			log.Printf("%s: %v", file, err)
+			exitCode = 2
+			continue
+		}
+		counts, err := checker.CountImports(file)
+		if err != nil {
+			log.Printf("%s: %v", file, err)
+			exitCode = 2
+			continue
+		}
+
+		// Files without imports are listed too, with all counts zero
+		key := file
+		if *by == "package" {
+			key = filepath.Dir(file)
+		}
+		if totals[key] == nil {
+			totals[key] = make(map[string]int)
+		}
+		for importType, count := range counts {
+			totals[key][importType] += count
+		}
+	}
+
+	keys := make([]string, 0, len(totals))
+	for key := range totals {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	importTypes := gogroupimports.ImportTypes()
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(table, "%s\t%s\ttotal\t\n", strings.ToUpper(*by), strings.Join(importTypes, "\t"))
+	for _, key := range keys {
+		total := 0
+		row := []string{key}
+		for _, importType := range importTypes {
+			total += totals[key][importType]
+			row = append(row, fmt.Sprint(totals[key][importType]))
+		}
+		fmt.Fprintf(table, "%s\t%d\t\n", strings.Join(row, "\t"), total)
+	}
+	if err := table.Flush(); err != nil {
+		log.Print(err)
+		return 2
+	}
+	return exitCode
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+)
+
+// unclassifiedDomain is a domain whose imports were classified as third party although they
+// look internal
+type unclassifiedDomain struct {
+	domain   string
+	reason   string
+	packages map[string]int
+}
+
+// reportUnclassified lists the domains of third party imports that look internal, because they
+// match GOPRIVATE or share the host of the own module, and suggests the internalPrivateDomains
+// that would classify them as internal private
+func reportUnclassified(checkers *checkerTree, files []string) int {
+	private := splitList(goEnv("GOPRIVATE"))
+	selfModule, _ := checkers.base["selfModule"].(string)
+
+	exitCode := 0
+	domains := make(map[string]*unclassifiedDomain)
+	for _, file := range files {
+		checker, err := checkers.forFile(file)
+		if err != nil {
+			log.Printf("%s: %v", file, err)
+			exitCode = 2
+			continue
+		}
+		imports, err := checker.ListImports(file)
+		if err != nil {
+			log.Printf("%s: %v", file, err)
+			exitCode = 2
+			continue
+		}
+		for _, info := range imports {
+			if info.Type != "public_open_source_or_third_party" {
+				continue
+			}
+			domain, reason := internalLooking(info.Path, selfModule, private)
+			if domain == "" {
+				continue
+			}
+			if domains[domain] == nil {
+				domains[domain] = &unclassifiedDomain{domain: domain, reason: reason, packages: make(map[string]int)}
+			}
+			domains[domain].packages[info.Path]++
+		}
+	}
+
+	if len(domains) == 0 {
+		fmt.Println("no third party imports look internal")
+		return exitCode
+	}
+
+	names := make([]string, 0, len(domains))
+	for name := range domains {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Println("third party imports that look internal:")
+	for _, name := range names {
+		domain := domains[name]
+		fmt.Printf("  %s (%s)\n", domain.domain, domain.reason)
+		packages := make([]string, 0, len(domain.packages))
+		for path := range domain.packages {
+			packages = append(packages, path)
+		}
+		sort.Strings(packages)
+		for _, path := range packages {
+			fmt.Printf("    %s %d\n", path, domain.packages[path])
+		}
+	}
+	fmt.Println("\nsuggested config addition:")
+	fmt.Println("  internalPrivateDomains:")
+	coversSelf := false
+	for _, name := range names {
+		fmt.Printf("    - %q\n", name)
+		coversSelf = coversSelf || selfModule != "" && strings.Contains(selfModule, name)
+	}
+	if coversSelf {
+		// Internal private domains take precedence over the own module unless modules are known
+		fmt.Printf("  # keeps the packages of %s in the own module group\n", selfModule)
+		fmt.Println("  useGoList: true")
+	}
+	return exitCode
+}
+
+// internalLooking returns the domain to add to internalPrivateDomains for importPath and why,
+// or "" when importPath doesn't look internal. On public hosts the domain is narrowed to the
+// organization, like guessInternalDomains does.
+func internalLooking(importPath, selfModule string, private []string) (domain, reason string) {
+	host, rest, _ := strings.Cut(importPath, "/")
+	domain = host
+	if publicHosts[host] {
+		org, _, _ := strings.Cut(rest, "/")
+		if org == "" {
+			return "", ""
+		}
+		domain = host + "/" + org + "/"
+	}
+
+	if pattern := matchingPrivatePattern(importPath, private); pattern != "" {
+		return domain, fmt.Sprintf("matches GOPRIVATE pattern %s", pattern)
+	}
+	// Whole path elements are compared, corp.com isn't the host of corp.com.evil/app
+	if selfModule != "" && strings.HasPrefix(selfModule+"/", strings.TrimSuffix(domain, "/")+"/") && strings.Contains(host, ".") {
+		return domain, fmt.Sprintf("shares the host of %s", selfModule)
+	}
+	return "", ""
+}
+
+// matchingPrivatePattern returns the GOPRIVATE pattern matching a prefix of importPath, like
+// the go command matches them, or "" when none does
+func matchingPrivatePattern(importPath string, patterns []string) string {
+	elements := strings.Split(importPath, "/")
+	for _, pattern := range patterns {
+		n := strings.Count(pattern, "/") + 1
+		if n > len(elements) {
+			continue
+		}
+		if ok, _ := path.Match(pattern, strings.Join(elements[:n], "/")); ok {
+			return pattern
+		}
+	}
+	return ""
+}
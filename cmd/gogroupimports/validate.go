@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hsivakum/gogroupimports"
+)
+
+// commandSettings are config keys handled by the command rather than passed on as Settings
+var commandSettings = map[string]bool{
+	"extends":          true,
+	"configPublicKeys": true,
+	"root":             true,
+	"gazelle":          true,
+	"bazelPrefixes":    true,
+}
+
+// warnedConfigs holds the config warnings logged already, so that configs loaded again, like
+// nested ones or on reloads, don't repeat them
+var warnedConfigs sync.Map
+
+// validateConfig checks the settings of one decoded config file: unknown keys, values of the
+// wrong type and the problems found by Settings.Validate. Every problem is reported on a line
+// of its own, prefixed with the location of the offending key in the file. Problems
+// Settings.Validate marks as warnings, like repeated values, are logged instead.
+func validateConfig(name string, content []byte, metaData map[string]interface{}) error {
+	lines := keyLines(name, content)
+	var problems []configProblem
+	locate := func(key string, format string, args ...interface{}) string {
+		location := name
+		if line, ok := lines[key]; ok {
+			location = fmt.Sprintf("%s:%d", name, line)
+		}
+		return location + ": " + fmt.Sprintf(format, args...)
+	}
+	report := func(key string, format string, args ...interface{}) {
+		problems = append(problems, configProblem{line: lines[key], message: locate(key, format, args...)})
+	}
+	warn := func(key string, format string, args ...interface{}) {
+		message := locate(key, format, args...)
+		if _, warned := warnedConfigs.LoadOrStore(message, true); !warned {
+			verbosef(0, "warning: %s", message)
+		}
+	}
+
+	keys := make([]string, 0, len(metaData))
+	for key := range metaData {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if lines[keys[i]] != lines[keys[j]] {
+			return lines[keys[i]] < lines[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	fields := settingFields()
+	valid := make(map[string]interface{})
+	for _, key := range keys {
+		if commandSettings[key] {
+			continue
+		}
+		if _, ok := fields[key]; !ok {
+			if suggestion := closestSetting(key, fields); suggestion != "" {
+				report(key, "unknown setting %q, did you mean %q?", key, suggestion)
+			} else {
+				report(key, "unknown setting %q", key)
+			}
+			continue
+		}
+		if _, err := gogroupimports.ParseSettings(map[string]interface{}{key: metaData[key]}); err != nil {
+			report(key, "%s: %s", key, describeTypeError(err))
+			continue
+		}
+		valid[key] = metaData[key]
+	}
+
+	settings, err := gogroupimports.ParseSettings(valid)
+	if err == nil {
+		err = settings.Validate()
+	}
+	var errs gogroupimports.Errors
+	if errors.As(err, &errs) {
+		for _, err := range errs {
+			var settingErr *gogroupimports.SettingError
+			switch {
+			case !errors.As(err, &settingErr):
+			case settingErr.Warning:
+				warn(settingErr.Setting, "%v", err)
+			default:
+				report(settingErr.Setting, "%v", err)
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].line < problems[j].line
+	})
+	messages := make([]string, len(problems))
+	for i, problem := range problems {
+		messages[i] = problem.message
+	}
+	return errors.New(strings.Join(messages, "\n"))
+}
+
+// configProblem is a problem found in a config file, at the line of the offending key
+type configProblem struct {
+	line    int
+	message string
+}
+
+// keyLines returns the line of every top level key of a config, nil when it can't be parsed
+func keyLines(name string, content []byte) map[string]int {
+	lines := make(map[string]int)
+	if strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml") {
+		var document yaml.Node
+		if yaml.Unmarshal(content, &document) != nil || len(document.Content) == 0 {
+			return nil
+		}
+		mapping := document.Content[0]
+		for i := 0; i+1 < len(mapping.Content); i += 2 {
+			lines[mapping.Content[i].Value] = mapping.Content[i].Line
+		}
+		return lines
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return lines
+		}
+		key, _ := token.(string)
+		lines[key] = 1 + bytes.Count(content[:decoder.InputOffset()], []byte("\n"))
+		var value json.RawMessage
+		if decoder.Decode(&value) != nil {
+			return lines
+		}
+	}
+	return lines
+}
+
+// describeTypeError explains a failure to decode a setting without the Go type names
+func describeTypeError(err error) string {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return fmt.Sprintf("expected a %s, got a %s", describeType(typeErr.Type.Kind().String()), typeErr.Value)
+	}
+	return err.Error()
+}
+
+// describeType names the JSON counterpart of a Go kind
+func describeType(kind string) string {
+	switch kind {
+	case "slice":
+		return "list"
+	case "map", "struct":
+		return "mapping"
+	case "int", "int64":
+		return "number"
+	}
+	return kind
+}
+
+// closestSetting suggests the setting a misspelled key was meant to be, "" when none is close
+func closestSetting(key string, fields map[string]reflect.Type) string {
+	best, bestDistance := "", len(key)/3+1
+	for name := range fields {
+		if strings.EqualFold(name, key) {
+			return name
+		}
+		if distance := editDistance(strings.ToLower(key), strings.ToLower(name)); distance < bestDistance || distance == bestDistance && best != "" && name < best {
+			best, bestDistance = name, distance
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hsivakum/gogroupimports"
+)
+
+// watchPollInterval is how often watch mode looks for changed files and configs
+const watchPollInterval = 250 * time.Millisecond
+
+// watchListInterval is how often watch mode lists the files again even though no directory
+// holding them changed, to find files in new directories
+const watchListInterval = 5 * time.Second
+
+// watch checks the files listed by listFiles, then keeps checking the files that change until
+// interrupted. Changes are collected until none happened for debounce, so that a burst of
+// saves or a code generator rewriting many files triggers a single check of all of them.
+// Files matching one of the ignore globs never trigger a check. When a config changes the
+// checkers are recreated with loadCheckers and every file is checked again. Every poll only
+// stats the files known, they are listed again when a directory holding them changes or
+// every watchListInterval.
+func (run *checkRun) watch(listFiles func() ([]string, error), loadCheckers func() (*checkerTree, error), configPath string, debounce time.Duration, ignore []string) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	files, err := listFiles()
+	if err != nil {
+		verbosef(0, "%v", err)
+		return 2
+	}
+	exitCode := run.checkFiles(files)
+	known := watchedFiles(files, ignore)
+	stamps := goFileStamps(known)
+	dirs := goFileStamps(parentDirs(files))
+	lastList := time.Now()
+	configs := configStamps(run.checkers, configPath)
+
+	pending := make(map[string]bool)
+	var lastChange time.Time
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return exitCode
+		case <-ticker.C:
+		}
+
+		if current := configStamps(run.checkers, configPath); stampsChanged(configs, current) {
+			gogroupimports.ClearCaches()
+			checkers, err := loadCheckers()
+			if err != nil {
+				verbosef(0, "reloading the config, keeping the previous one: %v", err)
+			} else {
+				verbosef(1, "reloaded the config")
+				run.checkers = checkers
+				for file := range stamps {
+					pending[file] = true
+				}
+				lastChange = time.Now()
+			}
+			configs = configStamps(run.checkers, configPath)
+		} else {
+			configs = current
+		}
+
+		if time.Since(lastList) >= watchListInterval || stampsChanged(dirs, goFileStamps(parentDirs(known))) {
+			files, err := listFiles()
+			if err != nil {
+				verbosef(0, "%v", err)
+				continue
+			}
+			known = watchedFiles(files, ignore)
+			dirs = goFileStamps(parentDirs(files))
+			lastList = time.Now()
+		}
+		current := goFileStamps(known)
+		for file, stamp := range current {
+			if previous, ok := stamps[file]; !ok || previous != stamp {
+				pending[file] = true
+				lastChange = time.Now()
+			}
+		}
+		for file := range pending {
+			if _, ok := current[file]; !ok {
+				delete(pending, file)
+			}
+		}
+		stamps = current
+
+		if len(pending) == 0 || time.Since(lastChange) < debounce {
+			continue
+		}
+		batch := make([]string, 0, len(pending))
+		for file := range pending {
+			batch = append(batch, file)
+		}
+		sort.Strings(batch)
+		clear(pending)
+		verbosef(1, "checking %d changed files", len(batch))
+		exitCode = run.checkFiles(batch)
+		// Fixes rewrite files, which mustn't count as changes
+		for file, stamp := range goFileStamps(batch) {
+			stamps[file] = stamp
+		}
+	}
+}
+
+// parentDirs returns the directories holding files and their parents, whose modification times
+// change when files are added or removed
+func parentDirs(files []string) []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, file := range files {
+		for dir := filepath.Dir(file); !seen[dir]; dir = filepath.Dir(dir) {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// goFileStamps returns the stamps of files, skipping those that vanished
+func goFileStamps(files []string) map[string]fileStamp {
+	stamps := make(map[string]fileStamp, len(files))
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			stamps[file] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return stamps
+}
+
+// watchedFiles drops the files matching one of the ignore globs. Globs without a slash match
+// the file name, like *_gen.go, others the slash separated path, where ** matches any number
+// of directories, like **/mocks/**.
+func watchedFiles(files []string, ignore []string) []string {
+	if len(ignore) == 0 {
+		return files
+	}
+	var watched []string
+	for _, file := range files {
+		if !matchesAnyGlob(filepath.ToSlash(file), ignore) {
+			watched = append(watched, file)
+		}
+	}
+	return watched
+}
+
+// matchesAnyGlob reports whether the slash separated name matches one of globs
+func matchesAnyGlob(name string, globs []string) bool {
+	for _, glob := range globs {
+		if !strings.Contains(glob, "/") {
+			if ok, _ := path.Match(glob, path.Base(name)); ok {
+				return true
+			}
+			continue
+		}
+		if gogroupimports.MatchPattern(glob, name) {
+			return true
+		}
+	}
+	return false
+}
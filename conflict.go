@@ -0,0 +1,99 @@
+package gogroupimports
+
+import (
+	"fmt"
+	"go/ast"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Schemes of the AliasScheme setting, naming packages added by fixes whose name is taken
+const (
+	// AliasSchemeParent prefixes the name with the path element before it, like pkgerrors for
+	// github.com/pkg/errors, numbering it when that is taken as well
+	AliasSchemeParent = "parent"
+	// AliasSchemeNumber numbers the name, like errors2
+	AliasSchemeNumber = "number"
+)
+
+// validateAliasScheme checks that scheme is empty or a known alias scheme
+func validateAliasScheme(scheme string) error {
+	switch scheme {
+	case "", AliasSchemeParent, AliasSchemeNumber:
+		return nil
+	}
+	return fmt.Errorf("unknown alias scheme %q, want %s or %s", scheme, AliasSchemeParent, AliasSchemeNumber)
+}
+
+// identifierOf turns a path element into an identifier of its lowercase letters and digits,
+// "" when it has none or starts with a digit
+func identifierOf(element string) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, element)
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		return ""
+	}
+	return name
+}
+
+// conflictAlias returns an alias for the package at importPath, called name, that isn't one of
+// the taken names, following scheme. The same file and settings always give the same alias.
+func conflictAlias(importPath, name string, taken map[string]bool, scheme string) string {
+	base := name
+	if scheme != AliasSchemeNumber {
+		dir := path.Dir(importPath)
+		if isMajorVersion(path.Base(importPath)) {
+			dir = path.Dir(dir)
+		}
+		if parent := identifierOf(path.Base(dir)); parent != "" {
+			base = parent + name
+			if !taken[base] {
+				return base
+			}
+		}
+	}
+	for n := 2; ; n++ {
+		if alias := base + strconv.Itoa(n); !taken[alias] {
+			return alias
+		}
+	}
+}
+
+// takenNames returns the names of the imports and identifiers of node, leaving out the
+// selected names, which don't conflict with packages. The name of the import except, which goes
+// away, and its references aren't counted.
+func takenNames(node *ast.File, except *ast.ImportSpec) map[string]bool {
+	taken := make(map[string]bool)
+	exceptName := ""
+	if except != nil {
+		exceptName = localNameOf(except)
+	}
+	for _, importSpec := range node.Imports {
+		if importSpec != except {
+			taken[localNameOf(importSpec)] = true
+		}
+	}
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.ImportSpec:
+			return false
+		case *ast.SelectorExpr:
+			if ident, ok := n.X.(*ast.Ident); !ok || ident.Name != exceptName || ident.Obj != nil {
+				ast.Inspect(n.X, visit)
+			}
+			return false
+		case *ast.Ident:
+			taken[n.Name] = true
+		}
+		return true
+	}
+	ast.Inspect(node, visit)
+	return taken
+}
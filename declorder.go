@@ -0,0 +1,43 @@
+package gogroupimports
+
+import "go/token"
+
+// RuleDeclarationOrder is reported for files keeping several import declarations whose groups
+// are out of order or split between them
+const RuleDeclarationOrder = "declaration-order"
+
+// checkDeclarationOrder checks the groups of every import declaration against those of the
+// declarations before it: together they must follow the expected sequence, with every import
+// type in a single declaration, as if they were one block. With FactorImports the factored
+// rule reports the declarations instead, and fixes merge and regroup them.
+func checkDeclarationOrder(fset *token.FileSet, groups []ImportGroup, settings Settings) []Diagnostic {
+	if settings.FactorImports {
+		return nil
+	}
+	var diagnostics []Diagnostic
+	var latest, latestOfBlock ImportGroup // Groups of the type furthest along the sequence
+	hasLatest, hasLatestOfBlock := false, false
+	lastOfType := make(map[string]ImportGroup)
+	for i, group := range groups {
+		if i > 0 && group.block != groups[i-1].block && hasLatestOfBlock {
+			if !hasLatest || compareImportTypes(latestOfBlock.importType, latest.importType, settings) > 0 {
+				latest, hasLatest = latestOfBlock, true
+			}
+			hasLatestOfBlock = false
+		}
+
+		if last, ok := lastOfType[group.importType]; ok && last.block != group.block {
+			diagnostics = append(diagnostics, newDiagnostic(fset, group.pos, RuleDeclarationOrder,
+				"the %s imports are split between import declarations, the others are on line %d", group.importType, last.startLine))
+		} else if hasLatest && compareImportTypes(latest.importType, group.importType, settings) > 0 {
+			diagnostics = append(diagnostics, newDiagnostic(fset, group.pos, RuleDeclarationOrder,
+				"imports are not properly grouped across import declarations: %s imports must come before the %s imports on line %d", group.importType, latest.importType, latest.startLine))
+		}
+
+		lastOfType[group.importType] = group
+		if !hasLatestOfBlock || compareImportTypes(group.importType, latestOfBlock.importType, settings) > 0 {
+			latestOfBlock, hasLatestOfBlock = group, true
+		}
+	}
+	return diagnostics
+}
@@ -0,0 +1,299 @@
+package gogroupimports
+
+import (
+	"go/ast"
+	"go/build"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// checkDeprecatedImports reports every import listed in settings.DeprecatedImports
+// together with the suggested replacement paths
+func checkDeprecatedImports(fset *token.FileSet, node *ast.File, settings Settings) []Diagnostic {
+	if len(settings.DeprecatedImports) == 0 {
+		return nil
+	}
+
+	var diagnostics []Diagnostic
+	for _, importSpec := range node.Imports {
+		path := importPathOf(importSpec)
+		replacements, ok := settings.DeprecatedImports[path]
+		if !ok {
+			continue
+		}
+		diagnostic := newDiagnostic(fset, importSpec.Pos(), RuleDeprecated,
+			"import %q is deprecated%s", path, suggestion(replacements))
+		// Fix moves the uses of the package to the replacements, blank and dot imports have none
+		diagnostic.Fixable = len(replacements) > 0 && (importSpec.Name == nil || importSpec.Name.Name != "_" && importSpec.Name.Name != ".")
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	return diagnostics
+}
+
+// suggestion formats the replacement paths as a human readable hint
+func suggestion(replacements []string) string {
+	if len(replacements) == 0 {
+		return ""
+	}
+	quoted := make([]string, len(replacements))
+	for i, replacement := range replacements {
+		quoted[i] = strconv.Quote(replacement)
+	}
+	return ", use " + strings.Join(quoted, " or ") + " instead"
+}
+
+// replacementPackage describes a package a deprecated import can be migrated to
+type replacementPackage struct {
+	path      string
+	localName string          // Identifier the package is referenced by in the file being fixed
+	exports   map[string]bool // Exported top-level identifiers of the package
+	imported  bool            // Whether the file already imports the package
+	aliased   bool            // localName is an alias, the name of the package is taken in the file
+}
+
+// specText returns the import spec adding target to a file
+func (target *replacementPackage) specText() string {
+	if target.aliased {
+		return target.localName + " " + strconv.Quote(target.path)
+	}
+	return strconv.Quote(target.path)
+}
+
+// fixDeprecatedImports rewrites deprecated imports and the selectors referencing them to the
+// first replacement package exporting the same identifier. Call sites without a name-compatible
+// replacement are left untouched and reported.
+func fixDeprecatedImports(fset *token.FileSet, node *ast.File, src []byte, settings Settings, ctxt *build.Context, srcDir string) ([]textEdit, []Diagnostic) {
+	if len(settings.DeprecatedImports) == 0 {
+		return nil, nil
+	}
+
+	var edits []textEdit
+	var diagnostics []Diagnostic
+	for _, decl := range node.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			importSpec := spec.(*ast.ImportSpec)
+			path := importPathOf(importSpec)
+			replacements := settings.DeprecatedImports[path]
+			if len(replacements) == 0 {
+				continue
+			}
+			if importSpec.Name != nil && (importSpec.Name.Name == "_" || importSpec.Name.Name == ".") {
+				diagnostics = append(diagnostics, newDiagnostic(fset, importSpec.Pos(), RuleDeprecated,
+					"cannot migrate %s import of %q automatically", importSpec.Name.Name, path))
+				continue
+			}
+
+			specEdits, specDiagnostics := migrateImport(fset, node, src, genDecl, importSpec, replacements, settings.AliasScheme, ctxt, srcDir)
+			edits = append(edits, specEdits...)
+			diagnostics = append(diagnostics, specDiagnostics...)
+		}
+	}
+	return edits, diagnostics
+}
+
+// migrateImport computes the edits moving the uses of a single deprecated import to its
+// replacements. Replacements whose name is taken in the file are added with an alias following
+// aliasScheme, so that the result still compiles.
+func migrateImport(fset *token.FileSet, node *ast.File, src []byte, genDecl *ast.GenDecl, importSpec *ast.ImportSpec, replacements []string, aliasScheme string, ctxt *build.Context, srcDir string) ([]textEdit, []Diagnostic) {
+	var diagnostics []Diagnostic
+	path := importPathOf(importSpec)
+	localName := localNameOf(importSpec)
+
+	var targets []*replacementPackage
+	for _, replacement := range replacements {
+		target, err := loadReplacement(node, importSpec, replacement, ctxt, srcDir)
+		if err != nil {
+			diagnostics = append(diagnostics, newDiagnostic(fset, importSpec.Pos(), RuleDeprecated,
+				"cannot migrate %q to %q: %v", path, replacement, err))
+			continue
+		}
+		targets = append(targets, target)
+	}
+	if len(targets) == 0 {
+		return nil, diagnostics
+	}
+
+	uses := packageSelectors(node, localName)
+	migrated, complete := matchReplacements(uses, targets)
+	// The deprecated import stays unless every use migrates, then replacements can't take its name
+	leaving := importSpec
+	if !complete {
+		leaving = nil
+	}
+	taken := takenNames(node, leaving)
+	for _, target := range targets {
+		if target.imported {
+			continue
+		}
+		if taken[target.localName] {
+			target.localName = conflictAlias(target.path, target.localName, taken, aliasScheme)
+			target.aliased = true
+		}
+		taken[target.localName] = true
+	}
+
+	var edits []textEdit
+	var added []*replacementPackage
+	for _, use := range uses {
+		target, ok := migrated[use]
+		if !ok {
+			diagnostics = append(diagnostics, newDiagnostic(fset, use.Pos(), RuleDeprecated,
+				"cannot migrate %s.%s: no replacement for %q exports %s", localName, use.Sel.Name, path, use.Sel.Name))
+			continue
+		}
+		if target.localName != localName {
+			ident := use.X.(*ast.Ident)
+			edits = append(edits, textEdit{start: offsetOf(fset, ident.Pos()), end: offsetOf(fset, ident.End()), text: target.localName})
+		}
+		if !target.imported && !containsPackage(added, target) {
+			added = append(added, target)
+		}
+	}
+
+	var specs []string
+	for _, target := range added {
+		specs = append(specs, target.specText())
+	}
+
+	if !complete {
+		return append(edits, insertImportSpecs(fset, src, genDecl, importSpec, specs)...), diagnostics
+	}
+	if len(uses) == 0 {
+		// Nothing references the package, so swapping the path is always safe
+		specs = nil
+		if !targets[0].imported {
+			specs = []string{targets[0].specText()}
+		}
+	}
+	return append(edits, replaceImportSpec(fset, src, genDecl, importSpec, specs)...), diagnostics
+}
+
+// matchReplacements assigns each selector to the first target exporting its identifier and reports
+// whether every selector could be assigned
+func matchReplacements(uses []*ast.SelectorExpr, targets []*replacementPackage) (map[*ast.SelectorExpr]*replacementPackage, bool) {
+	migrated := make(map[*ast.SelectorExpr]*replacementPackage)
+	complete := true
+	for _, use := range uses {
+		found := false
+		for _, target := range targets {
+			if target.exports[use.Sel.Name] {
+				migrated[use] = target
+				found = true
+				break
+			}
+		}
+		complete = complete && found
+	}
+	return migrated, complete
+}
+
+// loadReplacement resolves how the replacement package would be referenced from node, by the
+// name of an import of it already in place. migrateImport aliases it when its name is taken.
+func loadReplacement(node *ast.File, deprecated *ast.ImportSpec, path string, ctxt *build.Context, srcDir string) (*replacementPackage, error) {
+	name, exports, err := loadPackageExports(ctxt, path, srcDir)
+	if err != nil {
+		return nil, err
+	}
+	target := &replacementPackage{path: path, localName: name, exports: exports}
+
+	for _, importSpec := range node.Imports {
+		if importSpec == deprecated {
+			continue
+		}
+		if importPathOf(importSpec) == path {
+			target.imported = true
+			target.localName = localNameOf(importSpec)
+			break
+		}
+	}
+	return target, nil
+}
+
+// replaceImportSpec replaces importSpec with specs, removing it entirely when specs is empty
+func replaceImportSpec(fset *token.FileSet, src []byte, genDecl *ast.GenDecl, importSpec *ast.ImportSpec, specs []string) []textEdit {
+	if len(specs) == 0 {
+		if !genDecl.Lparen.IsValid() || len(genDecl.Specs) == 1 {
+			return []textEdit{removeLines(fset, src, genDecl.Doc, genDecl.Pos(), genDecl.End())}
+		}
+		return []textEdit{removeLines(fset, src, importSpec.Doc, importSpec.Pos(), importSpec.End())}
+	}
+
+	start, end := offsetOf(fset, importSpec.Pos()), offsetOf(fset, importSpec.Path.End())
+	text := strings.Join(specs, "\n"+indentOf(src, start))
+	if !genDecl.Lparen.IsValid() && len(specs) > 1 {
+		text = "(\n\t" + strings.Join(specs, "\n\t") + "\n)"
+	}
+	return []textEdit{{start: start, end: end, text: text}}
+}
+
+// insertImportSpecs adds specs directly after importSpec
+func insertImportSpecs(fset *token.FileSet, src []byte, genDecl *ast.GenDecl, importSpec *ast.ImportSpec, specs []string) []textEdit {
+	if len(specs) == 0 {
+		return nil
+	}
+
+	var text strings.Builder
+	if genDecl.Lparen.IsValid() {
+		indent := indentOf(src, offsetOf(fset, importSpec.Pos()))
+		for _, spec := range specs {
+			text.WriteString(indent + spec + "\n")
+		}
+		offset := nextLineStart(src, offsetOf(fset, importSpec.End()))
+		return []textEdit{{start: offset, end: offset, text: text.String()}}
+	}
+
+	for _, spec := range specs {
+		text.WriteString("import " + spec + "\n")
+	}
+	offset := nextLineStart(src, offsetOf(fset, genDecl.End()))
+	return []textEdit{{start: offset, end: offset, text: text.String()}}
+}
+
+// removeLines deletes the whole lines spanning from pos (or its doc comment) to end
+func removeLines(fset *token.FileSet, src []byte, doc *ast.CommentGroup, pos, end token.Pos) textEdit {
+	if doc != nil {
+		pos = doc.Pos()
+	}
+	return textEdit{start: lineStart(src, offsetOf(fset, pos)), end: nextLineStart(src, offsetOf(fset, end))}
+}
+
+// indentOf returns the whitespace preceding offset on its line
+func indentOf(src []byte, offset int) string {
+	start := lineStart(src, offset)
+	indent := src[start:offset]
+	if strings.TrimSpace(string(indent)) != "" {
+		return ""
+	}
+	return string(indent)
+}
+
+// packageSelectors returns all selector expressions qualified by the package identifier name.
+// Identifiers resolved to a local object shadow the package and are skipped.
+func packageSelectors(node *ast.File, name string) []*ast.SelectorExpr {
+	var selectors []*ast.SelectorExpr
+	ast.Inspect(node, func(n ast.Node) bool {
+		selector, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if ident, ok := selector.X.(*ast.Ident); ok && ident.Name == name && ident.Obj == nil {
+			selectors = append(selectors, selector)
+		}
+		return true
+	})
+	return selectors
+}
+
+func containsPackage(targets []*replacementPackage, target *replacementPackage) bool {
+	for _, t := range targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package gogroupimports
+
+import (
+	"fmt"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// Rule names reported on diagnostics
+const (
+	RuleGrouping    = "grouping"
+	RuleSeparator   = "separator"
+	RuleDeprecated  = "deprecated"
+	RuleInternal    = "internal"
+	RuleLayer       = "layer"
+	RuleTestOnly    = "test-only"
+	RuleTestPackage = "test-package"
+	RuleImportPath  = "import-path"
+)
+
+// fixableRules are resolved by Fix when it can rewrite the file, see narrowFixable. Deprecated
+// imports are fixable unless imported for side effects or with a dot, see checkDeprecatedImports.
+var fixableRules = map[string]bool{RuleGrouping: true, RuleSeparator: true, RuleFactored: true, RuleBlockPadding: true,
+	RuleMisplacedImport: true, RulePackageSpacing: true, RuleUnnecessaryAlias: true, RuleImportOrder: true,
+}
+
+// layoutRules report where imports are placed in the file, their diagnostics point at the
+// physical position even behind //line directives, like the messages naming lines do
+var layoutRules = map[string]bool{RuleGrouping: true, RuleSeparator: true, RuleDeclarationOrder: true,
+	RuleBlockPadding: true, RulePackageSpacing: true, RuleFactored: true, RuleMisplacedImport: true,
+	RuleLineDirective: true, RuleImportOrder: true,
+}
+
+// Diagnostic describes a single problem found in a file
+type Diagnostic struct {
+	Path     string `json:"path"`     // File the problem was found in
+	Line     int    `json:"line"`     // 1-based line of the offending import
+	Column   int    `json:"column"`   // 1-based column of the offending import
+	Rule     string `json:"rule"`     // Name of the rule that produced the diagnostic
+	Severity string `json:"severity"` // SeverityError, SeverityWarning or SeverityInfo
+	Message  string `json:"message"`  // Human readable description
+	// Variant is the build variant of the file, like linux, windows/amd64 or the expression of its
+	// //go:build line, so that problems of platform specific files can be told apart
+	Variant string `json:"variant,omitempty"`
+	// Fixable tells that Fix resolves the problem, so that it can be applied without review
+	Fixable bool `json:"fixable"`
+
+	pos token.Pos // Position in the FileSet the file was parsed into
+}
+
+func (d Diagnostic) String() string {
+	rule := d.Rule
+	if d.Variant != "" {
+		rule += ", " + d.Variant
+	}
+	if d.Severity == SeverityWarning || d.Severity == SeverityInfo {
+		return fmt.Sprintf("%s:%d:%d: %s: %s (%s)", d.Path, d.Line, d.Column, d.Severity, d.Message, rule)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s (%s)", d.Path, d.Line, d.Column, d.Message, rule)
+}
+
+// SortDiagnostics orders diagnostics by path and position, then by rule and message, giving
+// the same order however the files were scheduled
+func SortDiagnostics(diagnostics []Diagnostic) {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		switch {
+		case a.Path != b.Path:
+			return a.Path < b.Path
+		case a.Line != b.Line:
+			return a.Line < b.Line
+		case a.Column != b.Column:
+			return a.Column < b.Column
+		case a.Rule != b.Rule:
+			return a.Rule < b.Rule
+		default:
+			return a.Message < b.Message
+		}
+	})
+}
+
+// newDiagnostic builds a diagnostic positioned at pos, see layoutRules
+func newDiagnostic(fset *token.FileSet, pos token.Pos, rule string, format string, args ...interface{}) Diagnostic {
+	position := fset.PositionFor(pos, !layoutRules[rule])
+	return Diagnostic{
+		Path:     position.Filename,
+		Line:     position.Line,
+		Column:   position.Column,
+		Rule:     rule,
+		Severity: SeverityError,
+		Message:  fmt.Sprintf(format, args...),
+		pos:      pos,
+	}
+}
+
+// Diagnostics is the error returned by Run and Fix for files with problems. Use errors.As to
+// get at the individual diagnostics, the Checker methods return them directly.
+type Diagnostics []Diagnostic
+
+func (diagnostics Diagnostics) Error() string {
+	lines := make([]string, len(diagnostics))
+	for i, diagnostic := range diagnostics {
+		lines[i] = diagnostic.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// diagnosticsError returns diagnostics as an error, or nil when there are none
+func diagnosticsError(diagnostics []Diagnostic) error {
+	if len(diagnostics) == 0 {
+		return nil
+	}
+	return Diagnostics(diagnostics)
+}
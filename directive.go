@@ -0,0 +1,78 @@
+package gogroupimports
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// RuleDirective is reported for malformed //gogroupimports: directives
+const RuleDirective = "directive"
+
+// groupDirective starts a comment classifying import paths differently within one file, e.g.
+// //gogroupimports:group github.com/special=internal_private
+const groupDirective = "//gogroupimports:group "
+
+// groupOverride classifies the imports matching pattern as importType
+type groupOverride struct {
+	pattern    string
+	importType string
+}
+
+// withFileDirectives returns settings with the group directives of node applied. Directives
+// are read from the comments before the first declaration that isn't an import, like the file
+// header, so that they are found when only the imports are parsed.
+func withFileDirectives(fset *token.FileSet, node *ast.File, settings Settings) (Settings, []Diagnostic) {
+	end := node.FileEnd
+	for _, decl := range node.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); !ok || genDecl.Tok != token.IMPORT {
+			end = decl.Pos()
+			break
+		}
+	}
+
+	var overrides []groupOverride
+	var diagnostics []Diagnostic
+	for _, group := range node.Comments {
+		if group.Pos() > end {
+			break
+		}
+		for _, comment := range group.List {
+			if !strings.HasPrefix(comment.Text, groupDirective) {
+				continue
+			}
+			pattern, importType, ok := strings.Cut(strings.TrimSpace(strings.TrimPrefix(comment.Text, groupDirective)), "=")
+			pattern, importType = strings.TrimSpace(pattern), strings.TrimSpace(importType)
+			if !ok || pattern == "" || !isImportType(importType) {
+				diagnostics = append(diagnostics, newDiagnostic(fset, comment.Pos(), RuleDirective,
+					"malformed directive %q, want %spath=type with type one of %s", comment.Text, groupDirective, strings.Join(expectedSequence, ", ")))
+				continue
+			}
+			overrides = append(overrides, groupOverride{pattern: pattern, importType: importType})
+		}
+	}
+	if len(overrides) > 0 {
+		settings.overrides = append(overrides, settings.overrides...)
+	}
+	return settings, diagnostics
+}
+
+// overriddenType returns the import type a group directive assigns to path
+func overriddenType(path string, settings Settings) (string, bool) {
+	for _, override := range settings.overrides {
+		if matchesImport(override.pattern, path) {
+			return override.importType, true
+		}
+	}
+	return "", false
+}
+
+// isImportType reports whether importType is one of the fixed import types
+func isImportType(importType string) bool {
+	for _, expected := range expectedSequence {
+		if expected == importType {
+			return true
+		}
+	}
+	return false
+}
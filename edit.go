@@ -0,0 +1,129 @@
+package gogroupimports
+
+import (
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// textEdit replaces the bytes in [start, end) of a source file with text
+type textEdit struct {
+	start int
+	end   int
+	text  string
+}
+
+// applyEdits returns a copy of src with all edits applied. Edits must not overlap.
+func applyEdits(src []byte, edits []textEdit) []byte {
+	sorted := make([]textEdit, len(edits))
+	copy(sorted, edits)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].start < sorted[j].start
+	})
+
+	out := make([]byte, 0, len(src))
+	last := 0
+	for _, edit := range sorted {
+		out = append(out, src[last:edit.start]...)
+		out = append(out, edit.text...)
+		last = edit.end
+	}
+	return append(out, src[last:]...)
+}
+
+// offsetOf converts pos into a byte offset within its file
+func offsetOf(fset *token.FileSet, pos token.Pos) int {
+	return fset.Position(pos).Offset
+}
+
+// lineStart returns the offset of the first byte of the line containing offset
+func lineStart(src []byte, offset int) int {
+	for offset > 0 && src[offset-1] != '\n' {
+		offset--
+	}
+	return offset
+}
+
+// nextLineStart returns the offset just past the newline ending the line containing offset
+func nextLineStart(src []byte, offset int) int {
+	for offset < len(src) && src[offset] != '\n' {
+		offset++
+	}
+	if offset < len(src) {
+		offset++
+	}
+	return offset
+}
+
+// lineOf returns the physical line of pos, ignoring //line directives, which is what layout
+// checks and rewrites have to work with
+func lineOf(fset *token.FileSet, pos token.Pos) int {
+	return fset.PositionFor(pos, false).Line
+}
+
+// lineEdits returns the edits turning src[start:end] into text, which must both consist of
+// complete lines. Only the lines outside the longest common subsequence of both are touched,
+// so imports that keep their place aren't rewritten.
+func lineEdits(src []byte, start, end int, text string) []textEdit {
+	oldLines := splitLines(string(src[start:end]))
+	newLines := splitLines(text)
+
+	// common[i][j] is the length of the longest common subsequence of oldLines[i:] and newLines[j:]
+	common := make([][]int, len(oldLines)+1)
+	for i := range common {
+		common[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var edits []textEdit
+	var pending *textEdit
+	offset := start
+	flush := func() {
+		if pending != nil {
+			pending.end = offset
+			edits = append(edits, *pending)
+			pending = nil
+		}
+	}
+	i, j := 0, 0
+	for i < len(oldLines) || j < len(newLines) {
+		switch {
+		case i < len(oldLines) && j < len(newLines) && oldLines[i] == newLines[j]:
+			flush()
+			offset += len(oldLines[i])
+			i++
+			j++
+		case j < len(newLines) && (i == len(oldLines) || common[i][j+1] >= common[i+1][j]):
+			if pending == nil {
+				pending = &textEdit{start: offset}
+			}
+			pending.text += newLines[j]
+			j++
+		default:
+			if pending == nil {
+				pending = &textEdit{start: offset}
+			}
+			offset += len(oldLines[i])
+			i++
+		}
+	}
+	flush()
+	return edits
+}
+
+// splitLines splits s after every newline
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
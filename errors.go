@@ -0,0 +1,44 @@
+package gogroupimports
+
+import (
+	"strings"
+)
+
+// FileError is an operational error that occurred while processing one file
+type FileError struct {
+	Path string // File that couldn't be processed
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// Errors collects the independent failures of a run over many files, usually one FileError
+// per file. Like the errors of errors.Join it unwraps into its elements, so errors.Is and
+// errors.As look at every failure.
+type Errors []error
+
+func (errs Errors) Error() string {
+	lines := make([]string, len(errs))
+	for i, err := range errs {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (errs Errors) Unwrap() []error {
+	return errs
+}
+
+// joinErrors returns errs as an error, or nil when there are none
+func joinErrors(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return Errors(errs)
+}
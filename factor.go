@@ -0,0 +1,116 @@
+package gogroupimports
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// RuleFactored is reported for imports spread over several declarations when FactorImports is set
+const RuleFactored = "factored"
+
+// factorableDecls returns the import declarations of node that belong into a single block.
+// import "C" stays on its own, cgo reads the comment directly above it.
+func factorableDecls(node *ast.File) []*ast.GenDecl {
+	var decls []*ast.GenDecl
+	for _, decl := range node.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT || len(genDecl.Specs) == 0 {
+			continue
+		}
+		if len(genDecl.Specs) == 1 && importPathOf(genDecl.Specs[0].(*ast.ImportSpec)) == "C" {
+			continue
+		}
+		decls = append(decls, genDecl)
+	}
+	return decls
+}
+
+// checkFactoredImports reports every import declaration following the first one, their imports
+// can't be grouped together with the others
+func checkFactoredImports(fset *token.FileSet, node *ast.File, settings Settings) []Diagnostic {
+	if !settings.FactorImports {
+		return nil
+	}
+	var diagnostics []Diagnostic
+	decls := factorableDecls(node)
+	for i := 1; i < len(decls); i++ {
+		diagnostics = append(diagnostics, newDiagnostic(fset, decls[i].Pos(), RuleFactored,
+			"imports should be factored into a single parenthesized block with the imports on line %d", lineOf(fset, decls[0].Pos())))
+	}
+	return diagnostics
+}
+
+// fixFactoredImports merges all import declarations into one parenthesized block in place of
+// the first one. The imports are added in their original order, fixImportGroups sorts them
+// into groups afterwards.
+func fixFactoredImports(fset *token.FileSet, node *ast.File, src []byte, settings Settings) ([]textEdit, []Diagnostic) {
+	decls := factorableDecls(node)
+	if !settings.FactorImports || len(decls) < 2 {
+		return nil, nil
+	}
+
+	var body strings.Builder
+	var edits []textEdit
+	for i, genDecl := range decls {
+		start, end := genDecl.Pos(), genDecl.End()
+		if genDecl.Doc != nil {
+			start = genDecl.Doc.Pos()
+		}
+		if spec := genDecl.Specs[0].(*ast.ImportSpec); !genDecl.Lparen.IsValid() && spec.Comment != nil {
+			end = spec.Comment.End()
+		}
+		startOffset, endOffset := offsetOf(fset, start), offsetOf(fset, end)
+		if !ownsLines(src, startOffset, endOffset) {
+			return nil, []Diagnostic{newDiagnostic(fset, genDecl.Pos(), RuleFactored,
+				"cannot factor imports automatically: every import declaration must be on lines of its own")}
+		}
+
+		for _, line := range docLines(fset, src, genDecl.Doc) {
+			body.WriteString("\t" + line + "\n")
+		}
+		if genDecl.Lparen.IsValid() {
+			lparen, rparen := offsetOf(fset, genDecl.Lparen), offsetOf(fset, genDecl.Rparen)
+			if strings.TrimSpace(string(src[lparen+1:nextLineStart(src, lparen)])) != "" || strings.TrimSpace(string(src[lineStart(src, rparen):rparen])) != "" {
+				return nil, []Diagnostic{newDiagnostic(fset, genDecl.Pos(), RuleFactored,
+					"cannot factor imports automatically: every import and the closing parenthesis must be on a line of their own")}
+			}
+			body.Write(src[nextLineStart(src, lparen):lineStart(src, rparen)])
+		} else {
+			spec := genDecl.Specs[0].(*ast.ImportSpec)
+			body.WriteString("\t" + string(src[offsetOf(fset, spec.Pos()):endOffset]) + "\n")
+		}
+
+		edit := textEdit{start: lineStart(src, startOffset), end: nextLineStart(src, endOffset)}
+		if i > 0 {
+			// Blank lines before a removed declaration go with it
+			previous := nextLineStart(src, offsetOf(fset, decls[i-1].End()))
+			if previous < edit.start && strings.TrimSpace(string(src[previous:edit.start])) == "" {
+				edit.start = previous
+			}
+		}
+		edits = append(edits, edit)
+	}
+	edits[0].text = "import (\n" + body.String() + ")\n"
+	return edits, nil
+}
+
+// ownsLines reports whether src[start:end] is preceded and followed only by blanks on its
+// first and last line
+func ownsLines(src []byte, start, end int) bool {
+	before := string(src[lineStart(src, start):start])
+	after := string(src[end:nextLineStart(src, end)])
+	return strings.TrimSpace(before) == "" && strings.TrimSpace(after) == ""
+}
+
+// docLines returns the lines of a doc comment, nil when there is none
+func docLines(fset *token.FileSet, src []byte, doc *ast.CommentGroup) []string {
+	if doc == nil {
+		return nil
+	}
+	var lines []string
+	for _, comment := range doc.List {
+		lines = append(lines, strings.Split(string(src[offsetOf(fset, comment.Pos()):offsetOf(fset, comment.End())]), "\n")...)
+	}
+	return lines
+}
@@ -0,0 +1,109 @@
+package gogroupimports
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// gitignoreRule is a single pattern of a .gitignore file
+type gitignoreRule struct {
+	base    string // Slash separated directory of the .gitignore relative to the walk root, "" for the root
+	pattern string // matchPattern pattern relative to base
+	negate  bool   // The pattern re-includes paths excluded before, it started with !
+	dirOnly bool   // The pattern only matches directories, it ended with /
+}
+
+// gitignore holds the .gitignore rules seen during a walk. Rules found later, which includes
+// those of deeper directories, take precedence like they do for git.
+type gitignore struct {
+	rules []gitignoreRule
+}
+
+// isRepositoryRoot reports whether dir is the top directory of a git work tree, where .git is a
+// directory or, for linked work trees and submodules, a file
+func isRepositoryRoot(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// load adds the rules of the file at path, which lives in the directory base relative to the
+// walk root. A missing file adds nothing, also when .git is a file rather than a directory.
+func (g *gitignore) load(path, base string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rule, ok := parseGitignoreLine(scanner.Text()); ok {
+			rule.base = base
+			g.rules = append(g.rules, rule)
+		}
+	}
+	return scanner.Err()
+}
+
+// parseGitignoreLine parses one line of a .gitignore file, reporting false for blank lines and
+// comments
+func parseGitignoreLine(line string) (gitignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	if !strings.HasSuffix(line, `\ `) {
+		line = strings.TrimRight(line, " \t")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return gitignoreRule{}, false
+	}
+
+	var rule gitignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if line == "" {
+		return gitignoreRule{}, false
+	}
+
+	// Patterns without a slash match at any depth, the others relative to the .gitignore
+	if strings.Contains(line, "/") {
+		rule.pattern = strings.TrimPrefix(line, "/")
+	} else {
+		rule.pattern = "**/" + line
+	}
+	return rule, true
+}
+
+// ignored reports whether the slash separated path rel, relative to the walk root, is excluded
+func (g *gitignore) ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range g.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		name := rel
+		if rule.base != "" {
+			if !strings.HasPrefix(rel, rule.base+"/") {
+				continue
+			}
+			name = strings.TrimPrefix(rel, rule.base+"/")
+		}
+		if matchPattern(rule.pattern, name) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
@@ -1,3 +1,13 @@
 module github.com/hsivakum/gogroupimports
 
 go 1.22.2
+
+require (
+	golang.org/x/crypto v0.26.0
+	golang.org/x/mod v0.21.0
+	golang.org/x/sync v0.8.0
+	golang.org/x/tools v0.26.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.26.0 // indirect
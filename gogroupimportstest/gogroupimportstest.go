@@ -0,0 +1,181 @@
+// Package gogroupimportstest runs a gogroupimports.Checker over test files and compares the
+// outcome with expectations kept next to them, the way analysistest does for analyzers. It is
+// meant for testing configs, custom sort orders and other extensions.
+//
+// Expected diagnostics are written as comments on the line they are reported for, holding one
+// or more quoted regular expressions matched against the diagnostic messages:
+//
+//	"fmt" // want `builtin imports must come before`
+//
+// Every diagnostic has to match an expectation on its line and every expectation has to be
+// matched by a diagnostic. For RunWithFixes the fixed source of file.go is compared with
+// file.go.golden.
+package gogroupimportstest
+
+import (
+	"bytes"
+	"errors"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hsivakum/gogroupimports"
+)
+
+// Testing is the part of *testing.T the helpers use
+type Testing interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Run checks every .go file of dir with c and reports unmatched diagnostics and expectations
+// on t
+func Run(t Testing, dir string, c *gogroupimports.Checker) {
+	t.Helper()
+	for _, file := range goFiles(t, dir) {
+		checkFile(t, c, file)
+	}
+}
+
+// RunWithFixes runs Run and compares the source fixed by c with the golden file of every
+// .go file of dir that has one
+func RunWithFixes(t Testing, dir string, c *gogroupimports.Checker) {
+	t.Helper()
+	for _, file := range goFiles(t, dir) {
+		checkFile(t, c, file)
+
+		golden, err := os.ReadFile(file + ".golden")
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			t.Errorf("%v", err)
+			continue
+		}
+		fixed, _, err := c.Fix(file)
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		if !bytes.Equal(fixed, golden) {
+			t.Errorf("%s: fixed source differs from %s.golden:\n%s", file, filepath.Base(file), lineDiff(string(golden), string(fixed)))
+		}
+	}
+}
+
+// expectation is a regular expression a diagnostic on its line has to match
+type expectation struct {
+	line    int
+	pattern *regexp.Regexp
+	matched bool
+}
+
+// checkFile compares the diagnostics of file with its want comments
+func checkFile(t Testing, c *gogroupimports.Checker, file string) {
+	t.Helper()
+	expectations, err := readExpectations(file)
+	if err != nil {
+		t.Errorf("%s: %v", file, err)
+		return
+	}
+
+	diagnostics, err := c.Check(file)
+	if err != nil {
+		t.Errorf("%s: %v", file, err)
+		return
+	}
+
+	for _, diagnostic := range diagnostics {
+		found := false
+		for _, expected := range expectations {
+			if expected.line == diagnostic.Line && !expected.matched && expected.pattern.MatchString(diagnostic.Message) {
+				expected.matched = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("%s: unexpected diagnostic: %s", file, diagnostic)
+		}
+	}
+	for _, expected := range expectations {
+		if !expected.matched {
+			t.Errorf("%s:%d: no diagnostic matching %q", file, expected.line, expected.pattern)
+		}
+	}
+}
+
+// readExpectations parses the want comments of file
+func readExpectations(file string) ([]*expectation, error) {
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	var expectations []*expectation
+	for _, group := range node.Comments {
+		for _, comment := range group.List {
+			text, ok := strings.CutPrefix(comment.Text, "// want ")
+			if !ok {
+				continue
+			}
+			line := fset.Position(comment.Pos()).Line
+			for text = strings.TrimSpace(text); text != ""; text = strings.TrimSpace(text) {
+				quoted, err := strconv.QuotedPrefix(text)
+				if err != nil {
+					return nil, errors.New(fset.Position(comment.Pos()).String() + ": want needs quoted regular expressions")
+				}
+				text = text[len(quoted):]
+				unquoted, _ := strconv.Unquote(quoted)
+				pattern, err := regexp.Compile(unquoted)
+				if err != nil {
+					return nil, err
+				}
+				expectations = append(expectations, &expectation{line: line, pattern: pattern})
+			}
+		}
+	}
+	return expectations, nil
+}
+
+// goFiles returns the .go files of dir in name order
+func goFiles(t Testing, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return nil
+	}
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".go") {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// lineDiff lists the lines that differ between want and got
+func lineDiff(want, got string) string {
+	wantLines, gotLines := strings.Split(want, "\n"), strings.Split(got, "\n")
+	var diff strings.Builder
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var wantLine, gotLine string
+		if i < len(wantLines) {
+			wantLine = wantLines[i]
+		}
+		if i < len(gotLines) {
+			gotLine = gotLines[i]
+		}
+		if wantLine != gotLine {
+			diff.WriteString(strconv.Itoa(i+1) + ":\n-" + wantLine + "\n+" + gotLine + "\n")
+		}
+	}
+	return diff.String()
+}
@@ -0,0 +1,31 @@
+package gogroupimports
+
+import "strings"
+
+// hostOf returns the hosting domain of an import path, its first element
+func hostOf(path string) string {
+	host, _, _ := strings.Cut(path, "/")
+	return host
+}
+
+// isHostType reports whether importType is a host group of the auto sections mode rather than
+// one of the fixed import types
+func isHostType(importType string) bool {
+	for _, expected := range expectedSequence {
+		if expected == importType {
+			return false
+		}
+	}
+	return true
+}
+
+// hostRank returns the position of host in the configured host order. Hosts that aren't
+// configured share the position after the last configured one.
+func hostRank(host string, settings Settings) int {
+	for i, configured := range settings.HostOrder {
+		if configured == host {
+			return i
+		}
+	}
+	return len(settings.HostOrder)
+}
@@ -0,0 +1,182 @@
+package gogroupimports
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+)
+
+// indexVersion is increased whenever the index format changes incompatibly
+const indexVersion = 1
+
+// classificationIndex holds everything a Checker otherwise probes the environment for, so that
+// CI runs can classify imports without running go list, scanning GOROOT or fetching vanity roots
+type classificationIndex struct {
+	Version     int                       `json:"version"`
+	GoVersion   string                    `json:"goVersion"`   // Go release the stdlib list was taken from
+	Stdlib      []string                  `json:"stdlib"`      // Standard library packages
+	Modules     map[string][]listedModule `json:"modules"`     // Build lists by module root, relative to the index
+	VanityRoots map[string]string         `json:"vanityRoots"` // Vanity import prefixes and where they are hosted, "" for none
+}
+
+// WriteIndex writes the stdlib packages, the build lists of the modules containing files and
+// the vanity roots of their imports to filename. Setting IndexFile to the file lets later
+// Checkers use these lookups instead of probing the environment again. Module roots are
+// stored relative to the index, so it stays valid when the checkout moves. When files can't
+// be read no index is written and every failure is returned in an Errors.
+func (c *Checker) WriteIndex(filename string, files []string) error {
+	base, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return err
+	}
+
+	index := classificationIndex{
+		Version:     indexVersion,
+		GoVersion:   runtime.Version(),
+		VanityRoots: make(map[string]string),
+	}
+	for path := range c.settings.stdlib {
+		index.Stdlib = append(index.Stdlib, path)
+	}
+	sort.Strings(index.Stdlib)
+
+	var errs []error
+	for _, file := range files {
+		settings, err := c.settingsFor(file)
+		if err != nil {
+			errs = append(errs, &FileError{Path: file, Err: err})
+			continue
+		}
+		if settings.modules != nil {
+			if index.Modules == nil {
+				index.Modules = make(map[string][]listedModule)
+			}
+			index.Modules[relativeTo(base, settings.modules.root)] = relativeModules(base, settings.modules)
+		}
+		if !settings.ResolveVanityImports {
+			continue
+		}
+
+		node, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.ImportsOnly)
+		if err != nil {
+			errs = append(errs, &FileError{Path: file, Err: err})
+			continue
+		}
+		for _, importSpec := range node.Imports {
+			path := importPathOf(importSpec)
+			if getSpecType(importSpec, settings) != "public_open_source_or_third_party" || isWellKnownHost(path) {
+				continue
+			}
+			root := lookupVanityRoot(path)
+			if root.prefix == "" {
+				index.VanityRoots[path] = ""
+				continue
+			}
+			index.VanityRoots[root.prefix] = root.repo
+		}
+	}
+
+	// An index missing some files would silently classify them differently later
+	if err := joinErrors(errs); err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(index, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(content, '\n'), 0o644)
+}
+
+// readIndex loads an index written by WriteIndex
+func readIndex(filename string) (*classificationIndex, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var index classificationIndex
+	if err := json.Unmarshal(content, &index); err != nil {
+		return nil, fmt.Errorf("invalid index %s: %w", filename, err)
+	}
+	if index.Version != indexVersion {
+		return nil, fmt.Errorf("index %s has version %d, want %d: rebuild it", filename, index.Version, indexVersion)
+	}
+	if index.GoVersion != runtime.Version() {
+		return nil, fmt.Errorf("index %s was built with %s, not %s: rebuild it", filename, index.GoVersion, runtime.Version())
+	}
+	return &index, nil
+}
+
+// useIndex makes c take its lookups from the index read from filename
+func (c *Checker) useIndex(filename string) error {
+	index, err := readIndex(filename)
+	if err != nil {
+		return err
+	}
+	base, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return err
+	}
+
+	c.settings.stdlib = make(map[string]bool, len(index.Stdlib))
+	for _, path := range index.Stdlib {
+		c.settings.stdlib[path] = true
+	}
+
+	c.modules.indexes = make(map[string]*moduleIndex, len(index.Modules))
+	for root, modules := range index.Modules {
+		root = resolveFrom(base, root)
+		for i, module := range modules {
+			if module.Main {
+				modules[i].Dir = resolveFrom(base, module.Dir)
+			}
+		}
+		c.modules.indexes[root] = newModuleIndex(root, modules)
+	}
+
+	vanityRoots.Lock()
+	defer vanityRoots.Unlock()
+	for prefix, repo := range index.VanityRoots {
+		root := vanityRoot{}
+		if repo != "" {
+			root = vanityRoot{prefix: prefix, repo: repo}
+		}
+		vanityRoots.roots[prefix] = root
+	}
+	return nil
+}
+
+// relativeModules returns the build list of index with the directories of the main modules
+// relative to base. Other modules live in the module cache and only need their path.
+func relativeModules(base string, index *moduleIndex) []listedModule {
+	modules := make([]listedModule, len(index.modules))
+	for i, module := range index.modules {
+		modules[i] = listedModule{Path: module.Path, Main: module.Main}
+		if module.Main {
+			modules[i].Dir = relativeTo(base, module.Dir)
+		}
+	}
+	return modules
+}
+
+// relativeTo returns path relative to base, or path itself when it can't be expressed that way
+func relativeTo(base, path string) string {
+	if rel, err := filepath.Rel(base, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// resolveFrom is the reverse of relativeTo
+func resolveFrom(base, path string) string {
+	path = filepath.FromSlash(path)
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(base, path)
+}
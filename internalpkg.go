@@ -0,0 +1,59 @@
+package gogroupimports
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// checkInternalImports reports imports of internal packages that belong to another module
+// or to the standard library. The go tool rejects them at build time, but the diagnostic
+// points at the offending line.
+func checkInternalImports(fset *token.FileSet, node *ast.File, settings Settings) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, importSpec := range node.Imports {
+		path := importPathOf(importSpec)
+		parent, ok := internalParent(path)
+		if !ok || hasTemplateAction(path, settings) || !isForeignInternal(path, parent, settings) {
+			continue
+		}
+
+		owner := parent
+		if owner == "" {
+			owner = "the standard library"
+		}
+		diagnostics = append(diagnostics, newDiagnostic(fset, importSpec.Pos(), RuleInternal,
+			"import %q is internal to %s and cannot be imported from another module", path, owner))
+	}
+	return diagnostics
+}
+
+// internalParent returns the path that may import the internal package path, i.e. everything
+// before its last internal element. It reports false when path is not an internal package.
+func internalParent(path string) (string, bool) {
+	switch {
+	case strings.HasSuffix(path, "/internal"):
+		return strings.TrimSuffix(path, "/internal"), true
+	case strings.Contains(path, "/internal/"):
+		return path[:strings.LastIndex(path, "/internal/")], true
+	case path == "internal" || strings.HasPrefix(path, "internal/"):
+		return "", true
+	}
+	return "", false
+}
+
+// isForeignInternal reports whether the internal package path with the given parent lives
+// outside of the own module. Without a known own module only standard library internals
+// can be told apart.
+func isForeignInternal(path, parent string, settings Settings) bool {
+	if module, ok := settings.modules.lookup(path); ok {
+		return !settings.modules.isOwn(module)
+	}
+	if isBuiltinImport(path, settings) {
+		return true
+	}
+	if settings.SelfModule == "" {
+		return false
+	}
+	return parent != settings.SelfModule && !strings.HasPrefix(parent, settings.SelfModule+"/")
+}
@@ -0,0 +1,155 @@
+package gogroupimports
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LayerRule restricts what the packages matching Packages may import. Patterns are slash
+// separated, match either full import paths or paths relative to the own module, and support
+// path.Match syntax within an element plus ** for any number of elements.
+type LayerRule struct {
+	Packages string   `json:"packages"` // Importing packages the rule applies to, e.g. "pkg/domain/**"
+	Deny     []string `json:"deny"`     // Imports the packages may not use, e.g. "pkg/transport/**"
+	Allow    []string `json:"allow"`    // Exceptions to Deny
+}
+
+// checkLayers reports imports that a layer rule denies for the package of filename
+func checkLayers(fset *token.FileSet, node *ast.File, settings Settings, filename string) []Diagnostic {
+	if len(settings.LayerRules) == 0 {
+		return nil
+	}
+
+	pkgPath, relPath := packagePathOf(filename, settings)
+	var diagnostics []Diagnostic
+	for _, rule := range settings.LayerRules {
+		if !matchesPackage(rule.Packages, pkgPath, relPath) {
+			continue
+		}
+		for _, importSpec := range node.Imports {
+			importPath := importPathOf(importSpec)
+			if !matchesAnyPackage(rule.Deny, importPath, settings) || matchesAnyPackage(rule.Allow, importPath, settings) {
+				continue
+			}
+			diagnostics = append(diagnostics, newDiagnostic(fset, importSpec.Pos(), RuleLayer,
+				"packages matching %q may not import %q", rule.Packages, importPath))
+		}
+	}
+	return diagnostics
+}
+
+// packagePathOf returns the import path of the package containing filename and the same path
+// relative to its module root. The import path is empty when the own module is unknown.
+func packagePathOf(filename string, settings Settings) (string, string) {
+	root, err := findModuleRoot(filepath.Dir(filename))
+	if err != nil {
+		return "", ""
+	}
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return "", ""
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return "", ""
+	}
+	rel = filepath.ToSlash(rel)
+
+	if settings.SelfModule == "" {
+		return "", rel
+	}
+	if rel == "." {
+		return settings.SelfModule, rel
+	}
+	return settings.SelfModule + "/" + rel, rel
+}
+
+// matchesPackage reports whether pattern matches the full or module relative package path
+func matchesPackage(pattern, pkgPath, relPath string) bool {
+	return (pkgPath != "" && matchPattern(pattern, pkgPath)) || (relPath != "" && matchPattern(pattern, relPath))
+}
+
+// matchesAnyPackage reports whether any pattern matches importPath. Imports of the own module
+// are also matched relative to it.
+func matchesAnyPackage(patterns []string, importPath string, settings Settings) bool {
+	relPath := ""
+	if settings.SelfModule != "" && strings.HasPrefix(importPath, settings.SelfModule+"/") {
+		relPath = strings.TrimPrefix(importPath, settings.SelfModule+"/")
+	}
+	for _, pattern := range patterns {
+		if matchesPackage(pattern, importPath, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// validatePatterns checks the syntax of every pattern in settings up front, so that a typo
+// doesn't silently turn a rule off
+func validatePatterns(settings Settings) error {
+	patterns := append([]string(nil), settings.TestOnlyImports...)
+	patterns = append(patterns, settings.TestPackagePatterns...)
+	patterns = append(patterns, settings.SideEffectImports...)
+	patterns = append(patterns, settings.GeneratedImports...)
+	patterns = append(patterns, settings.SortPriority...)
+	for _, rule := range settings.LayerRules {
+		patterns = append(patterns, rule.Packages)
+		patterns = append(patterns, rule.Deny...)
+		patterns = append(patterns, rule.Allow...)
+	}
+
+	for _, pattern := range patterns {
+		if err := checkPattern(pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkPattern checks the syntax of a single pattern
+func checkPattern(pattern string) error {
+	for _, element := range strings.Split(pattern, "/") {
+		if _, err := path.Match(element, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchPattern matches a slash separated path against pattern, where ** matches any number
+// of path elements and every other element is matched with path.Match
+func matchPattern(pattern, name string) bool {
+	return matchElements(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// MatchPattern reports whether the slash separated path name matches pattern the way import
+// patterns of the settings do: ** matches any number of path elements, every other element is
+// matched with path.Match. Invalid patterns match nothing.
+func MatchPattern(pattern, name string) bool {
+	return matchPattern(pattern, name)
+}
+
+func matchElements(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchElements(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,112 @@
+package gogroupimports
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"runtime"
+	"time"
+)
+
+// RuleSkipped is reported for files left unchecked because they exceed MaxFileSize, MaxImports
+// or ParseTimeout
+const RuleSkipped = "skipped"
+
+// errParseTimeout is returned by parseFile for files taking longer than ParseTimeout to parse
+var errParseTimeout = errors.New("parse timeout")
+
+// exceedsLimits returns the diagnostic for a file of size bytes with imports imports that is
+// too large to check, imports is -1 when they haven't been counted yet
+func exceedsLimits(filename string, size int64, imports int, settings Settings) (Diagnostic, bool) {
+	switch {
+	case settings.MaxFileSize > 0 && size > settings.MaxFileSize:
+		return skippedDiagnostic(filename, settings, "the file has %d bytes, more than maxFileSize %d", size, settings.MaxFileSize), true
+	case settings.MaxImports > 0 && imports > settings.MaxImports:
+		return skippedDiagnostic(filename, settings, "the file has %d imports, more than maxImports %d", imports, settings.MaxImports), true
+	}
+	return Diagnostic{}, false
+}
+
+// parseTimedOut returns the diagnostic for a file whose parse was abandoned after ParseTimeout
+func parseTimedOut(filename string, settings Settings) Diagnostic {
+	return skippedDiagnostic(filename, settings, "parsing took longer than parseTimeout %s", settings.parseTimeout)
+}
+
+// skippedDiagnostic returns the informational diagnostic of a file left unchecked
+func skippedDiagnostic(filename string, settings Settings, format string, args ...interface{}) Diagnostic {
+	diagnostics := applySeverities([]Diagnostic{{
+		Path:     filename,
+		Line:     1,
+		Column:   1,
+		Rule:     RuleSkipped,
+		Severity: SeverityInfo,
+		Message:  "not checked, " + fmt.Sprintf(format, args...),
+	}}, settings)
+	return diagnostics[0]
+}
+
+// parseForCheck parses src with mode, the one parse deciding whether the file is checked at
+// all. ok is false for files that aren't: files without imports, like doc.go files and main
+// stubs, and files exceeding the limits or ParseTimeout, which get a diagnostic saying so.
+// Files that don't parse are an error.
+func parseForCheck(fset *token.FileSet, filename string, src []byte, mode parser.Mode, settings Settings) (node *ast.File, skipped []Diagnostic, ok bool, err error) {
+	if diagnostic, exceeds := exceedsLimits(filename, int64(len(src)), -1, settings); exceeds {
+		return nil, []Diagnostic{diagnostic}, false, nil
+	}
+	node, err = parseFile(fset, filename, src, mode, settings)
+	if errors.Is(err, errParseTimeout) {
+		return nil, []Diagnostic{parseTimedOut(filename, settings)}, false, nil
+	}
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to parse file: %w", err)
+	}
+	// With ImportsOnly, imports following other declarations are only found by a full parse
+	if len(node.Imports) == 0 && (mode&parser.ImportsOnly == 0 || !bytes.Contains(src, []byte("import"))) {
+		return nil, nil, false, nil
+	}
+	if diagnostic, exceeds := exceedsLimits(filename, int64(len(src)), len(node.Imports), settings); exceeds {
+		return nil, []Diagnostic{diagnostic}, false, nil
+	}
+	return node, nil, true, nil
+}
+
+// parseSlots holds a value for every parse with a ParseTimeout under way, including those
+// abandoned after it, so that abandoned parses can't pile up beyond one per CPU
+var parseSlots = make(chan struct{}, runtime.GOMAXPROCS(0))
+
+// parseFile parses src like parser.ParseFile, giving up with errParseTimeout after
+// ParseTimeout. The parser can't be interrupted, so an abandoned parse finishes in the
+// background, but corrupted or malicious files no longer stall the run. The timeout bounds the
+// latency, not the work: an abandoned parse keeps its parse slot until it finishes, so further
+// parses wait for one once every CPU is busy with them.
+func parseFile(fset *token.FileSet, filename string, src []byte, mode parser.Mode, settings Settings) (*ast.File, error) {
+	if settings.parseTimeout <= 0 {
+		node, err := parser.ParseFile(fset, filename, src, mode)
+		return node, withoutMisplacedImportErrors(err)
+	}
+
+	type result struct {
+		node *ast.File
+		err  error
+	}
+	// FileSets are safe for concurrent use, an abandoned parse only adds an unused file to fset
+	done := make(chan result, 1)
+	parseSlots <- struct{}{}
+	go func() {
+		defer func() { <-parseSlots }()
+		node, err := parser.ParseFile(fset, filename, src, mode)
+		done <- result{node, err}
+	}()
+
+	timer := time.NewTimer(settings.parseTimeout)
+	defer timer.Stop()
+	select {
+	case parsed := <-done:
+		return parsed.node, withoutMisplacedImportErrors(parsed.err)
+	case <-timer.C:
+		return nil, errParseTimeout
+	}
+}
@@ -0,0 +1,59 @@
+package gogroupimports
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// RuleLineDirective is reported for files the fixer leaves alone because of //line directives,
+// as a warning since the directive itself is no problem
+const RuleLineDirective = "line-directive"
+
+// lineDirectiveDiagnostic reports the line directive in the imports of node, if there is one
+func lineDirectiveDiagnostic(fset *token.FileSet, node *ast.File) (Diagnostic, bool) {
+	directive, ok := lineDirectiveInImports(fset, node)
+	if !ok {
+		return Diagnostic{}, false
+	}
+	diagnostic := newDiagnostic(fset, directive.Pos(), RuleLineDirective,
+		"not rewriting imports because of the line directive %q before the end of the imports", directive.Text)
+	diagnostic.Severity = SeverityWarning
+	return diagnostic, true
+}
+
+// lineDirectiveInImports returns the first //line or /*line directive placed before the end of
+// the last import declaration. Moving import lines around such a directive would change which
+// original lines the code after it maps to.
+func lineDirectiveInImports(fset *token.FileSet, node *ast.File) (*ast.Comment, bool) {
+	var importsEnd token.Pos
+	for _, decl := range node.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+			importsEnd = genDecl.End()
+		}
+	}
+	if !importsEnd.IsValid() {
+		return nil, false
+	}
+
+	for _, group := range node.Comments {
+		if group.Pos() > importsEnd {
+			break
+		}
+		for _, comment := range group.List {
+			if isLineDirective(fset, comment) {
+				return comment, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// isLineDirective reports whether comment is a line directive. The // form only counts at the
+// start of a line.
+func isLineDirective(fset *token.FileSet, comment *ast.Comment) bool {
+	if strings.HasPrefix(comment.Text, "/*line ") {
+		return true
+	}
+	return strings.HasPrefix(comment.Text, "//line ") && fset.PositionFor(comment.Pos(), false).Column == 1
+}
@@ -0,0 +1,16 @@
+package gogroupimports
+
+import (
+	"io"
+	"log"
+)
+
+// debugLog receives details about cache usage. It discards everything unless replaced
+// through SetDebugLogger.
+var debugLog = log.New(io.Discard, "", 0)
+
+// SetDebugLogger sends details about cache hits and misses to logger. It must be called
+// before any file is checked.
+func SetDebugLogger(logger *log.Logger) {
+	debugLog = logger
+}
@@ -2,94 +2,354 @@ package gogroupimports
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"go/ast"
-	"go/build"
 	"go/parser"
 	"go/token"
-	"log"
-	"os"
-	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 type Settings struct {
 	SelfModule             string   `json:"selfModule"`
 	InternalPrivateDomains []string `json:"internalPrivateDomains"`
+	// DeprecatedImports maps a deprecated import path to its suggested replacements
+	DeprecatedImports map[string][]string `json:"deprecatedImports"`
+	// VanityImports maps a vanity import prefix such as "go.corp.dev" to the prefix it is hosted under,
+	// the longest prefix of an import deciding
+	VanityImports map[string]string `json:"vanityImports"`
+	// ResolveVanityImports looks up the go-import meta tag of third party paths to find where they are hosted
+	ResolveVanityImports bool `json:"resolveVanityImports"`
+	// UseGoList classifies imports by the module owning them according to `go list -m all`
+	UseGoList bool `json:"useGoList"`
+	// LayerRules restrict which packages may import which other packages
+	LayerRules []LayerRule `json:"layerRules"`
+	// TestOnlyImports lists modules and packages, like testify or gomock, that only _test.go files may import
+	TestOnlyImports []string `json:"testOnlyImports"`
+	// TestPackagePatterns match mock and testdata packages production code may not import, like
+	// "**/mocks", "**/mocks/**", "**/mock_*" and "**/testdata/**". The rule is off without them.
+	TestPackagePatterns []string `json:"testPackagePatterns"`
+	// SideEffectImports lists packages whose blank imports go into a trailing group of their own
+	SideEffectImports []string `json:"sideEffectImports"`
+	// SideEffectComment is placed above the side-effect group by the fixer, e.g. "drivers and profiling endpoints"
+	SideEffectComment string `json:"sideEffectComment"`
+	// GeneratedImports lists generated packages, like API clients and protobuf code, e.g.
+	// "git.corp.com/proto/gen/**". They go into a group of their own after the own module imports,
+	// wherever they are hosted, to keep them apart from hand-written code.
+	GeneratedImports []string `json:"generatedImports"`
+	// GroupByHost replaces the third party, internal private and own module groups with one group per hosting domain
+	GroupByHost bool `json:"groupByHost"`
+	// HostOrder orders the host groups of GroupByHost, hosts that aren't listed follow alphabetically
+	HostOrder []string `json:"hostOrder"`
+	// CaseInsensitiveSort sorts imports within a group ignoring case instead of byte-wise. Note that gofmt
+	// sorts consecutive imports byte-wise, so this only sticks when gofmt isn't run afterwards.
+	CaseInsensitiveSort bool `json:"caseInsensitiveSort"`
+	// SortOrder orders imports within a group: "lexical" (default), "length", "depth", "source" or a
+	// name registered with RegisterSortOrder. "source" keeps the order imports are written in, so
+	// fixes only move imports between groups and insert separators, ignoring SortPriority. Like
+	// CaseInsensitiveSort it only sticks when gofmt, which sorts every group, isn't run afterwards.
+	// Imports out of order within their group are reported by the import-order rule.
+	SortOrder string `json:"sortOrder"`
+	// SortPriority lists import prefixes that go first within their group, in the given order
+	SortPriority []string `json:"sortPriority"`
+	// GOROOT is the Go installation whose packages are the builtin ones. It defaults to the GOROOT
+	// the go command reports, which follows toolchain directives, see Toolchain.
+	GOROOT string `json:"goroot"`
+	// CacheDir stores module and stdlib lookups between runs, defaults to gogroupimports under os.UserCacheDir
+	CacheDir string `json:"cacheDir"`
+	// DisableCache turns off the persisted lookup cache
+	DisableCache bool `json:"disableCache"`
+	// IndexFile is an index written by Checker.WriteIndex to take the stdlib packages, module build
+	// lists and vanity roots from instead of probing the environment
+	IndexFile string `json:"indexFile"`
+	// MaxFileSize skips files larger than this many bytes, like huge generated files, with an
+	// informational diagnostic. Zero means no limit.
+	MaxFileSize int64 `json:"maxFileSize"`
+	// MaxImports skips files with more imports than this the same way. Zero means no limit.
+	MaxImports int `json:"maxImports"`
+	// ParseTimeout skips files taking longer than this to parse the same way, so corrupted or
+	// malicious files can't stall a run. It is a duration like "2s", empty means no limit. It
+	// bounds how long a file is waited for; abandoned parses still run to completion, at most one
+	// per CPU at a time.
+	ParseTimeout string `json:"parseTimeout"`
+	// FactorImports requires all imports in a single parenthesized block instead of several import
+	// declarations, fixes merge them. import "C" stays separate.
+	FactorImports bool `json:"factorImports"`
+	// PackageImportSpacing requires exactly one blank line between the package clause and the
+	// imports, fixes add or remove blank lines. Comments between them leave the spacing alone.
+	PackageImportSpacing bool `json:"packageImportSpacing"`
+	// RequireMajorVersionAliases requires imports of major version paths like github.com/go-chi/chi/v5
+	// to carry an alias ending in the version, like chiv5, since the package name hides the version
+	RequireMajorVersionAliases bool `json:"requireMajorVersionAliases"`
+	// MajorVersionAliases maps major version import paths to an alias accepted for them as well,
+	// e.g. {"github.com/go-chi/chi/v5": "chi"} for the one version a repository uses
+	MajorVersionAliases map[string]string `json:"majorVersionAliases"`
+	// BanUnnecessaryAliases reports aliases repeating the name of the package and aliases renaming
+	// it although nothing else in the file has its name, fixes drop them and rename the references.
+	// Packages are loaded to learn the names they declare, those that can't be loaded are left alone.
+	BanUnnecessaryAliases bool `json:"banUnnecessaryAliases"`
+	// AliasScheme names the packages fixes add, like the replacements of deprecated imports, when
+	// their name is taken in the file: "parent" (default) prefixes the element before the name, like
+	// pkgerrors, "number" numbers it, like errors2. References are rewritten to the alias.
+	AliasScheme string `json:"aliasScheme"`
+	// ToolsFiles sets how files pinning tool dependencies are handled, those built only with the
+	// tools tag whose imports are all blank: "skip" leaves them alone, "single-group" requires one
+	// sorted group whatever the types of the imports, whose order is checked by the import-order
+	// rule. By default they are checked like other files.
+	ToolsFiles string `json:"toolsFiles"`
+	// IncludeIgnoredFiles checks files guarded by //go:build ignore, like generator drivers and
+	// scripts run with go run, which are skipped by default
+	IncludeIgnoredFiles bool `json:"includeIgnoredFiles"`
+	// MergeInternalAndOwnModule puts internal private and own module imports into one group, for
+	// organizations treating everything on their domain as one section
+	MergeInternalAndOwnModule bool `json:"mergeInternalAndOwnModule"`
+	// Severities sets the severity of rules by name, "error" (default), "warning" or "info". Only
+	// errors fail a file unless FailOn says otherwise, e.g. {"deprecated": "warning"} reports deprecated imports without failing.
+	Severities map[string]string `json:"severities"`
+	// FailOn lists the severities that fail a file, defaults to ["error"]. Reporting stays the same,
+	// e.g. ["error", "warning"] in a CI config gates on warnings that only advise locally.
+	FailOn []string `json:"failOn"`
+
+	modules      *moduleIndex           // Modules of the build list, loaded when UseGoList is set
+	cache        *diskCache             // Persisted lookups, nil when disabled
+	stdlib       map[string]bool        // Standard library packages, loaded by NewChecker
+	less         func(a, b string) bool // SortOrder, resolved by NewChecker
+	parseTimeout time.Duration          // ParseTimeout, resolved by NewChecker
+	packageNames *packageNames          // Names of imported packages, for BanUnnecessaryAliases
+
+	overrides   []groupOverride // Group directives of the file being checked
+	singleGroup bool            // Every import goes into one group, for tools files
+
+	templateMarker string // Marker of the placeholders of template actions in the file being checked
 }
 
+// Run checks filename against the settings in metaData and reports its problems as a
+// Diagnostics error.
+//
+// Deprecated: Run compares metaData with the copy kept for every file to find the Checker set
+// up for it, and sets up a new one every 30 seconds. Create a Checker once with
+// NewChecker and use Checker.Check instead.
 func Run(filename string, metaData map[string]interface{}) ([]byte, error) {
-	marshal, err := json.Marshal(metaData)
+	checker, err := newCheckerFromMap(metaData)
 	if err != nil {
 		return nil, err
 	}
-	var settings Settings
-	err = json.Unmarshal(marshal, &settings)
+	diagnostics, err := checker.Check(filename)
 	if err != nil {
 		return nil, err
 	}
-	fset := token.NewFileSet()
+	return nil, diagnosticsError(diagnostics)
+}
 
-	// Parse the source file
-	node, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+// Fix rewrites the imports of filename according to metaData and returns the updated source.
+// Problems that cannot be fixed automatically are reported in the returned error
+// alongside the partially fixed source.
+//
+// Deprecated: Fix compares metaData with the copy kept for every file to find the Checker set
+// up for it, like Run. Create a Checker once with NewChecker and use Checker.Fix instead.
+func Fix(filename string, metaData map[string]interface{}) ([]byte, error) {
+	checker, err := newCheckerFromMap(metaData)
 	if err != nil {
-		log.Fatalf("Failed to parse file: %v", err)
+		return nil, err
 	}
-
-	importGroups, err := getImportGroups(fset, node, settings)
+	fixed, diagnostics, err := checker.Fix(filename)
 	if err != nil {
-		log.Fatalf("Error getting import groups: %v", err)
+		return nil, err
+	}
+	return fixed, diagnosticsError(diagnostics)
+}
+
+// fixPass computes the edits for one kind of fix
+type fixPass func(fset *token.FileSet, node *ast.File, src []byte, settings Settings) ([]textEdit, []Diagnostic)
+
+// fixSource runs the passes in order, parsing the output of the previous pass each time so
+// that every pass sees accurate positions
+func fixSource(filename string, src []byte, settings Settings, passes ...fixPass) ([]byte, []Diagnostic, error) {
+	var diagnostics []Diagnostic
+	var node *ast.File
+	for _, pass := range passes {
+		fset := getFileSet()
+		var err error
+		node, err = parseFile(fset, filename, src, parser.ParseComments, settings)
+		if errors.Is(err, errParseTimeout) {
+			return src, []Diagnostic{parseTimedOut(filename, settings)}, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse file: %w", err)
+		}
+
+		if directive, ok := lineDirectiveDiagnostic(fset, node); ok {
+			diagnostics = append(diagnostics, directive)
+			return src, withVariant(applySeverities(diagnostics, settings), filename, node), nil
+		}
+
+		// Malformed directives are reported by Check
+		fileSettings, _ := withFileDirectives(fset, node, settings)
+		fileSettings, skip := fileProfile(node, fileSettings)
+		if skip {
+			putFileSet(fset)
+			return src, nil, nil
+		}
+		edits, passDiagnostics := pass(fset, node, src, fileSettings)
+		src = applyEdits(src, edits)
+		diagnostics = append(diagnostics, passDiagnostics...)
+		putFileSet(fset)
+	}
+	diagnostics = withVariant(applySeverities(diagnostics, settings), filename, node)
+	SortDiagnostics(diagnostics)
+	return src, diagnostics, nil
+}
+
+// runCheckers caches the Checkers of Run and Fix by the identity of the metadata map. Linters
+// call them for every file with the same map, which then only sets up one Checker.
+var runCheckers struct {
+	sync.Mutex
+	byMetaData map[uintptr]runChecker
+}
+
+// runChecker is a cached Checker of Run and Fix with a copy of the metadata it was created
+// from, to tell whether the map changed since
+type runChecker struct {
+	checker  *Checker
+	metaData map[string]interface{}
+	created  time.Time
+}
+
+// maxRunCheckers bounds runCheckers for callers passing different metadata every time
+const maxRunCheckers = 16
+
+// runCheckerLifetime bounds how long Run and Fix use a cached Checker, which looks up the
+// modules of go.mod files and reads IndexFile once, so that long running callers see changes
+// to them
+const runCheckerLifetime = 30 * time.Second
+
+// newCheckerFromMap creates a Checker from the plugin metadata, or returns the one created
+// for the same map before when its contents are unchanged and it isn't older than
+// runCheckerLifetime
+func newCheckerFromMap(metaData map[string]interface{}) (*Checker, error) {
+	id := reflect.ValueOf(metaData).Pointer()
+	runCheckers.Lock()
+	defer runCheckers.Unlock()
+	if cached, ok := runCheckers.byMetaData[id]; ok && time.Since(cached.created) < runCheckerLifetime && reflect.DeepEqual(cached.metaData, metaData) {
+		return cached.checker, nil
 	}
 
-	// Check if imports are properly grouped and have line breaks between groups
-	if !areImportsGrouped(importGroups) {
-		return nil, fmt.Errorf("Warning: Imports are not properly grouped in file %s\n.", filename)
+	settings, err := ParseSettings(metaData)
+	if err != nil {
+		return nil, err
+	}
+	checker, err := NewChecker(settings)
+	if err != nil {
+		return nil, err
+	}
+	if runCheckers.byMetaData == nil || len(runCheckers.byMetaData) >= maxRunCheckers {
+		runCheckers.byMetaData = make(map[uintptr]runChecker)
 	}
+	clone, _ := cloneValue(reflect.ValueOf(metaData)).Interface().(map[string]interface{})
+	runCheckers.byMetaData[id] = runChecker{checker: checker, metaData: clone, created: time.Now()}
+	return checker, nil
+}
 
-	// Check for line breaks between import groups
-	for i, group := range importGroups {
-		if i > 0 && group.startLine != importGroups[i-1].endLine+2 {
-			return nil, fmt.Errorf("Warning: Missing single line break before %d\n", group.startLine)
+// cloneValue returns a copy of value sharing none of its maps and slices with it
+func cloneValue(value reflect.Value) reflect.Value {
+	switch value.Kind() {
+	case reflect.Map:
+		if value.IsNil() {
+			return value
 		}
+		clone := reflect.MakeMapWithSize(value.Type(), value.Len())
+		for iter := value.MapRange(); iter.Next(); {
+			clone.SetMapIndex(iter.Key(), cloneValue(iter.Value()))
+		}
+		return clone
+	case reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+		clone := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			clone.Index(i).Set(cloneValue(value.Index(i)))
+		}
+		return clone
+	case reflect.Interface:
+		if value.IsNil() {
+			return value
+		}
+		clone := reflect.New(value.Type()).Elem()
+		clone.Set(cloneValue(value.Elem()))
+		return clone
 	}
+	return value
+}
 
-	return nil, err
+// ParseSettings decodes settings given as a generic map, like linter plugin metadata or a
+// decoded config file, using the json names of the Settings fields
+func ParseSettings(metaData map[string]interface{}) (Settings, error) {
+	var settings Settings
+	marshal, err := json.Marshal(metaData)
+	if err != nil {
+		return settings, err
+	}
+	err = json.Unmarshal(marshal, &settings)
+	return settings, err
 }
 
-// ImportGroup represents a group of consecutive import declarations
+// ImportGroup represents a group of consecutive import declarations of one type, not
+// interrupted by blank lines
 type ImportGroup struct {
-	startLine  int    // Start line of the group
-	endLine    int    // End line of the group
-	importType string // Type of import: "builtin", "public_open_source", "internal_private_or_own_module"
+	pos        token.Pos // Position of the first import of the group
+	startLine  int       // Start line of the group
+	endLine    int       // End line of the group
+	importType string    // Group type, see groupTypeOf: one of expectedSequence, a host or toolsGroup
+	block      int       // Index of the import declaration holding the group
 }
 
-// getImportGroups extracts import groups from the AST
+// getImportGroups extracts import groups from the AST. A group ends where the import type
+// changes, where a blank line separates two imports or with its import declaration, so that
+// imports of one type split into several runs show up as several groups. import "C" belongs
+// to no group, it stays apart with the cgo preamble.
 func getImportGroups(fset *token.FileSet, node *ast.File, settings Settings) ([]ImportGroup, error) {
 	var groups []ImportGroup
 	var currentGroup *ImportGroup
 
+	block := -1
 	for _, decl := range node.Decls {
 		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+			block++
 			for _, spec := range genDecl.Specs {
 				importSpec := spec.(*ast.ImportSpec)
-				importPath := importSpec.Path.Value[1 : len(importSpec.Path.Value)-1] // Remove quotes
-
+				if importPathOf(importSpec) == "C" {
+					continue
+				}
 				// Determine the type of import and group accordingly
-				importType := getImportType(importPath, settings)
+				importType := groupTypeOf(getSpecType(importSpec, settings), settings)
+
+				// A comment introducing the import belongs to it
+				start := importSpec.Pos()
+				if importSpec.Doc != nil {
+					start = importSpec.Doc.Pos()
+				}
+				startLine := lineOf(fset, start)
 
 				// Start a new group if necessary
-				if currentGroup == nil || currentGroup.importType != importType {
+				if currentGroup == nil || currentGroup.importType != importType || startLine > currentGroup.endLine+1 || currentGroup.block != block {
 					if currentGroup != nil {
 						groups = append(groups, *currentGroup)
 					}
 					currentGroup = &ImportGroup{
-						startLine:  fset.Position(importSpec.Pos()).Line,
-						endLine:    fset.Position(importSpec.End()).Line,
+						pos:        importSpec.Pos(),
+						startLine:  startLine,
+						endLine:    lineOf(fset, importSpec.End()),
 						importType: importType,
+						block:      block,
 					}
 				} else {
 					// Update the end line of the current group
-					currentGroup.endLine = fset.Position(importSpec.End()).Line
+					currentGroup.endLine = lineOf(fset, importSpec.End())
 				}
 			}
 		}
@@ -104,33 +364,142 @@ func getImportGroups(fset *token.FileSet, node *ast.File, settings Settings) ([]
 
 // getImportType determines the type of import
 func getImportType(path string, settings Settings) string {
+	if importType, ok := overriddenType(path, settings); ok {
+		return importType
+	}
+	if isGeneratedImport(path, settings) {
+		return "generated"
+	}
+	if module, ok := settings.modules.lookup(path); ok && !settings.GroupByHost {
+		return classifyModule(module, settings)
+	}
+
+	importType := classifyPath(path, settings)
+	if settings.GroupByHost && importType != "builtin" {
+		return hostOf(path)
+	}
+	if importType == "public_open_source_or_third_party" {
+		// Vanity paths follow the classification of the place they are hosted at
+		if hosted, ok := resolveVanityImport(path, settings); ok {
+			importType = classifyPath(hosted, settings)
+		}
+	}
+	return importType
+}
+
+// classifyPath determines the type of import from the import path alone
+func classifyPath(path string, settings Settings) string {
 	if isInternalPrivateImport(path, settings) {
 		return "internal_private"
-	} else if strings.HasPrefix(path, settings.SelfModule) {
+	} else if settings.SelfModule != "" && strings.HasPrefix(path, settings.SelfModule) {
 		return "own_module"
-	} else if isBuiltinImport(path) {
+	} else if isBuiltinImport(path, settings) {
 		return "builtin"
 	} else {
 		return "public_open_source_or_third_party"
 	}
 }
 
+// expectedSequence is the correct sequence of import types
+var expectedSequence = []string{"builtin", "public_open_source_or_third_party", "internal_private", "own_module", "generated", "side_effect"}
+
+// groupTypeOf returns the group imports of importType go into, which is the type itself unless
+// MergeInternalAndOwnModule puts own module imports into the internal private group, or the file
+// is a tools file held to a single group
+func groupTypeOf(importType string, settings Settings) string {
+	if settings.singleGroup {
+		return toolsGroup
+	}
+	if settings.MergeInternalAndOwnModule && importType == "own_module" {
+		return "internal_private"
+	}
+	return importType
+}
+
 // areImportsGrouped checks if imports are properly grouped
-func areImportsGrouped(groups []ImportGroup) bool {
-	// Define the correct sequence of import types
-	expectedSequence := []string{"builtin", "public_open_source_or_third_party", "internal_private", "own_module"}
+func areImportsGrouped(groups []ImportGroup, settings Settings) bool {
+	return firstMisplacedGroup(groups, settings) < 0
+}
 
-	// Check if the actual import sequence matches the expected sequence
-	for i, group := range groups {
-		if i < len(expectedSequence) && group.importType != expectedSequence[i] {
-			return false
+// firstMisplacedGroup returns the index of the first group breaking the expected sequence within
+// its import declaration, or -1. Every import type may appear once and types must follow the
+// expected sequence, but a file doesn't have to use all of them. Consecutive groups of the same
+// type are split by blank lines rather than misplaced, isSplitGroup reports those.
+// checkDeclarationOrder compares the groups of different declarations.
+func firstMisplacedGroup(groups []ImportGroup, settings Settings) int {
+	for i := 1; i < len(groups); i++ {
+		if groups[i-1].block == groups[i].block && compareImportTypes(groups[i-1].importType, groups[i].importType, settings) > 0 {
+			return i
 		}
 	}
-	return true
+	return -1
+}
+
+// isSplitGroup reports whether group i continues the imports of the group before it after
+// a blank line within the same import declaration
+func isSplitGroup(groups []ImportGroup, i int, settings Settings) bool {
+	return i > 0 && groups[i-1].block == groups[i].block && compareImportTypes(groups[i-1].importType, groups[i].importType, settings) == 0
+}
+
+// compareImportTypes orders import types by the expected sequence. In the auto sections mode
+// the host groups come right after builtin, in the configured host order and then by name.
+func compareImportTypes(a, b string, settings Settings) int {
+	rankA, rankB := importTypeRank(a, settings), importTypeRank(b, settings)
+	if rankA != rankB {
+		return rankA - rankB
+	}
+	if isHostType(a) && isHostType(b) {
+		return strings.Compare(a, b)
+	}
+	return 0
+}
+
+// importTypeRank returns the position of importType in the expected sequence
+func importTypeRank(importType string, settings Settings) int {
+	if isHostType(importType) {
+		return 1 + hostRank(importType, settings)
+	}
+
+	hostSlots := 0
+	if settings.GroupByHost {
+		hostSlots = len(settings.HostOrder) + 1
+	}
+	for i, expected := range expectedSequence {
+		if expected == importType {
+			if i == 0 {
+				return 0
+			}
+			return i + hostSlots
+		}
+	}
+	return len(expectedSequence) + hostSlots
 }
 
 // Helper functions to check import types
 
+// importPathOf returns the import path of spec. Escapes and raw strings are interpreted the way
+// the compiler does, malformed literals, which checkImportPaths reports, give an empty path.
+func importPathOf(spec *ast.ImportSpec) string {
+	path, err := strconv.Unquote(spec.Path.Value)
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// checkImportPaths reports import path literals that aren't valid strings. The parser rejects
+// most of them already, but ASTs built or modified by other tools may still carry them.
+func checkImportPaths(fset *token.FileSet, node *ast.File) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, importSpec := range node.Imports {
+		if _, err := strconv.Unquote(importSpec.Path.Value); err != nil {
+			diagnostics = append(diagnostics, newDiagnostic(fset, importSpec.Pos(), RuleImportPath,
+				"invalid import path %s", importSpec.Path.Value))
+		}
+	}
+	return diagnostics
+}
+
 func isInternalPrivateImport(path string, settings Settings) bool {
 	for _, domain := range settings.InternalPrivateDomains {
 		if strings.Contains(path, domain) {
@@ -140,8 +509,19 @@ func isInternalPrivateImport(path string, settings Settings) bool {
 	return false
 }
 
-func isBuiltinImport(path string) bool {
+func isGeneratedImport(path string, settings Settings) bool {
+	for _, generated := range settings.GeneratedImports {
+		if matchesImport(generated, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func isBuiltinImport(path string, settings Settings) bool {
 	// Check if the import path belongs to a built-in package
-	_, err := os.Stat(filepath.Join(build.Default.GOROOT, "src", path))
-	return err == nil
+	if settings.stdlib != nil {
+		return inStdlib(settings.stdlib, path)
+	}
+	return isStdlibPath(path, settings)
 }
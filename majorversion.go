@@ -0,0 +1,68 @@
+package gogroupimports
+
+import (
+	"cmp"
+	"go/ast"
+	"go/token"
+	"path"
+	"strings"
+)
+
+// RuleMajorVersionAlias is reported when RequireMajorVersionAliases is set for imports of
+// major version suffixed paths, like github.com/go-chi/chi/v5, without an alias naming the version
+const RuleMajorVersionAlias = "major-version-alias"
+
+// majorVersionOf returns the major version suffix of path, like "v5", or "" when its last
+// element isn't one. Major versions 0 and 1 have no suffix.
+func majorVersionOf(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		return ""
+	}
+	version := path[i+1:]
+	if !isMajorVersion(version) || version[1] == '0' || version == "v1" {
+		return ""
+	}
+	return version
+}
+
+// suggestedVersionAlias returns an alias for the major version path, the name of the element
+// before the version followed by the version, like chiv5
+func suggestedVersionAlias(importPath, version string) string {
+	return cmp.Or(identifierOf(path.Base(strings.TrimSuffix(importPath, "/"+version))), "pkg") + version
+}
+
+// checkMajorVersionAliases reports imports of major version paths whose alias doesn't end in
+// the version and isn't the one configured in MajorVersionAliases. Blank and dot imports don't
+// name the package and standard library packages like math/rand/v2 are known by their name,
+// both are left alone.
+func checkMajorVersionAliases(fset *token.FileSet, node *ast.File, settings Settings) []Diagnostic {
+	if !settings.RequireMajorVersionAliases {
+		return nil
+	}
+	var diagnostics []Diagnostic
+	for _, importSpec := range node.Imports {
+		importPath := importPathOf(importSpec)
+		version := majorVersionOf(importPath)
+		if version == "" || isBuiltinImport(importPath, settings) {
+			continue
+		}
+		alias := ""
+		if importSpec.Name != nil {
+			alias = importSpec.Name.Name
+		}
+		if alias == "_" || alias == "." || strings.HasSuffix(alias, version) {
+			continue
+		}
+		configured, ok := settings.MajorVersionAliases[importPath]
+		if ok && alias == configured {
+			continue
+		}
+		if !ok {
+			configured = suggestedVersionAlias(importPath, version)
+		}
+		diagnostics = append(diagnostics, newDiagnostic(fset, importSpec.Pos(), RuleMajorVersionAlias,
+			"import of %s needs an alias naming its major version, like %s %q", importPath, configured, importPath))
+	}
+	return diagnostics
+}
@@ -0,0 +1,114 @@
+package gogroupimports
+
+import (
+	"errors"
+	"go/ast"
+	"go/scanner"
+	"go/token"
+	"strings"
+)
+
+// RuleMisplacedImport is reported for import declarations following other declarations, which
+// bad merges leave behind and the compiler rejects
+const RuleMisplacedImport = "misplaced-import"
+
+// misplacedImportError is the message of the parser for import declarations following other
+// declarations. The parser still adds them to the file, so they can be checked and moved.
+const misplacedImportError = "imports must appear before other declarations"
+
+// withoutMisplacedImportErrors returns err without the errors of misplaced imports, nil when
+// there were no others, so that files whose only problem is the order of their declarations
+// are checked and fixed
+func withoutMisplacedImportErrors(err error) error {
+	var list scanner.ErrorList
+	if !errors.As(err, &list) {
+		return err
+	}
+	var others scanner.ErrorList
+	for _, e := range list {
+		if e.Msg != misplacedImportError {
+			others = append(others, e)
+		}
+	}
+	return others.Err()
+}
+
+// misplacedImports returns the import declarations of node following other declarations, and
+// the last import declaration before them, nil when there is none
+func misplacedImports(node *ast.File) (misplaced []*ast.GenDecl, last *ast.GenDecl) {
+	leading := true
+	for _, decl := range node.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		isImport := ok && genDecl.Tok == token.IMPORT
+		switch {
+		case isImport && leading:
+			last = genDecl
+		case isImport:
+			misplaced = append(misplaced, genDecl)
+		default:
+			leading = false
+		}
+	}
+	return misplaced, last
+}
+
+// checkMisplacedImports reports every import declaration following other declarations
+func checkMisplacedImports(fset *token.FileSet, node *ast.File) []Diagnostic {
+	misplaced, _ := misplacedImports(node)
+	var diagnostics []Diagnostic
+	for _, genDecl := range misplaced {
+		diagnostics = append(diagnostics, newDiagnostic(fset, genDecl.Pos(), RuleMisplacedImport,
+			"imports must come directly after the package clause, before other declarations"))
+	}
+	return diagnostics
+}
+
+// fixMisplacedImports moves the misplaced import declarations, with their doc comments, after
+// the last import declaration in place, or after the package clause when there is none. The
+// passes following it sort the moved imports into their groups.
+func fixMisplacedImports(fset *token.FileSet, node *ast.File, src []byte, settings Settings) ([]textEdit, []Diagnostic) {
+	misplaced, last := misplacedImports(node)
+	if len(misplaced) == 0 {
+		return nil, nil
+	}
+
+	var moved strings.Builder
+	var edits []textEdit
+	for _, genDecl := range misplaced {
+		start, end := genDecl.Pos(), genDecl.End()
+		if genDecl.Doc != nil {
+			start = genDecl.Doc.Pos()
+		}
+		if spec := genDecl.Specs; !genDecl.Lparen.IsValid() && len(spec) == 1 && spec[0].(*ast.ImportSpec).Comment != nil {
+			end = spec[0].(*ast.ImportSpec).Comment.End()
+		}
+		startOffset, endOffset := offsetOf(fset, start), offsetOf(fset, end)
+		if !ownsLines(src, startOffset, endOffset) {
+			return nil, []Diagnostic{newDiagnostic(fset, genDecl.Pos(), RuleMisplacedImport,
+				"cannot move imports automatically: every import declaration must be on lines of its own")}
+		}
+
+		edit := textEdit{start: lineStart(src, startOffset), end: nextLineStart(src, endOffset)}
+		moved.WriteString("\n")
+		moved.Write(src[edit.start:edit.end])
+		if !strings.HasSuffix(moved.String(), "\n") {
+			moved.WriteString("\n")
+		}
+		// Blank lines before a moved declaration go with it
+		for edit.start > 0 {
+			previous := lineStart(src, edit.start-1)
+			if strings.TrimSpace(string(src[previous:edit.start])) != "" {
+				break
+			}
+			edit.start = previous
+		}
+		edits = append(edits, edit)
+	}
+
+	after := node.Name.End()
+	if last != nil {
+		after = last.End()
+	}
+	insert := nextLineStart(src, offsetOf(fset, after))
+	return append(edits, textEdit{start: insert, end: insert, text: moved.String()}), nil
+}
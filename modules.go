@@ -0,0 +1,220 @@
+package gogroupimports
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// listedModule is a module of the build list as reported by `go list -m -json`
+type listedModule struct {
+	Path string
+	Main bool
+	Dir  string
+}
+
+// moduleIndex finds the module owning an import path
+type moduleIndex struct {
+	root    string         // Directory of the go.mod the index was built for
+	modules []listedModule // Sorted by descending path length so the innermost module matches first
+}
+
+// moduleIndexCache keeps the index of every module root a Checker has seen
+type moduleIndexCache struct {
+	sync.Mutex
+	indexes map[string]*moduleIndex
+	loading singleflight.Group // Concurrent loads of the same root share one go list run
+}
+
+// load returns the build list of the module containing filename. Build lists are persisted in
+// cache keyed by the module's go.mod, go.sum and go.work and the Go version, the lookups are
+// traced as "cache" spans in the span of ctx.
+func (indexes *moduleIndexCache) load(ctx context.Context, filename string, cache *diskCache) (*moduleIndex, error) {
+	root, err := findModuleRoot(filepath.Dir(filename))
+	if err != nil {
+		return nil, err
+	}
+
+	indexes.Lock()
+	index, ok := indexes.indexes[root]
+	indexes.Unlock()
+	if ok {
+		debugLog.Printf("module index of %s already loaded", root)
+		return index, nil
+	}
+
+	loaded, err, shared := indexes.loading.Do(root, func() (interface{}, error) {
+		key, err := moduleCacheKey(root)
+		if err != nil {
+			return nil, err
+		}
+		var modules []listedModule
+		_, endCache := startSpan(ctx, "cache", filename)
+		cached := cache.get(key, &modules)
+		endCache()
+		if !cached {
+			modules, err = goListModules(root)
+			if err != nil {
+				return nil, err
+			}
+			_, endCache = startSpan(ctx, "cache", filename)
+			cache.put(key, modules)
+			endCache()
+		}
+		index := newModuleIndex(root, modules)
+
+		indexes.Lock()
+		defer indexes.Unlock()
+		if indexes.indexes == nil {
+			indexes.indexes = make(map[string]*moduleIndex)
+		}
+		indexes.indexes[root] = index
+		return index, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if shared {
+		debugLog.Printf("module index of %s loaded by a concurrent check", root)
+	}
+	return loaded.(*moduleIndex), nil
+}
+
+// newModuleIndex builds an index for the modules listed in root
+func newModuleIndex(root string, modules []listedModule) *moduleIndex {
+	sort.SliceStable(modules, func(i, j int) bool {
+		return len(modules[i].Path) > len(modules[j].Path)
+	})
+	return &moduleIndex{root: root, modules: modules}
+}
+
+// moduleCacheKey identifies the build list of the module in root, which also depends on the
+// version of the go command and on the go.work the go command uses for root
+func moduleCacheKey(root string) (string, error) {
+	goMod, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return "", err
+	}
+	goSum, err := os.ReadFile(filepath.Join(root, "go.sum"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	var goWork []byte
+	workFile := findGoWork(root)
+	if workFile != "" {
+		goWork, err = os.ReadFile(workFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+	_, version := Toolchain()
+	return cacheKey([]byte("modules"), []byte(version), []byte(workFile), []byte(root), goMod, goSum, goWork), nil
+}
+
+// findGoWork returns the go.work the go command uses in dir: the one GOWORK names, or the
+// nearest one in dir and its parents. It returns "" when workspaces are off or there is none.
+func findGoWork(dir string) string {
+	switch gowork := os.Getenv("GOWORK"); gowork {
+	case "off":
+		return ""
+	case "":
+	default:
+		return gowork
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.work")); err == nil {
+			return filepath.Join(dir, "go.work")
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// goListModules runs `go list -m -json all` in dir
+func goListModules(dir string) ([]listedModule, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("go", "list", "-m", "-json", "all")
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("go list -m all: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var modules []listedModule
+	decoder := json.NewDecoder(&stdout)
+	for {
+		var module listedModule
+		if err := decoder.Decode(&module); err == io.EOF {
+			return modules, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("go list -m all: %w", err)
+		}
+		modules = append(modules, module)
+	}
+}
+
+// findModuleRoot returns the directory of the go.mod governing dir
+func findModuleRoot(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.New("no go.mod found")
+		}
+		dir = parent
+	}
+}
+
+// lookup returns the module owning importPath. It is safe to call on a nil index.
+func (index *moduleIndex) lookup(importPath string) (listedModule, bool) {
+	if index == nil {
+		return listedModule{}, false
+	}
+	for _, module := range index.modules {
+		if importPath == module.Path || strings.HasPrefix(importPath, module.Path+"/") {
+			return module, true
+		}
+	}
+	return listedModule{}, false
+}
+
+// isOwn reports whether module is the one the index was built for. In workspace mode every
+// workspace module is a main module, but only the one containing the file is its own module.
+func (index *moduleIndex) isOwn(module listedModule) bool {
+	return module.Main && filepath.Clean(module.Dir) == index.root
+}
+
+// classifyModule determines the type of an import owned by module
+func classifyModule(module listedModule, settings Settings) string {
+	if settings.modules.isOwn(module) {
+		return "own_module"
+	}
+	if isInternalPrivateImport(module.Path, settings) {
+		return "internal_private"
+	}
+	if hosted, ok := resolveVanityImport(module.Path, settings); ok && isInternalPrivateImport(hosted, settings) {
+		return "internal_private"
+	}
+	return "public_open_source_or_third_party"
+}
@@ -0,0 +1,44 @@
+package gogroupimports
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// RuleImportOrder is reported for imports out of order within their group, which Fix sorts
+// by SortPriority and SortOrder
+const RuleImportOrder = "import-order"
+
+// checkImportOrder reports every import of a parenthesized block that sorts before the import
+// above it in the same group, see lessImportPath. Groups are split like getImportGroups does.
+func checkImportOrder(fset *token.FileSet, node *ast.File, settings Settings) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, decl := range node.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT || !genDecl.Lparen.IsValid() {
+			continue
+		}
+		var previous *ast.ImportSpec
+		previousType, previousEnd := "", 0
+		for _, spec := range genDecl.Specs {
+			importSpec := spec.(*ast.ImportSpec)
+			path := importPathOf(importSpec)
+			if path == "C" {
+				previous = nil
+				continue
+			}
+			importType := groupTypeOf(getSpecType(importSpec, settings), settings)
+			start := importSpec.Pos()
+			if importSpec.Doc != nil {
+				start = importSpec.Doc.Pos()
+			}
+			sameGroup := previous != nil && importType == previousType && lineOf(fset, start) == previousEnd+1
+			if sameGroup && lessImportPath(path, importPathOf(previous), settings) {
+				diagnostics = append(diagnostics, newDiagnostic(fset, importSpec.Pos(), RuleImportOrder,
+					"import %q should come before %q in the %s imports", path, importPathOf(previous), importType))
+			}
+			previous, previousType, previousEnd = importSpec, importType, lineOf(fset, importSpec.End())
+		}
+	}
+	return diagnostics
+}
@@ -0,0 +1,80 @@
+package gogroupimports
+
+import (
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// localNameOf returns the identifier importSpec is referenced by in its file
+func localNameOf(importSpec *ast.ImportSpec) string {
+	if importSpec.Name != nil {
+		return importSpec.Name.Name
+	}
+	return defaultPackageName(importPathOf(importSpec))
+}
+
+// defaultPackageName guesses a package name from its import path the same way goimports does,
+// skipping major version suffixes
+func defaultPackageName(importPath string) string {
+	name := path.Base(importPath)
+	if isMajorVersion(name) {
+		name = path.Base(path.Dir(importPath))
+	}
+	if i := strings.Index(name, ".v"); i > 0 && isMajorVersion(name[i+1:]) {
+		name = name[:i] // gopkg.in/yaml.v3
+	}
+	name = strings.TrimPrefix(name, "go-")
+	return strings.NewReplacer("-", "_", ".", "_").Replace(name)
+}
+
+// isMajorVersion reports whether element is a major version suffix such as v2
+func isMajorVersion(element string) bool {
+	return len(element) > 1 && element[0] == 'v' && strings.Trim(element[1:], "0123456789") == ""
+}
+
+// loadPackageExports returns the package name and exported top-level identifiers of the package
+// imported as importPath from srcDir, taking the files ctxt builds
+func loadPackageExports(ctxt *build.Context, importPath string, srcDir string) (string, map[string]bool, error) {
+	pkg, err := ctxt.Import(importPath, srcDir, 0)
+	if err != nil {
+		return "", nil, err
+	}
+
+	fset := token.NewFileSet()
+	exports := make(map[string]bool)
+	for _, name := range pkg.GoFiles {
+		file, err := parser.ParseFile(fset, filepath.Join(pkg.Dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return "", nil, err
+		}
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv == nil && decl.Name.IsExported() {
+					exports[decl.Name.Name] = true
+				}
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						if spec.Name.IsExported() {
+							exports[spec.Name.Name] = true
+						}
+					case *ast.ValueSpec:
+						for _, name := range spec.Names {
+							if name.IsExported() {
+								exports[name.Name] = true
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return pkg.Name, exports, nil
+}
@@ -0,0 +1,56 @@
+package gogroupimports
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// RuleBlockPadding is reported for blank lines directly after the opening or before the closing
+// parenthesis of an import block, which the checks between groups don't see
+const RuleBlockPadding = "block-padding"
+
+// checkBlockPadding reports import blocks starting or ending with blank lines. Lines holding
+// only comments count as content. fixImportGroups removes the blank lines.
+func checkBlockPadding(fset *token.FileSet, node *ast.File) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, decl := range node.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT || !genDecl.Lparen.IsValid() || len(genDecl.Specs) == 0 {
+			continue
+		}
+
+		first, last := blockContentLines(fset, node, genDecl)
+		lparenLine, rparenLine := lineOf(fset, genDecl.Lparen), lineOf(fset, genDecl.Rparen)
+		file := fset.File(genDecl.Pos())
+		if first > lparenLine+1 {
+			diagnostics = append(diagnostics, newDiagnostic(fset, file.LineStart(lparenLine+1), RuleBlockPadding,
+				"unexpected blank line after the opening parenthesis of the import block"))
+		}
+		if last < rparenLine-1 {
+			diagnostics = append(diagnostics, newDiagnostic(fset, file.LineStart(rparenLine-1), RuleBlockPadding,
+				"unexpected blank line before the closing parenthesis of the import block"))
+		}
+	}
+	return diagnostics
+}
+
+// blockContentLines returns the first and last physical line of the imports and comments
+// within the parentheses of genDecl
+func blockContentLines(fset *token.FileSet, node *ast.File, genDecl *ast.GenDecl) (first, last int) {
+	firstSpec := genDecl.Specs[0].(*ast.ImportSpec)
+	lastSpec := genDecl.Specs[len(genDecl.Specs)-1].(*ast.ImportSpec)
+	start, end := firstSpec.Pos(), lastSpec.End()
+	if firstSpec.Doc != nil {
+		start = firstSpec.Doc.Pos()
+	}
+	if lastSpec.Comment != nil {
+		end = lastSpec.Comment.End()
+	}
+	for _, comment := range node.Comments {
+		if comment.Pos() < genDecl.Lparen || comment.End() > genDecl.Rparen {
+			continue
+		}
+		start, end = min(start, comment.Pos()), max(end, comment.End())
+	}
+	return lineOf(fset, start), lineOf(fset, end)
+}
@@ -0,0 +1,73 @@
+package gogroupimports
+
+import (
+	"bytes"
+	"go/token"
+	"os"
+	"sync"
+)
+
+// fileSets holds emptied FileSets for reuse, so that a run doesn't allocate one per file
+var fileSets = sync.Pool{New: func() interface{} { return token.NewFileSet() }}
+
+// maxFileSetBase retires FileSets once their positions reach it. Removing a file frees its
+// line table but not its range of positions, so the base of a reused FileSet only grows.
+const maxFileSetBase = 1 << 30
+
+// getFileSet returns an empty FileSet, to be handed back with putFileSet
+func getFileSet() *token.FileSet {
+	return fileSets.Get().(*token.FileSet)
+}
+
+// putFileSet empties fset and keeps it for reuse. Positions of the removed files, like those
+// of diagnostics, can't be resolved with fset anymore.
+func putFileSet(fset *token.FileSet) {
+	if fset.Base() > maxFileSetBase {
+		return
+	}
+	var files []*token.File
+	fset.Iterate(func(file *token.File) bool {
+		files = append(files, file)
+		return true
+	})
+	for _, file := range files {
+		fset.RemoveFile(file)
+	}
+	fileSets.Put(fset)
+}
+
+// readBuffers holds the buffers files are read into for reuse
+var readBuffers = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// maxPooledBuffer is the capacity above which read buffers are dropped instead of kept, so
+// that one huge file doesn't pin its size for the rest of the run
+const maxPooledBuffer = 1 << 20
+
+// readFile reads filename into a pooled buffer, to be handed back with putBuffer once nothing
+// refers to its contents anymore
+func readFile(filename string) (*bytes.Buffer, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	buf := readBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	if info, err := file.Stat(); err == nil && info.Size() < maxPooledBuffer {
+		buf.Grow(int(info.Size()) + bytes.MinRead)
+	}
+	if _, err := buf.ReadFrom(file); err != nil {
+		putBuffer(buf)
+		return nil, err
+	}
+	return buf, nil
+}
+
+// putBuffer keeps buf for reuse by readFile
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	readBuffers.Put(buf)
+}
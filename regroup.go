@@ -0,0 +1,200 @@
+package gogroupimports
+
+import (
+	"go/ast"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// importLine is a single import of a block together with its comments
+type importLine struct {
+	path       string
+	importType string
+	text       string // Source lines of the import, including doc and line comments
+}
+
+// fixImportGroups rewrites every parenthesized import block of node so that its imports are
+// split into the expected groups separated by single blank lines
+func fixImportGroups(fset *token.FileSet, node *ast.File, src []byte, settings Settings) ([]textEdit, []Diagnostic) {
+	return fixImportGroupsIn(fset, node, src, settings, func(*ast.GenDecl) bool { return true })
+}
+
+// fixImportGroupsIn regroups the import blocks of node for which include returns true
+func fixImportGroupsIn(fset *token.FileSet, node *ast.File, src []byte, settings Settings, include func(*ast.GenDecl) bool) ([]textEdit, []Diagnostic) {
+	var edits []textEdit
+	var diagnostics []Diagnostic
+	for _, decl := range node.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT || !genDecl.Lparen.IsValid() || len(genDecl.Specs) == 0 || !include(genDecl) {
+			continue
+		}
+		edit, ok := regroupBlock(fset, node, src, genDecl, settings)
+		if !ok {
+			diagnostics = append(diagnostics, newDiagnostic(fset, genDecl.Pos(), RuleGrouping,
+				"cannot regroup import block automatically: every import and the closing parenthesis must be on a line of their own"))
+			continue
+		}
+		edits = append(edits, lineEdits(src, edit.start, edit.end, edit.text)...)
+	}
+	return edits, diagnostics
+}
+
+// narrowFixable clears Fixable of the diagnostics of node that Fix would leave in place: all
+// of them when a line directive among the imports keeps Fix from rewriting the file, and the
+// grouping, separator and import order diagnostics of blocks regroupBlock can't rewrite
+func narrowFixable(fset *token.FileSet, node *ast.File, src []byte, settings Settings, diagnostics []Diagnostic) {
+	if _, ok := lineDirectiveInImports(fset, node); ok {
+		for i := range diagnostics {
+			diagnostics[i].Fixable = false
+		}
+		return
+	}
+	for _, decl := range node.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT || !genDecl.Lparen.IsValid() || len(genDecl.Specs) == 0 {
+			continue
+		}
+		if _, ok := regroupBlock(fset, node, src, genDecl, settings); ok {
+			continue
+		}
+		for i, diagnostic := range diagnostics {
+			if (diagnostic.Rule == RuleGrouping || diagnostic.Rule == RuleSeparator || diagnostic.Rule == RuleImportOrder) && diagnostic.pos > genDecl.Lparen && diagnostic.pos < genDecl.Rparen {
+				diagnostics[i].Fixable = false
+			}
+		}
+	}
+}
+
+// regroupBlock computes the edit replacing the body of a parenthesized import block with its
+// regrouped imports. Callers narrow it down with lineEdits to the lines that actually move.
+// Doc and end-of-line comments move together with their import, free-standing comments stick
+// to the import following them and comments after the last import stay at the end of the
+// block. New lines end like the line of the opening parenthesis, so CRLF files stay CRLF. It
+// reports false when the block's layout can't be rewritten safely.
+func regroupBlock(fset *token.FileSet, node *ast.File, src []byte, genDecl *ast.GenDecl, settings Settings) (textEdit, bool) {
+	lparenLine := lineOf(fset, genDecl.Lparen)
+	rparenOffset := offsetOf(fset, genDecl.Rparen)
+	if strings.TrimSpace(string(src[lineStart(src, rparenOffset):rparenOffset])) != "" {
+		return textEdit{}, false
+	}
+
+	var lines []importLine
+	var firstLines, lastLines []int
+	lastLine := lparenLine
+	for _, spec := range genDecl.Specs {
+		importSpec := spec.(*ast.ImportSpec)
+		first, last := importSpec.Pos(), importSpec.End()
+		if importSpec.Doc != nil {
+			first = importSpec.Doc.Pos()
+		}
+		if importSpec.Comment != nil {
+			last = importSpec.Comment.End()
+		}
+		if lineOf(fset, first) <= lastLine {
+			return textEdit{}, false
+		}
+		lastLine = lineOf(fset, last)
+		firstLines = append(firstLines, lineOf(fset, first))
+		lastLines = append(lastLines, lastLine)
+
+		path := importPathOf(importSpec)
+		lines = append(lines, importLine{
+			path:       path,
+			importType: groupTypeOf(getSpecType(importSpec, settings), settings),
+			text:       sourceLines(fset, src, first, last),
+		})
+	}
+	if lineOf(fset, genDecl.Rparen) <= lastLine {
+		return textEdit{}, false
+	}
+
+	// Free-standing comments, e.g. commented out imports, travel with the next import
+	leading := make([][]string, len(lines))
+	var trailing []string
+	for _, comment := range node.Comments {
+		if comment.Pos() < genDecl.Lparen || comment.End() > genDecl.Rparen {
+			continue
+		}
+		line := lineOf(fset, comment.Pos())
+		if line == lparenLine {
+			continue
+		}
+		next := sort.SearchInts(lastLines, line)
+		if next < len(lines) && line >= firstLines[next] {
+			continue // Already part of the import's lines
+		}
+		text := sourceLines(fset, src, comment.Pos(), comment.End())
+		if next == len(lines) {
+			trailing = append(trailing, text)
+			continue
+		}
+		leading[next] = append(leading[next], text)
+	}
+	for i, comments := range leading {
+		if len(comments) > 0 {
+			lines[i].text = strings.Join(comments, "\n") + "\n" + lines[i].text
+		}
+	}
+
+	text := renderImportLines(lines, settings)
+	if len(trailing) > 0 {
+		text += "\n" + strings.Join(trailing, "\n") + "\n"
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if ending := lineEnding(src, offsetOf(fset, genDecl.Lparen)); ending != "\n" {
+		text = strings.ReplaceAll(text, "\n", ending)
+	}
+	return textEdit{
+		start: nextLineStart(src, offsetOf(fset, genDecl.Lparen)),
+		end:   lineStart(src, rparenOffset),
+		text:  text,
+	}, true
+}
+
+// sourceLines returns the complete lines of src spanning from pos to end, without the final newline.
+// Carriage returns and trailing blanks are kept so that lines which don't move compare equal.
+func sourceLines(fset *token.FileSet, src []byte, pos, end token.Pos) string {
+	start := lineStart(src, offsetOf(fset, pos))
+	stop := nextLineStart(src, offsetOf(fset, end))
+	return strings.TrimSuffix(string(src[start:stop]), "\n")
+}
+
+// lineEnding returns the line ending of the line containing offset, "\r\n" or "\n"
+func lineEnding(src []byte, offset int) string {
+	end := nextLineStart(src, offset)
+	if end >= 2 && src[end-1] == '\n' && src[end-2] == '\r' {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// renderImportLines orders lines by import type, then path, and separates the groups with blank lines.
+// The configured side-effect comment introduces the side-effect group.
+func renderImportLines(lines []importLine, settings Settings) string {
+	sorted := make([]importLine, len(lines))
+	copy(sorted, lines)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if order := compareImportTypes(sorted[i].importType, sorted[j].importType, settings); order != 0 {
+			return order < 0
+		}
+		return lessImportPath(sorted[i].path, sorted[j].path, settings)
+	})
+
+	sideEffectComment := sideEffectCommentLine(settings)
+	var text strings.Builder
+	for i, line := range sorted {
+		firstOfGroup := i == 0 || line.importType != sorted[i-1].importType
+		if firstOfGroup && i > 0 {
+			text.WriteString("\n")
+		}
+		if firstOfGroup && line.importType == "side_effect" && sideEffectComment != "" {
+			indent := line.text[:len(line.text)-len(strings.TrimLeft(line.text, " \t"))]
+			if !strings.HasPrefix(strings.TrimSpace(line.text), sideEffectComment) {
+				text.WriteString(indent + sideEffectComment + "\n")
+			}
+		}
+		text.WriteString(line.text + "\n")
+	}
+	return text.String()
+}
@@ -0,0 +1,43 @@
+package gogroupimports
+
+import (
+	"go/ast"
+	"go/token"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// RuleRelativeImport is reported for relative import paths like "./util", which the go
+// command only accepted in GOPATH mode
+const RuleRelativeImport = "relative-import"
+
+// isRelativeImport reports whether path is relative to the importing package
+func isRelativeImport(path string) bool {
+	return path == "." || path == ".." || strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../")
+}
+
+// checkRelativeImports reports relative imports, suggesting the full import path when the
+// package path of filename is known
+func checkRelativeImports(fset *token.FileSet, node *ast.File, settings Settings, filename string) []Diagnostic {
+	var diagnostics []Diagnostic
+	var pkgPath string
+	for _, importSpec := range node.Imports {
+		importPath := importPathOf(importSpec)
+		if !isRelativeImport(importPath) {
+			continue
+		}
+		if pkgPath == "" {
+			pkgPath, _ = packagePathOf(filename, settings)
+		}
+		suggestion := ""
+		if pkgPath != "" {
+			if full := path.Join(pkgPath, importPath); full == settings.SelfModule || strings.HasPrefix(full, settings.SelfModule+"/") {
+				suggestion = ", import " + strconv.Quote(full) + " instead"
+			}
+		}
+		diagnostics = append(diagnostics, newDiagnostic(fset, importSpec.Pos(), RuleRelativeImport,
+			"relative import %q is not supported in module mode%s", importPath, suggestion))
+	}
+	return diagnostics
+}
@@ -0,0 +1,89 @@
+package gogroupimports
+
+import (
+	"go/ast"
+	"go/token"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// RenameModule rewrites every import of oldPath, or of a package below it, in filename to the
+// same package under newPath and regroups the affected import blocks. The returned source is
+// nil when filename doesn't import anything from oldPath. The diagnostics report import blocks
+// that couldn't be regrouped.
+func (c *Checker) RenameModule(filename, oldPath, newPath string) ([]byte, []Diagnostic, error) {
+	settings, err := c.settingsFor(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The module being renamed may well be the current one
+	if renamed, ok := renamePath(settings.SelfModule, oldPath, newPath); ok {
+		settings.SelfModule = renamed
+	}
+
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	renamed := false
+	fixed, diagnostics, err := fixSource(filename, src, settings,
+		func(fset *token.FileSet, node *ast.File, src []byte, settings Settings) ([]textEdit, []Diagnostic) {
+			edits := renameImports(fset, node, oldPath, newPath)
+			renamed = len(edits) > 0
+			return edits, nil
+		},
+		func(fset *token.FileSet, node *ast.File, src []byte, settings Settings) ([]textEdit, []Diagnostic) {
+			if !renamed {
+				return nil, nil
+			}
+			return fixImportGroupsIn(fset, node, src, settings, func(genDecl *ast.GenDecl) bool {
+				return importsUnder(genDecl, newPath)
+			})
+		},
+	)
+	if err != nil || !renamed {
+		return nil, nil, err
+	}
+	return fixed, diagnostics, nil
+}
+
+// renameImports returns the edits replacing the oldPath prefix of import paths with newPath
+func renameImports(fset *token.FileSet, node *ast.File, oldPath, newPath string) []textEdit {
+	var edits []textEdit
+	for _, importSpec := range node.Imports {
+		renamed, ok := renamePath(importPathOf(importSpec), oldPath, newPath)
+		if !ok {
+			continue
+		}
+		edits = append(edits, textEdit{
+			start: offsetOf(fset, importSpec.Path.Pos()),
+			end:   offsetOf(fset, importSpec.Path.End()),
+			text:  strconv.Quote(renamed),
+		})
+	}
+	return edits
+}
+
+// renamePath swaps the oldPath prefix of path for newPath. It reports false when path is not
+// oldPath or a package below it.
+func renamePath(path, oldPath, newPath string) (string, bool) {
+	if path == oldPath {
+		return newPath, true
+	}
+	if strings.HasPrefix(path, oldPath+"/") {
+		return newPath + path[len(oldPath):], true
+	}
+	return path, false
+}
+
+// importsUnder reports whether genDecl imports modulePath or a package below it
+func importsUnder(genDecl *ast.GenDecl, modulePath string) bool {
+	for _, spec := range genDecl.Specs {
+		if _, ok := renamePath(importPathOf(spec.(*ast.ImportSpec)), modulePath, modulePath); ok {
+			return true
+		}
+	}
+	return false
+}
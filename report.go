@@ -0,0 +1,90 @@
+package gogroupimports
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// ImportTypes returns the import types in the order their groups are expected in
+func ImportTypes() []string {
+	return append([]string(nil), expectedSequence...)
+}
+
+// ImportInfo describes a single import of a file
+type ImportInfo struct {
+	Path   string `json:"path"`   // Imported package path
+	Type   string `json:"type"`   // Import type the path is classified as
+	Module string `json:"module"` // Module providing the package, guessed from the path unless UseGoList is set
+	Line   int    `json:"line"`   // Line of the import
+}
+
+// CountImports returns how many imports of filename fall into each import type
+func (c *Checker) CountImports(filename string) (map[string]int, error) {
+	imports, err := c.ListImports(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, info := range imports {
+		counts[info.Type]++
+	}
+	return counts, nil
+}
+
+// ListImports returns the classified imports of filename in source order
+func (c *Checker) ListImports(filename string) ([]ImportInfo, error) {
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, filename, nil, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	if len(node.Imports) == 0 {
+		return []ImportInfo{}, nil
+	}
+
+	settings, err := c.settingsFor(filename)
+	if err != nil {
+		return nil, err
+	}
+	settings, _ = withFileDirectives(fset, node, settings)
+
+	imports := make([]ImportInfo, 0, len(node.Imports))
+	for _, importSpec := range node.Imports {
+		path := importPathOf(importSpec)
+		importType := getSpecType(importSpec, settings)
+		imports = append(imports, ImportInfo{
+			Path:   path,
+			Type:   importType,
+			Module: modulePathOf(path, importType, settings),
+			Line:   fset.Position(importSpec.Pos()).Line,
+		})
+	}
+	return imports, nil
+}
+
+// modulePathOf returns the module providing path. Without a module index the module is guessed
+// as the repository root on the well known code hosts and the first two path elements elsewhere.
+func modulePathOf(path, importType string, settings Settings) string {
+	if module, ok := settings.modules.lookup(path); ok {
+		return module.Path
+	}
+	if importType == "builtin" {
+		return "std"
+	}
+
+	elements := strings.Split(path, "/")
+	n := 2
+	switch elements[0] {
+	case "github.com", "gitlab.com", "bitbucket.org", "golang.org":
+		n = 3
+	}
+	if len(elements) > n && isMajorVersion(elements[n]) {
+		n++
+	}
+	if len(elements) < n {
+		n = len(elements)
+	}
+	return strings.Join(elements[:n], "/")
+}
@@ -0,0 +1,106 @@
+package gogroupimports
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sync"
+)
+
+// Rule is a check added to every Checker with RegisterRule, for organization specific import
+// policies. It runs in the same pass as the built in checks, after them, and its diagnostics
+// get the same treatment: severities from the config, build variants, sorting and reporting
+// through every output format.
+type Rule interface {
+	// Name identifies the rule in diagnostics and in the severities setting
+	Name() string
+	// Check returns the problems of the parsed file. Settings are those of the file, with its
+	// directives applied. NewDiagnostic builds diagnostics positioned in fset.
+	Check(file *ast.File, fset *token.FileSet, settings Settings) []Diagnostic
+}
+
+// builtinRules are the names of the checks of the package, which rules can't take
+var builtinRules = map[string]bool{
+	RuleGrouping: true, RuleSeparator: true, RuleDeprecated: true, RuleInternal: true,
+	RuleLayer: true, RuleTestOnly: true, RuleTestPackage: true, RuleImportPath: true,
+	RuleDirective: true, RuleFactored: true, RuleSkipped: true, RuleLineDirective: true,
+	RuleRelativeImport: true, RuleBlockPadding: true, RuleMisplacedImport: true,
+	RulePackageSpacing: true, RuleDeclarationOrder: true, RuleImportOrder: true,
+	RuleMajorVersionAlias: true, RuleUnnecessaryAlias: true, RuleTemplate: true,
+}
+
+// rules holds the rules registered with RegisterRule, in the order they were registered
+var rules struct {
+	sync.RWMutex
+	list []Rule
+}
+
+// RegisterRule adds rule to the checks of every Checker. It panics when rule is nil or its name
+// is empty or taken, by a built in check or another rule, like database/sql.Register does.
+// Rules are usually registered from init functions, before any file is checked.
+func RegisterRule(rule Rule) {
+	if rule == nil {
+		panic("gogroupimports: RegisterRule of nil rule")
+	}
+	name := rule.Name()
+	if name == "" || builtinRules[name] {
+		panic(fmt.Sprintf("gogroupimports: RegisterRule of rule with reserved name %q", name))
+	}
+	rules.Lock()
+	defer rules.Unlock()
+	for _, registered := range rules.list {
+		if registered.Name() == name {
+			panic(fmt.Sprintf("gogroupimports: RegisterRule called twice for rule %s", name))
+		}
+	}
+	rules.list = append(rules.list, rule)
+}
+
+// NewDiagnostic builds a diagnostic of rule positioned at pos, for Rule implementations. Its
+// severity is SeverityError unless the severities setting changes it.
+func NewDiagnostic(fset *token.FileSet, pos token.Pos, rule string, format string, args ...interface{}) Diagnostic {
+	return newDiagnostic(fset, pos, rule, format, args...)
+}
+
+// checkRules runs the registered rules on the parsed file. Diagnostics the rules built
+// themselves are completed: they get the rule name, error severity and the path when missing,
+// and a position within node for the analyzer.
+func checkRules(fset *token.FileSet, node *ast.File, settings Settings) []Diagnostic {
+	rules.RLock()
+	list := rules.list
+	rules.RUnlock()
+
+	var diagnostics []Diagnostic
+	for _, rule := range list {
+		for _, diagnostic := range rule.Check(node, fset, settings) {
+			if diagnostic.Rule == "" {
+				diagnostic.Rule = rule.Name()
+			}
+			if diagnostic.Severity == "" {
+				diagnostic.Severity = SeverityError
+			}
+			if diagnostic.pos == token.NoPos {
+				diagnostic.pos = positionOf(fset, node, diagnostic.Line, diagnostic.Column)
+			}
+			if diagnostic.Path == "" {
+				diagnostic.Path = fset.Position(node.Pos()).Filename
+			}
+			diagnostics = append(diagnostics, diagnostic)
+		}
+	}
+	return diagnostics
+}
+
+// positionOf returns the position of line and column in the file of node, or the package
+// clause when they are out of range
+func positionOf(fset *token.FileSet, node *ast.File, line, column int) token.Pos {
+	file := fset.File(node.Pos())
+	if file == nil || line < 1 || line > file.LineCount() {
+		return node.Package
+	}
+	pos := file.LineStart(line) + token.Pos(max(column, 1)-1)
+	if int(pos)-file.Base() > file.Size() {
+		return node.Package
+	}
+	return pos
+}
@@ -0,0 +1,89 @@
+package gogroupimports
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Severities of diagnostics. By default only errors make a file fail, warnings are advisory and
+// infos merely note something, like a file that was skipped. The FailOn setting changes which
+// severities fail.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// validateSeverities checks that Severities only promotes and demotes rules to known severities
+func validateSeverities(settings Settings) error {
+	for rule, severity := range settings.Severities {
+		if severity != SeverityError && severity != SeverityWarning && severity != SeverityInfo {
+			return fmt.Errorf("invalid severity %q for rule %s, want %s, %s or %s", severity, rule, SeverityError, SeverityWarning, SeverityInfo)
+		}
+	}
+	return nil
+}
+
+// applySeverities sets the severity configured for the rule of every diagnostic, the others
+// keep the default of their rule
+func applySeverities(diagnostics []Diagnostic, settings Settings) []Diagnostic {
+	for i, diagnostic := range diagnostics {
+		if severity, ok := settings.Severities[diagnostic.Rule]; ok {
+			diagnostics[i].Severity = severity
+		}
+	}
+	return diagnostics
+}
+
+// validateFailOn checks that failOn only lists known severities
+func validateFailOn(failOn []string) error {
+	for _, severity := range failOn {
+		if severity != SeverityError && severity != SeverityWarning && severity != SeverityInfo {
+			return fmt.Errorf("unknown severity %q, want %s, %s or %s", severity, SeverityError, SeverityWarning, SeverityInfo)
+		}
+	}
+	return nil
+}
+
+// ParseFailOn parses a comma separated list of severities, like the value of a flag overriding
+// the failOn setting
+func ParseFailOn(list string) ([]string, error) {
+	var failOn []string
+	for _, severity := range strings.Split(list, ",") {
+		if severity = strings.TrimSpace(severity); severity != "" {
+			failOn = append(failOn, severity)
+		}
+	}
+	return failOn, validateFailOn(failOn)
+}
+
+// Fails reports whether one of diagnostics has a severity of the failOn setting, or of
+// SeverityError when it is unset, so that the file fails the check
+func (c *Checker) Fails(diagnostics []Diagnostic) bool {
+	if c.settings.FailOn == nil {
+		return HasErrors(diagnostics)
+	}
+	return FailsOn(diagnostics, c.settings.FailOn)
+}
+
+// FailsOn reports whether one of diagnostics has one of the severities of failOn
+func FailsOn(diagnostics []Diagnostic, failOn []string) bool {
+	for _, diagnostic := range diagnostics {
+		if slices.Contains(failOn, diagnostic.Severity) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasErrors reports whether one of diagnostics has SeverityError, meaning that the file fails
+// the check rather than only drawing warnings
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
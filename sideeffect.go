@@ -0,0 +1,30 @@
+package gogroupimports
+
+import (
+	"go/ast"
+	"strings"
+)
+
+// getSpecType determines the type of an import declaration. Blank imports of configured
+// side-effect packages, like SQL drivers or image decoders, get a trailing group of their own.
+func getSpecType(importSpec *ast.ImportSpec, settings Settings) string {
+	path := importPathOf(importSpec)
+	if importSpec.Name != nil && importSpec.Name.Name == "_" {
+		for _, sideEffect := range settings.SideEffectImports {
+			if matchesImport(sideEffect, path) {
+				return "side_effect"
+			}
+		}
+	}
+	return getImportType(path, settings)
+}
+
+// sideEffectCommentLine returns the comment line introducing the side-effect group, or an
+// empty string when none is configured
+func sideEffectCommentLine(settings Settings) string {
+	comment := strings.TrimSpace(settings.SideEffectComment)
+	if comment == "" || strings.HasPrefix(comment, "//") {
+		return comment
+	}
+	return "// " + comment
+}
@@ -0,0 +1,88 @@
+package gogroupimports
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// sortOrderSource keeps imports in the order they are written in within their group, so that
+// fixes only move imports between groups and insert separators
+const sortOrderSource = "source"
+
+// sortOrders holds the orders selectable with Settings.SortOrder. An order only has to decide
+// the paths it cares about, ties fall back to lexical order.
+var sortOrders = struct {
+	sync.RWMutex
+	less map[string]func(a, b string) bool
+}{less: map[string]func(a, b string) bool{
+	"lexical": func(a, b string) bool { return false },
+	"length":  func(a, b string) bool { return len(a) < len(b) },
+	"depth":   func(a, b string) bool { return strings.Count(a, "/") < strings.Count(b, "/") },
+	// Never consulted, lessImportPath keeps the source order before looking at the order
+	sortOrderSource: func(a, b string) bool { return false },
+}}
+
+// RegisterSortOrder makes less selectable as the order of imports within a group by setting
+// sortOrder to name. less reports whether import path a sorts before b; paths it considers
+// equal are sorted lexically. Checkers resolve their order when they are created, so orders
+// have to be registered before NewChecker is called. less may be called concurrently.
+func RegisterSortOrder(name string, less func(a, b string) bool) {
+	sortOrders.Lock()
+	defer sortOrders.Unlock()
+	sortOrders.less[name] = less
+}
+
+// lookupSortOrder returns the order registered as name, which defaults to lexical
+func lookupSortOrder(name string) (func(a, b string) bool, error) {
+	if name == "" {
+		name = "lexical"
+	}
+	sortOrders.RLock()
+	defer sortOrders.RUnlock()
+	less, ok := sortOrders.less[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort order %q", name)
+	}
+	return less, nil
+}
+
+// priorityRank returns the index of the first SortPriority prefix matching path, with
+// unmatched paths sorting after all of them
+func priorityRank(path string, settings Settings) int {
+	for i, prefix := range settings.SortPriority {
+		if matchesImport(prefix, path) {
+			return i
+		}
+	}
+	return len(settings.SortPriority)
+}
+
+// lessImportPath orders two import paths of the same group. With the source order no path
+// sorts before another, so the stable sort of the fixer keeps them as they are.
+func lessImportPath(a, b string, settings Settings) bool {
+	if settings.SortOrder == sortOrderSource {
+		return false
+	}
+	if rankA, rankB := priorityRank(a, settings), priorityRank(b, settings); rankA != rankB {
+		return rankA < rankB
+	}
+	less := settings.less
+	if less == nil {
+		less, _ = lookupSortOrder(settings.SortOrder)
+	}
+	if less != nil {
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+	}
+	if settings.CaseInsensitiveSort {
+		if lowerA, lowerB := strings.ToLower(a), strings.ToLower(b); lowerA != lowerB {
+			return lowerA < lowerB
+		}
+	}
+	return a < b
+}
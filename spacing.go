@@ -0,0 +1,74 @@
+package gogroupimports
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// RulePackageSpacing is reported when PackageImportSpacing is set and the imports don't follow
+// the package clause after exactly one blank line
+const RulePackageSpacing = "package-spacing"
+
+// packageSpacing returns the first import declaration of node, where it starts including its
+// doc comment, and the number of blank lines between it and the package clause. ok is false
+// when there are no imports or comments between them, which leave the spacing to the author.
+func packageSpacing(fset *token.FileSet, node *ast.File) (genDecl *ast.GenDecl, start token.Pos, blank int, ok bool) {
+	if len(node.Decls) == 0 {
+		return nil, token.NoPos, 0, false
+	}
+	genDecl, isGenDecl := node.Decls[0].(*ast.GenDecl)
+	if !isGenDecl || genDecl.Tok != token.IMPORT {
+		return nil, token.NoPos, 0, false
+	}
+	start = genDecl.Pos()
+	if genDecl.Doc != nil {
+		start = genDecl.Doc.Pos()
+	}
+
+	packageLine := lineOf(fset, node.Name.End())
+	for _, comment := range node.Comments {
+		if comment.Pos() > node.Name.End() && comment.Pos() < start && lineOf(fset, comment.Pos()) > packageLine {
+			return nil, token.NoPos, 0, false
+		}
+	}
+	return genDecl, start, lineOf(fset, start) - packageLine - 1, true
+}
+
+// checkPackageSpacing reports imports that aren't separated from the package clause by exactly
+// one blank line
+func checkPackageSpacing(fset *token.FileSet, node *ast.File, settings Settings) []Diagnostic {
+	if !settings.PackageImportSpacing {
+		return nil
+	}
+	genDecl, _, blank, ok := packageSpacing(fset, node)
+	switch {
+	case !ok || blank == 1:
+		return nil
+	case blank < 1:
+		return []Diagnostic{newDiagnostic(fset, genDecl.Pos(), RulePackageSpacing,
+			"missing blank line between the package clause and the imports")}
+	default:
+		return []Diagnostic{newDiagnostic(fset, genDecl.Pos(), RulePackageSpacing,
+			"%d blank lines between the package clause and the imports, want 1", blank)}
+	}
+}
+
+// fixPackageSpacing leaves exactly one blank line between the package clause and the imports
+func fixPackageSpacing(fset *token.FileSet, node *ast.File, src []byte, settings Settings) ([]textEdit, []Diagnostic) {
+	if !settings.PackageImportSpacing {
+		return nil, nil
+	}
+	genDecl, start, blank, ok := packageSpacing(fset, node)
+	if !ok || blank == 1 {
+		return nil, nil
+	}
+	if blank < 0 {
+		return nil, []Diagnostic{newDiagnostic(fset, genDecl.Pos(), RulePackageSpacing,
+			"cannot space the imports automatically: they must start on a line of their own")}
+	}
+	return []textEdit{{
+		start: nextLineStart(src, offsetOf(fset, node.Name.End())),
+		end:   lineStart(src, offsetOf(fset, start)),
+		text:  "\n",
+	}}, nil
+}
@@ -0,0 +1,99 @@
+package gogroupimports
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// stdlib holds the package paths found under GOROOT/src, loaded once per process and GOROOT
+var stdlib struct {
+	sync.Mutex
+	packages map[string]map[string]bool // By GOROOT
+}
+
+// stdlibPackages returns the set of import paths provided by the GOROOT of settings, see
+// gorootOf, reading them from the cache when the same Go installation was indexed before
+func stdlibPackages(settings Settings) map[string]bool {
+	goroot, version := gorootOf(settings)
+	stdlib.Lock()
+	defer stdlib.Unlock()
+	if packages, ok := stdlib.packages[goroot]; ok {
+		return packages
+	}
+
+	key := cacheKey([]byte("stdlib"), []byte(version), []byte(goroot))
+	var paths []string
+	if !settings.cache.get(key, &paths) {
+		paths = scanStdlib(goroot)
+		if len(paths) > 0 {
+			settings.cache.put(key, paths)
+		}
+	}
+
+	packages := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		packages[path] = true
+	}
+	if stdlib.packages == nil {
+		stdlib.packages = make(map[string]map[string]bool)
+	}
+	stdlib.packages[goroot] = packages
+	return packages
+}
+
+// scanStdlib lists every directory below GOROOT/src as an import path
+func scanStdlib(goroot string) []string {
+	src := filepath.Join(goroot, "src")
+	var paths []string
+	_ = filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+		if entry.Name() == "testdata" {
+			return filepath.SkipDir
+		}
+		if rel, err := filepath.Rel(src, path); err == nil && rel != "." {
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return paths
+}
+
+// isStdlibPath reports whether path names a package below the GOROOT of settings
+func isStdlibPath(path string, settings Settings) bool {
+	return inStdlib(stdlibPackages(settings), path)
+}
+
+// inStdlib reports whether path is one of the stdlib packages. Import paths are compared as
+// strings, never looked up on disk, so that "FMT" isn't taken for fmt on case insensitive file
+// systems and OS separators don't matter. Without a GOROOT to scan, as in browsers, there are no
+// packages and paths shaped like stdlib ones are taken for the stdlib, since module paths start
+// with a domain.
+func inStdlib(stdlib map[string]bool, path string) bool {
+	if len(stdlib) == 0 {
+		return isStdlibShaped(path)
+	}
+	return stdlib[path]
+}
+
+// isStdlibShaped reports whether path looks like the import path of a stdlib package: clean
+// slash separated elements of lowercase letters, digits and underscores, like net/http
+func isStdlibShaped(path string) bool {
+	if path == "" {
+		return false
+	}
+	for _, element := range strings.Split(path, "/") {
+		if element == "" {
+			return false
+		}
+		for _, r := range element {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+				return false
+			}
+		}
+	}
+	return true
+}
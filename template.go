@@ -0,0 +1,232 @@
+package gogroupimports
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// RuleTemplate is reported for code generation templates the fixer leaves alone because fixing
+// would lose template actions or move imports across them
+const RuleTemplate = "template"
+
+// templateExtensions are the extensions of code generation templates holding Go source
+var templateExtensions = []string{".gotmpl", ".tmpl"}
+
+// IsTemplateFile reports whether filename is a code generation template, like model.go.tmpl or
+// client.gotmpl. Check and Fix treat its template actions as opaque and handle the Go source
+// around them.
+func IsTemplateFile(filename string) bool {
+	for _, extension := range templateExtensions {
+		if strings.HasSuffix(filename, extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// templateAction is a template action of a template, replaced by a placeholder in its Go source
+type templateAction struct {
+	text        string // The action including its delimiters
+	placeholder string // What stands for the action in the Go source
+	offset      int    // Offset of the placeholder in the Go source
+}
+
+// templateSource returns the Go source of the template src with every action replaced by a
+// placeholder, of the same length where possible so that positions stay the same. Actions on
+// lines of their own, like {{if .Log}}, become comments, others identifiers, like the package
+// name in package {{.Name}}. Within string literals the placeholder holds a dot, so that import
+// paths starting with an action, like "{{.Module}}/api", don't look like standard library ones.
+// Placeholders consist of marker followed by the number of the action.
+func templateSource(src []byte) (goSrc []byte, actions []templateAction, marker string) {
+	spans := findActions(src)
+	if len(spans) == 0 {
+		return src, nil, ""
+	}
+
+	// Placeholders are numbered after a marker the template doesn't use
+	marker = "Q"
+	for hasNumbered(src, marker) {
+		marker += "Q"
+	}
+
+	// The template without its actions, to tell what surrounds them
+	masked := bytes.Clone(src)
+	for _, span := range spans {
+		for i := span[0]; i < span[1]; i++ {
+			if masked[i] != '\n' {
+				masked[i] = ' '
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	actions = make([]templateAction, 0, len(spans))
+	last := 0
+	for i, span := range spans {
+		text := string(src[span[0]:span[1]])
+		id := marker + strconv.Itoa(i)
+		var placeholder string
+		switch {
+		case aloneOnLines(masked, span[0], span[1]):
+			newlines := strings.Count(text, "\n")
+			spaces := max(len(text)-len(id)-newlines-4, 0)
+			placeholder = "/*" + id + strings.Repeat(" ", spaces) + strings.Repeat("\n", newlines) + "*/"
+		case inStringLiteral(masked, span[0]):
+			placeholder = id + "." + strings.Repeat("x", max(len(text)-len(id)-1, 1))
+		default:
+			placeholder = id + strings.Repeat("x", max(len(text)-len(id), 0))
+		}
+		out.Write(src[last:span[0]])
+		actions = append(actions, templateAction{text: text, placeholder: placeholder, offset: out.Len()})
+		out.WriteString(placeholder)
+		last = span[1]
+	}
+	out.Write(src[last:])
+	return out.Bytes(), actions, marker
+}
+
+// hasTemplateAction reports whether path holds a placeholder of a template action, which stands
+// for something unknown
+func hasTemplateAction(path string, settings Settings) bool {
+	return settings.templateMarker != "" && hasNumbered([]byte(path), settings.templateMarker)
+}
+
+// importsHoldLineActions reports whether an import declaration of the Go source of a template
+// holds actions on lines of their own, like {{if .Log}}. Those control which imports are
+// generated, so moving imports around them changes what the template does.
+func importsHoldLineActions(goSrc []byte, actions []templateAction) bool {
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, "", goSrc, parser.ImportsOnly)
+	if err != nil {
+		return false
+	}
+	for _, decl := range node.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT {
+			continue
+		}
+		start, end := offsetOf(fset, genDecl.Pos()), offsetOf(fset, genDecl.End())
+		for _, action := range actions {
+			if strings.HasPrefix(action.placeholder, "/*") && start <= action.offset && action.offset < end {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// restoreTemplate puts the actions back in place of their placeholders in the fixed Go source
+// of a template. ok is false when the fix dropped or repeated a placeholder.
+func restoreTemplate(src []byte, actions []templateAction) (restored []byte, ok bool) {
+	restored = src
+	for _, action := range actions {
+		placeholder := []byte(action.placeholder)
+		if bytes.Count(restored, placeholder) != 1 {
+			return nil, false
+		}
+		restored = bytes.Replace(restored, placeholder, []byte(action.text), 1)
+	}
+	return restored, true
+}
+
+// findActions returns the start and end offsets of the {{ }} actions of src. An action that
+// isn't closed ends the search, leaving the rest of src as it is.
+func findActions(src []byte) [][2]int {
+	var spans [][2]int
+	for i := 0; i < len(src); {
+		start := bytes.Index(src[i:], []byte("{{"))
+		if start < 0 {
+			break
+		}
+		start += i
+		end := actionEnd(src, start+2)
+		if end < 0 {
+			break
+		}
+		spans = append(spans, [2]int{start, end})
+		i = end
+	}
+	return spans
+}
+
+// actionEnd returns the offset after the }} closing the action whose body starts at i, skipping
+// the quoted strings and comments within it, -1 when the action isn't closed
+func actionEnd(src []byte, i int) int {
+	for i < len(src) {
+		switch c := src[i]; {
+		case c == '"' || c == '\'' || c == '`':
+			j := i + 1
+			for j < len(src) && src[j] != c {
+				if src[j] == '\\' && c != '`' {
+					j++
+				}
+				j++
+			}
+			i = j + 1
+		case bytes.HasPrefix(src[i:], []byte("/*")):
+			j := bytes.Index(src[i+2:], []byte("*/"))
+			if j < 0 {
+				return -1
+			}
+			i += j + 4
+		case bytes.HasPrefix(src[i:], []byte("}}")):
+			return i + 2
+		default:
+			i++
+		}
+	}
+	return -1
+}
+
+// hasNumbered reports whether src contains prefix followed by a digit
+func hasNumbered(src []byte, prefix string) bool {
+	for i := 0; ; {
+		j := bytes.Index(src[i:], []byte(prefix))
+		if j < 0 {
+			return false
+		}
+		i += j + len(prefix)
+		if i < len(src) && '0' <= src[i] && src[i] <= '9' {
+			return true
+		}
+	}
+}
+
+// aloneOnLines reports whether the lines from start to end hold nothing but the action and
+// other actions, masked holding the template with its actions blanked out
+func aloneOnLines(masked []byte, start, end int) bool {
+	from, to := lineStart(masked, start), nextLineStart(masked, end)
+	return len(bytes.TrimSpace(masked[from:to])) == 0
+}
+
+// inStringLiteral reports whether offset lies within an interpreted string literal, going by
+// the quotes before it on its line
+func inStringLiteral(masked []byte, offset int) bool {
+	quotes := 0
+	for i := lineStart(masked, offset); i < offset; i++ {
+		switch masked[i] {
+		case '\\':
+			i++
+		case '"':
+			quotes++
+		}
+	}
+	return quotes%2 == 1
+}
+
+// templateFixRefused returns the diagnostic of a template the fixer leaves alone
+func templateFixRefused(filename string, settings Settings, reason string) Diagnostic {
+	diagnostics := applySeverities([]Diagnostic{{
+		Path:     filename,
+		Line:     1,
+		Column:   1,
+		Rule:     RuleTemplate,
+		Severity: SeverityError,
+		Message:  "not rewriting the template, " + reason,
+	}}, settings)
+	return diagnostics[0]
+}
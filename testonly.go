@@ -0,0 +1,63 @@
+package gogroupimports
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// checkTestOnlyImports reports imports of test-only modules from files that end up in
+// production binaries
+func checkTestOnlyImports(fset *token.FileSet, node *ast.File, settings Settings, filename string) []Diagnostic {
+	if len(settings.TestOnlyImports) == 0 || isTestFile(filename) {
+		return nil
+	}
+
+	var diagnostics []Diagnostic
+	for _, importSpec := range node.Imports {
+		path := importPathOf(importSpec)
+		for _, testOnly := range settings.TestOnlyImports {
+			if matchesImport(testOnly, path) {
+				diagnostics = append(diagnostics, newDiagnostic(fset, importSpec.Pos(), RuleTestOnly,
+					"%q is a test-only dependency and may only be imported from _test.go files", path))
+				break
+			}
+		}
+	}
+	return diagnostics
+}
+
+// isTestFile reports whether filename is only compiled by go test
+func isTestFile(filename string) bool {
+	return strings.HasSuffix(filename, "_test.go")
+}
+
+// matchesImport reports whether path is the module or package prefix, or matches it as a
+// pattern when it contains wildcards
+func matchesImport(prefix, path string) bool {
+	if strings.ContainsAny(prefix, "*?[") {
+		return matchPattern(prefix, path)
+	}
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// checkTestPackageImports reports production files importing mocks or testdata packages
+// matching TestPackagePatterns, which almost always means something is wired up wrong
+func checkTestPackageImports(fset *token.FileSet, node *ast.File, settings Settings, filename string) []Diagnostic {
+	if len(settings.TestPackagePatterns) == 0 || isTestFile(filename) {
+		return nil
+	}
+
+	var diagnostics []Diagnostic
+	for _, importSpec := range node.Imports {
+		path := importPathOf(importSpec)
+		for _, pattern := range settings.TestPackagePatterns {
+			if matchPattern(pattern, path) {
+				diagnostics = append(diagnostics, newDiagnostic(fset, importSpec.Pos(), RuleTestPackage,
+					"%q matches %q and should only be imported from _test.go files", path, pattern))
+				break
+			}
+		}
+	}
+	return diagnostics
+}
@@ -0,0 +1,56 @@
+package gogroupimports
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/build"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// toolchain holds the GOROOT and Go version of the go command, detected once per process
+var toolchain struct {
+	once    sync.Once
+	goroot  string
+	version string
+}
+
+// Toolchain returns the GOROOT and Go version the go command uses, as reported by go env. They
+// differ from those the binary was built with when a toolchain directive or GOTOOLCHAIN selects
+// another toolchain. Without a working go command, as in stripped containers, they are those of
+// the binary, whose GOROOT may not exist. The go command runs once per process.
+func Toolchain() (goroot, version string) {
+	toolchain.once.Do(func() {
+		toolchain.goroot, toolchain.version = build.Default.GOROOT, runtime.Version()
+		out, err := exec.Command("go", "env", "-json", "GOROOT", "GOVERSION").Output()
+		if err != nil {
+			debugLog.Printf("go env failed, using GOROOT %s: %v", toolchain.goroot, err)
+			return
+		}
+		var env struct{ GOROOT, GOVERSION string }
+		if err := json.Unmarshal(out, &env); err != nil || env.GOROOT == "" {
+			debugLog.Printf("unexpected go env output, using GOROOT %s: %s", toolchain.goroot, bytes.TrimSpace(out))
+			return
+		}
+		toolchain.goroot, toolchain.version = env.GOROOT, env.GOVERSION
+	})
+	return toolchain.goroot, toolchain.version
+}
+
+// gorootOf returns the GOROOT of settings and its Go version: the GOROOT setting, read from its
+// VERSION file, or the one detected by Toolchain
+func gorootOf(settings Settings) (goroot, version string) {
+	if settings.GOROOT == "" {
+		return Toolchain()
+	}
+	content, err := os.ReadFile(filepath.Join(settings.GOROOT, "VERSION"))
+	if err != nil {
+		return settings.GOROOT, ""
+	}
+	version, _, _ = strings.Cut(string(content), "\n")
+	return settings.GOROOT, strings.TrimSpace(version)
+}
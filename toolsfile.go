@@ -0,0 +1,54 @@
+package gogroupimports
+
+import (
+	"fmt"
+	"go/ast"
+)
+
+// Values of the ToolsFiles setting, for files pinning tool dependencies
+const (
+	// ToolsFilesSkip leaves tools files unchecked and unfixed
+	ToolsFilesSkip = "skip"
+	// ToolsFilesSingleGroup holds tools files to one sorted group of imports, whatever their
+	// types. Imports out of order are reported by the import-order rule, like in other groups.
+	ToolsFilesSingleGroup = "single-group"
+)
+
+// toolsGroup is the group every import of a tools file goes into with ToolsFilesSingleGroup
+const toolsGroup = "tools"
+
+// validateToolsFiles checks that mode is empty or a known ToolsFiles value
+func validateToolsFiles(mode string) error {
+	switch mode {
+	case "", ToolsFilesSkip, ToolsFilesSingleGroup:
+		return nil
+	}
+	return fmt.Errorf("unknown tools files mode %q, want %s or %s", mode, ToolsFilesSkip, ToolsFilesSingleGroup)
+}
+
+// isToolsFile reports whether node only pins tool dependencies: it is built with the tools tag
+// alone, like //go:build tools, and all its imports are blank
+func isToolsFile(node *ast.File) bool {
+	if len(node.Imports) == 0 {
+		return false
+	}
+	for _, importSpec := range node.Imports {
+		if importSpec.Name == nil || importSpec.Name.Name != "_" {
+			return false
+		}
+	}
+	return builtOnlyWith(node, "tools")
+}
+
+// withToolsFileProfile returns settings for node following ToolsFiles when it is a tools file,
+// and whether the file is skipped
+func withToolsFileProfile(node *ast.File, settings Settings) (Settings, bool) {
+	if settings.ToolsFiles == "" || !isToolsFile(node) {
+		return settings, false
+	}
+	if settings.ToolsFiles == ToolsFilesSkip {
+		return settings, true
+	}
+	settings.singleGroup = true
+	return settings, false
+}
@@ -0,0 +1,47 @@
+package gogroupimports
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Tracer receives the spans of checks and fixes, so that services running the checker can see
+// where the time goes. Start begins the span name about filename as a child of the span in ctx
+// and returns the context of the new span with the function ending it.
+//
+// The spans are "gogroupimports.check" and "gogroupimports.fix" for a whole file, with the
+// children "read" for reading the file, unless its source is given, "parse", "modules" for the
+// module lookups of UseGoList with a "cache" child for every lookup in the persisted cache,
+// "classify" for the checks and "rewrite" for the fixes. The serve and lsp commands export
+// them with -otlp-endpoint. An OpenTelemetry tracer is adapted by starting a span with the
+// filename as attribute:
+//
+//	func (t otelTracer) Start(ctx context.Context, name, filename string) (context.Context, func()) {
+//		ctx, span := t.tracer.Start(ctx, name, trace.WithAttributes(attribute.String("file", filename)))
+//		return ctx, func() { span.End() }
+//	}
+type Tracer interface {
+	Start(ctx context.Context, name, filename string) (context.Context, func())
+}
+
+// tracer holds the Tracer set with SetTracer, nil when tracing is off
+var tracer atomic.Pointer[Tracer]
+
+// SetTracer sends the spans of checks and fixes to t, nil turns tracing off. The spans of
+// Check and Fix are roots, CheckContext and FixContext add them to the span of their context.
+func SetTracer(t Tracer) {
+	if t == nil {
+		tracer.Store(nil)
+		return
+	}
+	tracer.Store(&t)
+}
+
+// startSpan begins a span with the Tracer set with SetTracer, doing nothing without one
+func startSpan(ctx context.Context, name, filename string) (context.Context, func()) {
+	t := tracer.Load()
+	if t == nil {
+		return ctx, func() {}
+	}
+	return (*t).Start(ctx, name, filename)
+}
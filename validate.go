@@ -0,0 +1,157 @@
+package gogroupimports
+
+import (
+	"fmt"
+	"go/token"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SettingError is a problem with the value of one setting, named by its json name
+type SettingError struct {
+	Setting string
+	Err     error
+	// Warning marks problems that leave the settings usable, like repeated values or settings
+	// without effect, rather than invalid values
+	Warning bool
+}
+
+func (e *SettingError) Error() string {
+	return e.Setting + ": " + e.Err.Error()
+}
+
+func (e *SettingError) Unwrap() error {
+	return e.Err
+}
+
+// Validate reports every problem of settings rather than only the first one: invalid patterns,
+// severities and sort orders, which NewChecker refuses as well, but also values that are
+// repeated or overlap so that they have no effect. Each problem is a SettingError in the
+// returned Errors, the latter ones marked as warnings.
+func (settings Settings) Validate() error {
+	var errs []error
+	report := func(setting string, format string, args ...interface{}) {
+		errs = append(errs, &SettingError{Setting: setting, Err: fmt.Errorf(format, args...)})
+	}
+	warn := func(setting string, format string, args ...interface{}) {
+		errs = append(errs, &SettingError{Setting: setting, Err: fmt.Errorf(format, args...), Warning: true})
+	}
+
+	patterns := map[string][]string{
+		"testOnlyImports":     settings.TestOnlyImports,
+		"testPackagePatterns": settings.TestPackagePatterns,
+		"sideEffectImports":   settings.SideEffectImports,
+		"generatedImports":    settings.GeneratedImports,
+		"sortPriority":        settings.SortPriority,
+	}
+	for _, rule := range settings.LayerRules {
+		patterns["layerRules"] = append(patterns["layerRules"], rule.Packages)
+		patterns["layerRules"] = append(patterns["layerRules"], rule.Deny...)
+		patterns["layerRules"] = append(patterns["layerRules"], rule.Allow...)
+	}
+	for _, setting := range []string{"testOnlyImports", "testPackagePatterns", "sideEffectImports", "generatedImports", "sortPriority", "layerRules"} {
+		for _, pattern := range patterns[setting] {
+			if err := checkPattern(pattern); err != nil {
+				errs = append(errs, &SettingError{Setting: setting, Err: err})
+			}
+		}
+	}
+
+	if err := validateSeverities(settings); err != nil {
+		errs = append(errs, &SettingError{Setting: "severities", Err: err})
+	}
+	if err := validateFailOn(settings.FailOn); err != nil {
+		errs = append(errs, &SettingError{Setting: "failOn", Err: err})
+	}
+	if err := validateAliasScheme(settings.AliasScheme); err != nil {
+		errs = append(errs, &SettingError{Setting: "aliasScheme", Err: err})
+	}
+	if err := validateToolsFiles(settings.ToolsFiles); err != nil {
+		errs = append(errs, &SettingError{Setting: "toolsFiles", Err: err})
+	}
+	if _, err := lookupSortOrder(settings.SortOrder); err != nil {
+		errs = append(errs, &SettingError{Setting: "sortOrder", Err: err})
+	}
+	if settings.MaxFileSize < 0 {
+		report("maxFileSize", "must not be negative")
+	}
+	if settings.ParseTimeout != "" {
+		if timeout, err := time.ParseDuration(settings.ParseTimeout); err != nil {
+			report("parseTimeout", "%q is not a duration like 2s", settings.ParseTimeout)
+		} else if timeout < 0 {
+			report("parseTimeout", "must not be negative")
+		}
+	}
+	if settings.MaxImports < 0 {
+		report("maxImports", "must not be negative")
+	}
+
+	lists := []struct {
+		setting string
+		values  []string
+	}{
+		{"internalPrivateDomains", settings.InternalPrivateDomains},
+		{"testOnlyImports", settings.TestOnlyImports},
+		{"testPackagePatterns", settings.TestPackagePatterns},
+		{"sideEffectImports", settings.SideEffectImports},
+		{"generatedImports", settings.GeneratedImports},
+		{"hostOrder", settings.HostOrder},
+		{"sortPriority", settings.SortPriority},
+	}
+	for _, list := range lists {
+		seen := make(map[string]bool)
+		for _, value := range list.values {
+			if seen[value] {
+				warn(list.setting, "%q is listed more than once", value)
+			}
+			seen[value] = true
+		}
+	}
+
+	// Domains match by substring, so a domain containing another one never decides anything
+	for i, domain := range settings.InternalPrivateDomains {
+		if domain == "" {
+			report("internalPrivateDomains", "an empty domain matches every import")
+			continue
+		}
+		for j, other := range settings.InternalPrivateDomains {
+			if i != j && other != "" && domain != other && strings.Contains(domain, other) {
+				warn("internalPrivateDomains", "%q is redundant, every path containing it also contains %q", domain, other)
+				break
+			}
+		}
+		if settings.SelfModule != "" && !settings.UseGoList && strings.Contains(settings.SelfModule, domain) {
+			warn("internalPrivateDomains", "%q matches selfModule %q, so its packages are classified as internal private rather than own module; set useGoList to tell them apart", domain, settings.SelfModule)
+		}
+	}
+	if len(settings.HostOrder) > 0 && !settings.GroupByHost {
+		warn("hostOrder", "has no effect without groupByHost")
+	}
+	if settings.MergeInternalAndOwnModule && settings.GroupByHost {
+		warn("mergeInternalAndOwnModule", "has no effect with groupByHost, which groups imports by host instead")
+	}
+
+	var aliased []string
+	for importPath := range settings.MajorVersionAliases {
+		aliased = append(aliased, importPath)
+	}
+	sort.Strings(aliased)
+	for _, importPath := range aliased {
+		alias := settings.MajorVersionAliases[importPath]
+		if majorVersionOf(importPath) == "" {
+			report("majorVersionAliases", "%q has no major version suffix like /v2", importPath)
+		}
+		if !token.IsIdentifier(alias) || alias == "_" {
+			report("majorVersionAliases", "alias %q of %s is not a package name", alias, importPath)
+		}
+	}
+	if len(settings.MajorVersionAliases) > 0 && !settings.RequireMajorVersionAliases {
+		warn("majorVersionAliases", "has no effect without requireMajorVersionAliases")
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return Errors(errs)
+}
@@ -0,0 +1,187 @@
+package gogroupimports
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// vanityTimeout bounds a single go-import meta tag lookup
+const vanityTimeout = 5 * time.Second
+
+// vanityRoots caches go-import lookups by import path for the lifetime of the process.
+// Failed lookups are cached as empty roots so unreachable hosts are only tried once.
+var vanityRoots = struct {
+	sync.Mutex
+	roots map[string]vanityRoot
+}{roots: make(map[string]vanityRoot)}
+
+// vanityRoot maps an import prefix to the repository it is hosted in
+type vanityRoot struct {
+	prefix string
+	repo   string
+}
+
+// resolveVanityImport returns the path importPath is hosted under, using the configured vanity
+// prefixes first and go-import meta tags when enabled. Of overlapping prefixes, like go.corp.dev
+// and go.corp.dev/legacy, the longest one matching decides. It reports false for non vanity
+// paths.
+func resolveVanityImport(importPath string, settings Settings) (string, bool) {
+	bestPrefix, bestHosted, found := "", "", false
+	for prefix, hosted := range settings.VanityImports {
+		prefix = strings.TrimSuffix(strings.TrimSuffix(prefix, "*"), "/")
+		hosted = strings.TrimSuffix(strings.TrimSuffix(hosted, "*"), "/")
+		if importPath != prefix && !strings.HasPrefix(importPath, prefix+"/") {
+			continue
+		}
+		// Prefixes only differing in a trailing /* tie, the smaller hosting path keeps it stable
+		if !found || len(prefix) > len(bestPrefix) || len(prefix) == len(bestPrefix) && hosted < bestHosted {
+			bestPrefix, bestHosted, found = prefix, hosted, true
+		}
+	}
+	if found {
+		return bestHosted + importPath[len(bestPrefix):], true
+	}
+
+	if !settings.ResolveVanityImports || isWellKnownHost(importPath) {
+		return "", false
+	}
+	root := lookupVanityRoot(importPath)
+	if root.repo == "" {
+		return "", false
+	}
+	return root.repo + importPath[len(root.prefix):], true
+}
+
+// isWellKnownHost reports whether importPath is on a code host that never uses vanity paths
+func isWellKnownHost(importPath string) bool {
+	host, _, _ := strings.Cut(importPath, "/")
+	switch host {
+	case "github.com", "gitlab.com", "bitbucket.org":
+		return true
+	}
+	return !strings.Contains(host, ".")
+}
+
+// vanityLookups lets concurrent checks importing the same path share a single request
+var vanityLookups singleflight.Group
+
+// lookupVanityRoot returns the cached go-import root of importPath, fetching it when needed
+func lookupVanityRoot(importPath string) vanityRoot {
+	if root, ok := cachedVanityRoot(importPath); ok {
+		debugLog.Printf("vanity import root of %s already resolved", importPath)
+		return root
+	}
+
+	root, _, _ := vanityLookups.Do(importPath, func() (interface{}, error) {
+		root, err := fetchVanityRoot(importPath)
+		if err != nil {
+			root = vanityRoot{}
+		}
+
+		vanityRoots.Lock()
+		defer vanityRoots.Unlock()
+		vanityRoots.roots[importPath] = root
+		if root.prefix != "" {
+			vanityRoots.roots[root.prefix] = root
+		}
+		return root, nil
+	})
+	return root.(vanityRoot)
+}
+
+// cachedVanityRoot returns the root of importPath or one of its parents resolved earlier
+func cachedVanityRoot(importPath string) (vanityRoot, bool) {
+	vanityRoots.Lock()
+	defer vanityRoots.Unlock()
+	for path := importPath; path != "." && path != ""; path = parentPath(path) {
+		if root, ok := vanityRoots.roots[path]; ok {
+			return root, true
+		}
+	}
+	return vanityRoot{}, false
+}
+
+// fetchVanityRoot requests importPath with ?go-get=1 and parses the go-import meta tag
+func fetchVanityRoot(importPath string) (vanityRoot, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), vanityTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+importPath+"?go-get=1", nil)
+	if err != nil {
+		return vanityRoot{}, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return vanityRoot{}, err
+	}
+	defer resp.Body.Close()
+
+	return parseGoImport(importPath, resp.Body)
+}
+
+// parseGoImport finds the go-import meta tag matching importPath in an HTML document
+func parseGoImport(importPath string, body io.Reader) (vanityRoot, error) {
+	decoder := xml.NewDecoder(body)
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return vanityRoot{}, errors.New("no go-import meta tag found")
+		}
+		element, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if strings.EqualFold(element.Name.Local, "body") {
+			return vanityRoot{}, errors.New("no go-import meta tag found")
+		}
+		if !strings.EqualFold(element.Name.Local, "meta") || attribute(element, "name") != "go-import" {
+			continue
+		}
+
+		// content is "import-prefix vcs repo-url"
+		fields := strings.Fields(attribute(element, "content"))
+		if len(fields) != 3 {
+			continue
+		}
+		prefix := fields[0]
+		if importPath != prefix && !strings.HasPrefix(importPath, prefix+"/") {
+			continue
+		}
+		repo := fields[2]
+		if i := strings.Index(repo, "://"); i >= 0 {
+			repo = repo[i+3:]
+		}
+		repo = strings.TrimSuffix(strings.TrimSuffix(repo, "/"), ".git")
+		return vanityRoot{prefix: prefix, repo: repo}, nil
+	}
+}
+
+// attribute returns the value of the named attribute of element
+func attribute(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if strings.EqualFold(attr.Name.Local, name) {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// parentPath returns path without its last element
+func parentPath(path string) string {
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
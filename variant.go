@@ -0,0 +1,156 @@
+package gogroupimports
+
+import (
+	"go/ast"
+	"go/build"
+	"go/build/constraint"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// knownOS and knownArch are the GOOS and GOARCH values the go tool recognizes in file names
+var (
+	knownOS = []string{
+		"aix", "android", "darwin", "dragonfly", "freebsd", "hurd", "illumos", "ios", "js", "linux",
+		"nacl", "netbsd", "openbsd", "plan9", "solaris", "wasip1", "windows", "zos",
+	}
+	knownArch = []string{
+		"386", "amd64", "amd64p32", "arm", "armbe", "arm64", "arm64be", "loong64", "mips", "mipsle",
+		"mips64", "mips64le", "mips64p32", "mips64p32le", "ppc", "ppc64", "ppc64le", "riscv",
+		"riscv64", "s390", "s390x", "sparc", "sparc64", "wasm",
+	}
+)
+
+// osArchOf returns the GOOS and GOARCH filename is restricted to by its name, like
+// foo_linux.go or foo_windows_amd64_test.go, empty when it isn't
+func osArchOf(filename string) (goos, goarch string) {
+	name := strings.TrimSuffix(filepath.Base(filename), ".go")
+	name = strings.TrimSuffix(name, "_test")
+	// Like the go tool, the part before the first underscore is never a constraint
+	i := strings.Index(name, "_")
+	if i < 0 {
+		return "", ""
+	}
+	parts := strings.Split(name[i:], "_")
+	last := parts[len(parts)-1]
+	if len(parts) > 2 && slices.Contains(knownOS, parts[len(parts)-2]) && slices.Contains(knownArch, last) {
+		return parts[len(parts)-2], last
+	}
+	if slices.Contains(knownOS, last) {
+		return last, ""
+	}
+	if slices.Contains(knownArch, last) {
+		return "", last
+	}
+	return "", ""
+}
+
+// fileVariant describes the build variant a file belongs to, like linux, windows/amd64 or the
+// expression of its //go:build line. It is empty for files built everywhere.
+func fileVariant(filename string, node *ast.File) string {
+	switch goos, goarch := osArchOf(filename); {
+	case goos != "" && goarch != "":
+		return goos + "/" + goarch
+	case goos != "":
+		return goos
+	case goarch != "":
+		return goarch
+	}
+	if expr := buildConstraintOf(node); expr != nil {
+		return expr.String()
+	}
+	return ""
+}
+
+// buildConstraintOf returns the expression of the //go:build line of node, nil when it has none
+// or it doesn't parse
+func buildConstraintOf(node *ast.File) constraint.Expr {
+	for _, group := range node.Comments {
+		if group.Pos() > node.Package {
+			break
+		}
+		for _, comment := range group.List {
+			if !constraint.IsGoBuild(comment.Text) {
+				continue
+			}
+			expr, err := constraint.Parse(comment.Text)
+			if err != nil {
+				return nil
+			}
+			return expr
+		}
+	}
+	return nil
+}
+
+// builtOnlyWith reports whether node is built with tag set and no other, but not without it,
+// like files guarded by //go:build ignore or //go:build tools
+func builtOnlyWith(node *ast.File, tag string) bool {
+	expr := buildConstraintOf(node)
+	return expr != nil && expr.Eval(func(t string) bool { return t == tag }) && !expr.Eval(func(string) bool { return false })
+}
+
+// fileProfile returns the settings for the kind of file node is, and whether it is skipped
+// altogether: files built only with the ignore tag, like generator drivers, unless
+// IncludeIgnoredFiles is set, and tools files as ToolsFiles says
+func fileProfile(node *ast.File, settings Settings) (Settings, bool) {
+	if !settings.IncludeIgnoredFiles && builtOnlyWith(node, "ignore") {
+		return settings, true
+	}
+	return withToolsFileProfile(node, settings)
+}
+
+// withVariant attributes diagnostics to the build variant of the file they were found in
+func withVariant(diagnostics []Diagnostic, filename string, node *ast.File) []Diagnostic {
+	if len(diagnostics) == 0 {
+		return diagnostics
+	}
+	variant := fileVariant(filename, node)
+	for i := range diagnostics {
+		diagnostics[i].Variant = variant
+	}
+	return diagnostics
+}
+
+// buildContextFor returns a build context filename is compiled in, so that packages it imports
+// are loaded with the files of its platform. The default context is used when it builds the
+// file, otherwise the first known GOOS and GOARCH that do. The file is matched by its name and
+// the build constraint of node, it isn't read.
+func buildContextFor(filename string, node *ast.File) *build.Context {
+	ctxt := build.Default
+	goos, goarch := osArchOf(filename)
+	if goos != "" {
+		ctxt.GOOS = goos
+	}
+	if goarch != "" {
+		ctxt.GOARCH = goarch
+	}
+
+	header := "package p\n"
+	if expr := buildConstraintOf(node); expr != nil {
+		header = "//go:build " + expr.String() + "\n\n" + header
+	}
+	dir, name := filepath.Split(filename)
+	matches := func(candidate build.Context) (bool, error) {
+		candidate.OpenFile = func(string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(header)), nil
+		}
+		return candidate.MatchFile(dir, name)
+	}
+	if ok, err := matches(ctxt); err != nil || ok {
+		return &ctxt
+	}
+
+	for _, goos := range knownOS {
+		for _, goarch := range append([]string{ctxt.GOARCH}, knownArch...) {
+			candidate := ctxt
+			candidate.GOOS, candidate.GOARCH = goos, goarch
+			if ok, _ := matches(candidate); ok {
+				return &candidate
+			}
+		}
+	}
+	return &ctxt
+}
@@ -0,0 +1,120 @@
+package gogroupimports
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// FindGoFiles returns the Go files below root. Like the go tool it skips vendor and testdata
+// directories as well as files and directories starting with . or _. skipped, when not nil,
+// is called for every path left out this way. When root is the top of a git repository, paths
+// matched by its .gitignore files are left out as well.
+func FindGoFiles(root string, skipped func(path string)) ([]string, error) {
+	return FindGoFilesWith(root, WalkOptions{Skipped: skipped})
+}
+
+// WalkOptions adjusts which files FindGoFilesWith returns
+type WalkOptions struct {
+	// Skipped, when not nil, is called for every path left out
+	Skipped func(path string)
+	// NoGitignore keeps the paths matched by .gitignore files, which are skipped by default when
+	// the walk starts at the top of a git repository
+	NoGitignore bool
+	// Templates returns code generation templates as well, see IsTemplateFile
+	Templates bool
+}
+
+// FindGoFilesWith is FindGoFiles with options
+func FindGoFilesWith(root string, options WalkOptions) ([]string, error) {
+	skipped := func(path string) {
+		if options.Skipped != nil {
+			options.Skipped(path)
+		}
+	}
+
+	var ignore *gitignore
+	if !options.NoGitignore && isRepositoryRoot(root) {
+		ignore = &gitignore{}
+		if err := ignore.load(filepath.Join(root, ".git", "info", "exclude"), ""); err != nil {
+			return nil, err
+		}
+	}
+
+	var files []string
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := entry.Name()
+		ignored := strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+		rel := ""
+		if path != root {
+			relPath, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(relPath)
+			ignored = ignored || (ignore != nil && ignore.ignored(rel, entry.IsDir()))
+		}
+		if entry.IsDir() {
+			if path != root && (ignored || name == "vendor" || name == "testdata") {
+				skipped(path)
+				return filepath.SkipDir
+			}
+			if ignore != nil {
+				return ignore.load(filepath.Join(path, ".gitignore"), rel)
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".go") && !(options.Templates && IsTemplateFile(name)) {
+			return nil
+		}
+		if ignored {
+			skipped(path)
+			return nil
+		}
+		files = append(files, path)
+		return nil
+	})
+	return files, err
+}
+
+// FindPackageFiles returns the Go files of the packages matching patterns, like std, ./... or
+// example.com/app/..., resolved by the go tool from dir. Test files and files excluded by build
+// constraints are included, so every file of the packages is checked.
+func FindPackageFiles(dir string, patterns []string) ([]string, error) {
+	config := &packages.Config{
+		Mode:  packages.NeedName | packages.NeedFiles,
+		Dir:   dir,
+		Tests: true,
+	}
+	pkgs, err := packages.Load(config, patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	var errs []error
+	seen := make(map[string]bool)
+	for _, pkg := range pkgs {
+		// The test main packages consist of generated files in the build cache
+		if strings.HasSuffix(pkg.ID, ".test") {
+			continue
+		}
+		for _, pkgErr := range pkg.Errors {
+			errs = append(errs, pkgErr)
+		}
+		for _, file := range append(pkg.GoFiles, pkg.IgnoredFiles...) {
+			if strings.HasSuffix(file, ".go") && !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, joinErrors(errs)
+}